@@ -0,0 +1,104 @@
+package jwt
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// token 的类型，用于区分 access token 和 refresh token
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// Claims token 携带的数据
+// 时间相关的字段都是 Unix 时间戳，单位是秒
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+
+	// Extra 自定义的字段，和上面的字段平铺在一起
+	Extra map[string]any `json:"-"`
+}
+
+// registered 上面的字段在 JSON 中的名字
+var registered = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "token_type"}
+
+// claimsAlias 避免 MarshalJSON 递归调用
+type claimsAlias Claims
+
+func (c *Claims) MarshalJSON() ([]byte, error) {
+	bs, err := json.Marshal((*claimsAlias)(c))
+	if err != nil || len(c.Extra) == 0 {
+		return bs, err
+	}
+	m := make(map[string]any, len(c.Extra)+len(registered))
+	for k, v := range c.Extra {
+		m[k] = v
+	}
+	// 标准字段优先
+	if err = json.Unmarshal(bs, &m); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func (c *Claims) UnmarshalJSON(bs []byte) error {
+	if err := json.Unmarshal(bs, (*claimsAlias)(c)); err != nil {
+		return err
+	}
+	m := make(map[string]any)
+	if err := json.Unmarshal(bs, &m); err != nil {
+		return err
+	}
+	for _, k := range registered {
+		delete(m, k)
+	}
+	if len(m) > 0 {
+		c.Extra = m
+	}
+	return nil
+}
+
+func (c *Claims) Expiration() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// Audience 在 JSON 里面可以是一个字符串，也可以是字符串数组
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(bs []byte) error {
+	var single string
+	if err := json.Unmarshal(bs, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(bs, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
+
+func (a Audience) contains(aud string) bool {
+	for _, val := range a {
+		if val == aud {
+			return true
+		}
+	}
+	return false
+}
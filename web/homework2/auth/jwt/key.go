@@ -0,0 +1,113 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// 支持的签名算法
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// es256KeySize ES256 签名中 r 和 s 各自的字节数
+const es256KeySize = 32
+
+var errVerifyOnly = errors.New("jwt: 只能用于校验的密钥不能签名")
+
+// Key 签名和校验使用的密钥
+// ID 会被写入 token 头部的 kid，校验的时候根据 kid 查找密钥，这样就可以轮换密钥
+type Key struct {
+	ID        string
+	Algorithm string
+
+	hmacSecret []byte
+	rsaPriv    *rsa.PrivateKey
+	rsaPub     *rsa.PublicKey
+	ecPriv     *ecdsa.PrivateKey
+	ecPub      *ecdsa.PublicKey
+}
+
+func NewHS256Key(kid string, secret []byte) *Key {
+	return &Key{ID: kid, Algorithm: HS256, hmacSecret: secret}
+}
+
+func NewRS256Key(kid string, priv *rsa.PrivateKey) *Key {
+	return &Key{ID: kid, Algorithm: RS256, rsaPriv: priv, rsaPub: &priv.PublicKey}
+}
+
+// NewRS256VerifyKey 只有公钥，只能用于校验
+func NewRS256VerifyKey(kid string, pub *rsa.PublicKey) *Key {
+	return &Key{ID: kid, Algorithm: RS256, rsaPub: pub}
+}
+
+func NewES256Key(kid string, priv *ecdsa.PrivateKey) *Key {
+	return &Key{ID: kid, Algorithm: ES256, ecPriv: priv, ecPub: &priv.PublicKey}
+}
+
+// NewES256VerifyKey 只有公钥，只能用于校验
+func NewES256VerifyKey(kid string, pub *ecdsa.PublicKey) *Key {
+	return &Key{ID: kid, Algorithm: ES256, ecPub: pub}
+}
+
+func (k *Key) sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.hmacSecret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case RS256:
+		if k.rsaPriv == nil {
+			return nil, errVerifyOnly
+		}
+		return rsa.SignPKCS1v15(rand.Reader, k.rsaPriv, crypto.SHA256, digest[:])
+	case ES256:
+		if k.ecPriv == nil {
+			return nil, errVerifyOnly
+		}
+		if k.ecPriv.Curve != elliptic.P256() {
+			return nil, ErrAlgorithmMismatch
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k.ecPriv, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// 签名是定长的 r 和 s 拼接在一起，而不是 ASN.1 格式
+		sig := make([]byte, 2*es256KeySize)
+		r.FillBytes(sig[:es256KeySize])
+		s.FillBytes(sig[es256KeySize:])
+		return sig, nil
+	}
+	return nil, ErrAlgorithmMismatch
+}
+
+func (k *Key) verify(data []byte, sig []byte) bool {
+	digest := sha256.Sum256(data)
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.hmacSecret)
+		mac.Write(data)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case RS256:
+		return k.rsaPub != nil &&
+			rsa.VerifyPKCS1v15(k.rsaPub, crypto.SHA256, digest[:], sig) == nil
+	case ES256:
+		if k.ecPub == nil || k.ecPub.Curve != elliptic.P256() || len(sig) != 2*es256KeySize {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:es256KeySize])
+		s := new(big.Int).SetBytes(sig[es256KeySize:])
+		return ecdsa.Verify(k.ecPub, digest[:], r, s)
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package jwt
+
+import (
+	"net/http"
+	"strings"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// ctxClaimsKey Claims 在 web.Context.UserValues 中的 key
+const ctxClaimsKey = "_jwt_claims"
+
+// MiddlewareBuilder 校验 Authorization 头部中的 Bearer token
+// 校验通过之后，可以使用 ClaimsFromContext 取出 token 携带的数据
+type MiddlewareBuilder struct {
+	svc      *Service
+	excludes map[string]struct{}
+}
+
+func NewMiddlewareBuilder(svc *Service) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		svc:      svc,
+		excludes: make(map[string]struct{}, 4),
+	}
+}
+
+// Exclude 不需要校验的路径，例如登录和健康检查
+func (b *MiddlewareBuilder) Exclude(paths ...string) *MiddlewareBuilder {
+	for _, p := range paths {
+		b.excludes[p] = struct{}{}
+	}
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			if _, ok := b.excludes[ctx.Req.URL.Path]; ok {
+				next(ctx)
+				return
+			}
+			token, ok := bearerToken(ctx.Req)
+			if !ok {
+				ctx.Resp.Header().Set("WWW-Authenticate", `Bearer`)
+				ctx.RespStatusCode = http.StatusUnauthorized
+				return
+			}
+			claims, err := b.svc.Parse(token)
+			if err == nil && claims.TokenType == TokenTypeRefresh {
+				// refresh token 只能用于刷新
+				err = ErrInvalidTokenType
+			}
+			if err != nil {
+				ctx.Resp.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				ctx.RespStatusCode = http.StatusUnauthorized
+				return
+			}
+			if ctx.UserValues == nil {
+				ctx.UserValues = make(map[string]any, 1)
+			}
+			ctx.UserValues[ctxClaimsKey] = claims
+			next(ctx)
+		}
+	}
+}
+
+// ClaimsFromContext 取出中间件校验通过的 Claims
+func ClaimsFromContext(ctx *web.Context) (*Claims, bool) {
+	claims, ok := ctx.UserValues[ctxClaimsKey].(*Claims)
+	return claims, ok
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	auth := req.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
@@ -0,0 +1,73 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	svc := NewService(NewHS256Key("k1", []byte("secret")))
+	pair, err := svc.Issue("123", nil)
+	require.NoError(t, err)
+
+	s := web.NewHTTPServer()
+	s.Use(NewMiddlewareBuilder(svc).Exclude("/login", "/health").Build())
+	handler := func(ctx *web.Context) {
+		sub := "anonymous"
+		if claims, ok := ClaimsFromContext(ctx); ok {
+			sub = claims.Subject
+		}
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(sub)
+	}
+	s.Get("/profile", handler)
+	s.Get("/health", handler)
+
+	testCases := []struct {
+		name     string
+		path     string
+		auth     string
+		wantCode int
+		wantBody string
+		wantAuth string
+	}{
+		{name: "valid", path: "/profile", auth: "Bearer " + pair.AccessToken, wantCode: http.StatusOK, wantBody: "123"},
+		{name: "lower case scheme", path: "/profile", auth: "bearer " + pair.AccessToken, wantCode: http.StatusOK, wantBody: "123"},
+		{name: "exclude", path: "/health", wantCode: http.StatusOK, wantBody: "anonymous"},
+		{name: "no token", path: "/profile", wantCode: http.StatusUnauthorized, wantAuth: "Bearer"},
+		{name: "basic auth", path: "/profile", auth: "Basic abc", wantCode: http.StatusUnauthorized, wantAuth: "Bearer"},
+		{
+			name:     "invalid token",
+			path:     "/profile",
+			auth:     "Bearer abc.def.ghi",
+			wantCode: http.StatusUnauthorized,
+			wantAuth: `Bearer error="invalid_token"`,
+		},
+		{
+			name:     "refresh token",
+			path:     "/profile",
+			auth:     "Bearer " + pair.RefreshToken,
+			wantCode: http.StatusUnauthorized,
+			wantAuth: `Bearer error="invalid_token"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantAuth, recorder.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
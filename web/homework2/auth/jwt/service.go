@@ -0,0 +1,288 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTokenMalformed    = errors.New("jwt: token 格式不正确")
+	ErrSignatureInvalid  = errors.New("jwt: 签名不正确")
+	ErrAlgorithmMismatch = errors.New("jwt: 签名算法不匹配")
+	ErrKeyNotFound       = errors.New("jwt: 找不到密钥")
+	ErrTokenExpired      = errors.New("jwt: token 已经过期")
+	ErrTokenNotValidYet  = errors.New("jwt: token 还没有生效")
+	ErrInvalidIssuer     = errors.New("jwt: iss 不正确")
+	ErrInvalidAudience   = errors.New("jwt: aud 不正确")
+	ErrInvalidTokenType  = errors.New("jwt: token 类型不正确")
+)
+
+// KeyFunc 根据 token 头部的 kid 查找校验用的密钥
+// 密钥保存在配置中心之类的地方的时候，可以通过它实现密钥轮换
+type KeyFunc func(kid string) (*Key, error)
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// TokenPair 登录或者刷新的时候签发的一对 token
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn access token 的有效期，单位是秒
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// Service 签发和校验 token
+type Service struct {
+	mutex sync.RWMutex
+	// signKey 当前用于签名的密钥
+	signKey *Key
+	// keys 校验时可以使用的所有密钥，按 kid 索引
+	keys    map[string]*Key
+	keyFunc KeyFunc
+
+	issuer     string
+	audience   Audience
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	// leeway 校验时间的时候允许的误差，用于应对服务器之间的时钟偏差
+	leeway time.Duration
+	now    func() time.Time
+}
+
+type ServiceOption func(s *Service)
+
+// NewService signKey 用于签名，同时也会被用于校验
+func NewService(signKey *Key, opts ...ServiceOption) *Service {
+	s := &Service{
+		signKey:    signKey,
+		keys:       map[string]*Key{signKey.ID: signKey},
+		accessTTL:  15 * time.Minute,
+		refreshTTL: 7 * 24 * time.Hour,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ServiceWithIssuer 签发的 token 会带上 iss，校验的时候也会检查 iss
+func ServiceWithIssuer(issuer string) ServiceOption {
+	return func(s *Service) {
+		s.issuer = issuer
+	}
+}
+
+// ServiceWithAudience 签发的 token 会带上 aud，校验的时候要求 token 的 aud 包含其中之一
+func ServiceWithAudience(audience ...string) ServiceOption {
+	return func(s *Service) {
+		s.audience = audience
+	}
+}
+
+// ServiceWithTTL access token 和 refresh token 的有效期，默认是 15 分钟和 7 天
+func ServiceWithTTL(access time.Duration, refresh time.Duration) ServiceOption {
+	return func(s *Service) {
+		s.accessTTL = access
+		s.refreshTTL = refresh
+	}
+}
+
+func ServiceWithLeeway(leeway time.Duration) ServiceOption {
+	return func(s *Service) {
+		s.leeway = leeway
+	}
+}
+
+// ServiceWithKeyFunc 自定义查找校验密钥的逻辑，设置之后 Service 自身保存的密钥只用于签名
+func ServiceWithKeyFunc(fn KeyFunc) ServiceOption {
+	return func(s *Service) {
+		s.keyFunc = fn
+	}
+}
+
+// RotateKey 使用新的密钥签名
+// 旧的密钥依旧可以用于校验，直到调用 RemoveKey，这样已经签发的 token 不会立刻失效
+func (s *Service) RotateKey(key *Key) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.signKey = key
+	s.keys[key.ID] = key
+}
+
+// RemoveKey 旧的密钥不再用于校验，正在用于签名的密钥不能被移除
+func (s *Service) RemoveKey(kid string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.signKey.ID == kid {
+		return
+	}
+	delete(s.keys, kid)
+}
+
+// Sign 签名，claims 会被原样写入 token
+func (s *Service) Sign(claims *Claims) (string, error) {
+	s.mutex.RLock()
+	key := s.signKey
+	s.mutex.RUnlock()
+
+	hb, err := json.Marshal(header{Alg: key.Algorithm, Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	cb, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signing := encode(hb) + "." + encode(cb)
+	sig, err := key.sign([]byte(signing))
+	if err != nil {
+		return "", err
+	}
+	return signing + "." + encode(sig), nil
+}
+
+// Parse 校验签名和 exp、nbf、iss、aud，返回 token 携带的数据
+func (s *Service) Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	hb, err := decode(parts[0])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	var h header
+	if err = json.Unmarshal(hb, &h); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	key, err := s.findKey(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	// 必须和密钥的算法一致，避免 alg=none 或者用公钥作为 HMAC 密钥之类的攻击
+	if h.Alg != key.Algorithm {
+		return nil, ErrAlgorithmMismatch
+	}
+	sig, err := decode(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if !key.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrSignatureInvalid
+	}
+	cb, err := decode(parts[1])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	claims := &Claims{}
+	if err = json.Unmarshal(cb, claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err = s.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (s *Service) findKey(kid string) (*Key, error) {
+	if s.keyFunc != nil {
+		key, err := s.keyFunc(kid)
+		if err == nil && key == nil {
+			err = ErrKeyNotFound
+		}
+		return key, err
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func (s *Service) validate(c *Claims) error {
+	now := s.now()
+	if c.ExpiresAt != 0 && now.After(time.Unix(c.ExpiresAt, 0).Add(s.leeway)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != 0 && now.Add(s.leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrTokenNotValidYet
+	}
+	if s.issuer != "" && c.Issuer != s.issuer {
+		return ErrInvalidIssuer
+	}
+	if len(s.audience) > 0 {
+		for _, aud := range s.audience {
+			if c.Audience.contains(aud) {
+				return nil
+			}
+		}
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// Issue 签发一对 token，extra 是自定义的数据，会被同时写入两个 token
+func (s *Service) Issue(subject string, extra map[string]any) (*TokenPair, error) {
+	access, err := s.Sign(s.newClaims(subject, TokenTypeAccess, s.accessTTL, extra))
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.Sign(s.newClaims(subject, TokenTypeRefresh, s.refreshTTL, extra))
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(s.accessTTL / time.Second),
+	}, nil
+}
+
+// Refresh 使用 refresh token 签发一对新的 token
+// 如果需要让 refresh token 只能使用一次，可以记录已经使用过的 jti
+func (s *Service) Refresh(refreshToken string) (*TokenPair, error) {
+	claims, err := s.Parse(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeRefresh {
+		return nil, ErrInvalidTokenType
+	}
+	return s.Issue(claims.Subject, claims.Extra)
+}
+
+func (s *Service) newClaims(subject string, typ string, ttl time.Duration, extra map[string]any) *Claims {
+	now := s.now()
+	return &Claims{
+		Issuer:    s.issuer,
+		Subject:   subject,
+		Audience:  s.audience,
+		ExpiresAt: now.Add(ttl).Unix(),
+		NotBefore: now.Unix(),
+		IssuedAt:  now.Unix(),
+		ID:        uuid.NewString(),
+		TokenType: typ,
+		Extra:     extra,
+	}
+}
+
+func encode(bs []byte) string {
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,242 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_SignAndParse(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		key  *Key
+	}{
+		{name: "HS256", key: NewHS256Key("hs", []byte("secret"))},
+		{name: "RS256", key: NewRS256Key("rs", rsaKey)},
+		{name: "ES256", key: NewES256Key("es", ecKey)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewService(tc.key)
+			claims := &Claims{
+				Subject:  "123",
+				Audience: Audience{"web"},
+				Extra:    map[string]any{"role": "admin"},
+			}
+			token, err := svc.Sign(claims)
+			require.NoError(t, err)
+			got, err := svc.Parse(token)
+			require.NoError(t, err)
+			assert.Equal(t, claims, got)
+
+			// 篡改数据之后签名失效
+			parts := strings.Split(token, ".")
+			fake, err := svc.Sign(&Claims{Subject: "456"})
+			require.NoError(t, err)
+			parts[1] = strings.Split(fake, ".")[1]
+			_, err = svc.Parse(strings.Join(parts, "."))
+			assert.Equal(t, ErrSignatureInvalid, err)
+		})
+	}
+}
+
+func TestService_Parse_JWTIOExample(t *testing.T) {
+	// jwt.io 上的例子
+	token := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9." +
+		"eyJzdWIiOiIxMjM0NTY3ODkwIiwibmFtZSI6IkpvaG4gRG9lIiwiaWF0IjoxNTE2MjM5MDIyfQ." +
+		"SflKxwRJSMeKKF2QT4fwpMeJf36POk6yJV_adQssw5c"
+	svc := NewService(NewHS256Key("", []byte("your-256-bit-secret")))
+	claims, err := svc.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, &Claims{
+		Subject:  "1234567890",
+		IssuedAt: 1516239022,
+		Extra:    map[string]any{"name": "John Doe"},
+	}, claims)
+}
+
+func TestService_Parse(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	signer := NewService(NewHS256Key("k1", []byte("secret")))
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		svc     *Service
+		token   func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name:    "malformed",
+			svc:     signer,
+			token:   func(t *testing.T) string { return "abc.def" },
+			wantErr: ErrTokenMalformed,
+		},
+		{
+			name: "alg none",
+			svc:  signer,
+			token: func(t *testing.T) string {
+				return encode([]byte(`{"alg":"none","kid":"k1"}`)) + "." + encode([]byte(`{"sub":"1"}`)) + "."
+			},
+			wantErr: ErrAlgorithmMismatch,
+		},
+		{
+			name: "unknown kid",
+			svc:  NewService(NewHS256Key("k2", []byte("secret"))),
+			token: func(t *testing.T) string {
+				return sign(t, signer, &Claims{})
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "wrong algorithm",
+			svc:  NewService(NewRS256VerifyKey("k1", &rsaKey.PublicKey)),
+			token: func(t *testing.T) string {
+				return sign(t, signer, &Claims{})
+			},
+			wantErr: ErrAlgorithmMismatch,
+		},
+		{
+			name: "expired",
+			svc:  newTestService(now, ServiceWithLeeway(time.Second)),
+			token: func(t *testing.T) string {
+				return sign(t, signer, &Claims{ExpiresAt: now.Add(-2 * time.Second).Unix()})
+			},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name: "expired within leeway",
+			svc:  newTestService(now, ServiceWithLeeway(time.Minute)),
+			token: func(t *testing.T) string {
+				return sign(t, signer, &Claims{ExpiresAt: now.Add(-2 * time.Second).Unix()})
+			},
+		},
+		{
+			name: "not valid yet",
+			svc:  newTestService(now),
+			token: func(t *testing.T) string {
+				return sign(t, signer, &Claims{NotBefore: now.Add(time.Minute).Unix()})
+			},
+			wantErr: ErrTokenNotValidYet,
+		},
+		{
+			name: "issuer",
+			svc:  newTestService(now, ServiceWithIssuer("geekbang")),
+			token: func(t *testing.T) string {
+				return sign(t, signer, &Claims{Issuer: "evil"})
+			},
+			wantErr: ErrInvalidIssuer,
+		},
+		{
+			name: "audience",
+			svc:  newTestService(now, ServiceWithAudience("web", "app")),
+			token: func(t *testing.T) string {
+				return sign(t, signer, &Claims{Audience: Audience{"admin"}})
+			},
+			wantErr: ErrInvalidAudience,
+		},
+		{
+			name: "audience matched",
+			svc:  newTestService(now, ServiceWithAudience("web", "app")),
+			token: func(t *testing.T) string {
+				return sign(t, signer, &Claims{Audience: Audience{"admin", "app"}})
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.svc.Parse(tc.token(t))
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestService_RotateKey(t *testing.T) {
+	svc := NewService(NewHS256Key("k1", []byte("secret1")))
+	oldToken := sign(t, svc, &Claims{Subject: "1"})
+
+	svc.RotateKey(NewHS256Key("k2", []byte("secret2")))
+	newToken := sign(t, svc, &Claims{Subject: "1"})
+	// 旧的 token 依旧可以校验通过
+	_, err := svc.Parse(oldToken)
+	assert.NoError(t, err)
+	_, err = svc.Parse(newToken)
+	assert.NoError(t, err)
+
+	svc.RemoveKey("k1")
+	_, err = svc.Parse(oldToken)
+	assert.Equal(t, ErrKeyNotFound, err)
+	// 正在使用的密钥不能被移除
+	svc.RemoveKey("k2")
+	_, err = svc.Parse(newToken)
+	assert.NoError(t, err)
+}
+
+func TestService_KeyFunc(t *testing.T) {
+	keys := map[string]*Key{"k1": NewHS256Key("k1", []byte("secret1"))}
+	svc := NewService(keys["k1"], ServiceWithKeyFunc(func(kid string) (*Key, error) {
+		return keys[kid], nil
+	}))
+	token := sign(t, svc, &Claims{Subject: "1"})
+	_, err := svc.Parse(token)
+	assert.NoError(t, err)
+	delete(keys, "k1")
+	_, err = svc.Parse(token)
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+func TestService_Refresh(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	svc := newTestService(now, ServiceWithIssuer("geekbang"), ServiceWithTTL(time.Minute, time.Hour))
+	pair, err := svc.Issue("123", map[string]any{"role": "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), pair.ExpiresIn)
+
+	access, err := svc.Parse(pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, TokenTypeAccess, access.TokenType)
+	assert.Equal(t, now.Add(time.Minute).Unix(), access.ExpiresAt)
+
+	// access token 不能用来刷新
+	_, err = svc.Refresh(pair.AccessToken)
+	assert.Equal(t, ErrInvalidTokenType, err)
+
+	// access token 过期之后依旧可以刷新
+	svc.now = func() time.Time { return now.Add(30 * time.Minute) }
+	newPair, err := svc.Refresh(pair.RefreshToken)
+	require.NoError(t, err)
+	claims, err := svc.Parse(newPair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "123", claims.Subject)
+	assert.Equal(t, "geekbang", claims.Issuer)
+	assert.Equal(t, map[string]any{"role": "admin"}, claims.Extra)
+
+	svc.now = func() time.Time { return now.Add(2 * time.Hour) }
+	_, err = svc.Refresh(pair.RefreshToken)
+	assert.Equal(t, ErrTokenExpired, err)
+}
+
+func newTestService(now time.Time, opts ...ServiceOption) *Service {
+	svc := NewService(NewHS256Key("k1", []byte("secret")), opts...)
+	svc.now = func() time.Time { return now }
+	return svc
+}
+
+func sign(t *testing.T, svc *Service, claims *Claims) string {
+	token, err := svc.Sign(claims)
+	require.NoError(t, err)
+	return token
+}
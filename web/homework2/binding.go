@@ -0,0 +1,285 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 绑定使用的标签
+// 例如：
+//
+//	type UserReq struct {
+//		ID     int64                 `path:"id"`
+//		Page   int                   `query:"page"`
+//		Tags   []string              `query:"tag"`
+//		Token  string                `header:"X-Token"`
+//		Name   string                `form:"name"`
+//		Avatar *multipart.FileHeader `form:"avatar"`
+//		Birth  time.Time             `query:"birth" time_format:"2006-01-02"`
+//	}
+const (
+	tagQuery      = "query"
+	tagForm       = "form"
+	tagPath       = "path"
+	tagHeader     = "header"
+	tagTimeFormat = "time_format"
+)
+
+// defaultMultipartMemory 解析 multipart 表单时，保存在内存中的最大字节数
+// 超过的部分会被写入临时文件
+const defaultMultipartMemory = 32 << 20
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	fileHeaderType = reflect.TypeOf(&multipart.FileHeader{})
+)
+
+// Bind 依据字段上的 path、query、header 和 form 标签，一次性填充 val
+// val 必须是指向结构体的指针。
+// 请求体是 JSON 的时候，请使用 BindJSON
+// Bind 和 BindJSON 在绑定成功之后，会使用 Validator 校验 val；
+// BindQuery、BindPath、BindHeader 和 BindForm 只负责绑定，因为它们可以组合使用，
+// 只有全部绑定完成之后才能校验，此时需要调用 Validate
+func (c *Context) Bind(val any) error {
+	if err := c.bindPath(val); err != nil {
+		return err
+	}
+	if err := c.bindQuery(val); err != nil {
+		return err
+	}
+	if err := c.bindHeader(val); err != nil {
+		return err
+	}
+	if isFormContentType(c.Req.Header.Get("Content-Type")) {
+		if err := c.bindForm(val); err != nil {
+			return err
+		}
+	}
+	return c.Validate(val)
+}
+
+// BindQuery 依据 query 标签，使用查询参数填充 val，不会校验 val
+func (c *Context) BindQuery(val any) error {
+	return c.bindQuery(val)
+}
+
+// BindPath 依据 path 标签，使用路径参数填充 val，不会校验 val
+func (c *Context) BindPath(val any) error {
+	return c.bindPath(val)
+}
+
+// BindHeader 依据 header 标签，使用请求头部填充 val，不会校验 val
+func (c *Context) BindHeader(val any) error {
+	return c.bindHeader(val)
+}
+
+// BindForm 依据 form 标签，使用表单数据填充 val
+// 支持 application/x-www-form-urlencoded 和 multipart/form-data，
+// 上传的文件可以绑定到 *multipart.FileHeader 或者 []*multipart.FileHeader 类型的字段上
+// 不会校验 val
+func (c *Context) BindForm(val any) error {
+	return c.bindForm(val)
+}
+
+func (c *Context) bindQuery(val any) error {
+	if c.cacheQueryValues == nil {
+		c.cacheQueryValues = c.Req.URL.Query()
+	}
+	return bindValues(val, tagQuery, func(key string) ([]string, bool) {
+		vals, ok := c.cacheQueryValues[key]
+		return vals, ok
+	}, nil)
+}
+
+func (c *Context) bindPath(val any) error {
+	return bindValues(val, tagPath, func(key string) ([]string, bool) {
+		v, ok := c.PathParams[key]
+		if !ok {
+			return nil, false
+		}
+		return []string{v}, true
+	}, nil)
+}
+
+func (c *Context) bindHeader(val any) error {
+	return bindValues(val, tagHeader, func(key string) ([]string, bool) {
+		vals, ok := c.Req.Header[http.CanonicalHeaderKey(key)]
+		return vals, ok
+	}, nil)
+}
+
+func (c *Context) bindForm(val any) error {
+	var files map[string][]*multipart.FileHeader
+	if strings.HasPrefix(c.Req.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := c.Req.ParseMultipartForm(defaultMultipartMemory); err != nil {
+			return bodyErr(err)
+		}
+		files = c.Req.MultipartForm.File
+	} else if err := c.Req.ParseForm(); err != nil {
+		return bodyErr(err)
+	}
+	return bindValues(val, tagForm, func(key string) ([]string, bool) {
+		vals, ok := c.Req.Form[key]
+		return vals, ok
+	}, files)
+}
+
+func isFormContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(contentType, "multipart/form-data")
+}
+
+// bindValues 遍历 val 的字段，使用 lookup 查找标签对应的值并且完成转换
+// 没有标签或者找不到值的字段会被忽略
+func bindValues(val any, tag string,
+	lookup func(key string) ([]string, bool),
+	files map[string][]*multipart.FileHeader) error {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("web: 只支持绑定到指向结构体的指针")
+	}
+	return bindStruct(rv.Elem(), tag, lookup, files)
+}
+
+func bindStruct(rv reflect.Value, tag string,
+	lookup func(key string) ([]string, bool),
+	files map[string][]*multipart.FileHeader) error {
+	typ := rv.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		fd := typ.Field(i)
+		fv := rv.Field(i)
+		key, ok := fd.Tag.Lookup(tag)
+		// 没有标签的嵌套结构体，继续处理它的字段
+		if fd.Anonymous && fd.Type.Kind() == reflect.Struct && !ok {
+			if err := bindStruct(fv, tag, lookup, files); err != nil {
+				return err
+			}
+			continue
+		}
+		if !fd.IsExported() || !ok || key == "-" {
+			continue
+		}
+		if files != nil && isFileField(fd.Type) {
+			if fhs, ok := files[key]; ok && len(fhs) > 0 {
+				setFiles(fv, fhs)
+			}
+			continue
+		}
+		vals, ok := lookup(key)
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		if err := setValues(fv, vals, fd.Tag.Get(tagTimeFormat)); err != nil {
+			return fmt.Errorf("web: 绑定字段 %s 失败: %w", fd.Name, err)
+		}
+	}
+	return nil
+}
+
+func isFileField(typ reflect.Type) bool {
+	return typ == fileHeaderType ||
+		(typ.Kind() == reflect.Slice && typ.Elem() == fileHeaderType)
+}
+
+func setFiles(fv reflect.Value, fhs []*multipart.FileHeader) {
+	if fv.Kind() == reflect.Slice {
+		fv.Set(reflect.ValueOf(fhs))
+		return
+	}
+	fv.Set(reflect.ValueOf(fhs[0]))
+}
+
+// setValues 将 vals 转换之后设置到 fv 上
+// 切片类型使用全部的值，其余类型只使用第一个值
+func setValues(fv reflect.Value, vals []string, timeFormat string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, v := range vals {
+			if err := setValue(slice.Index(i), v, timeFormat); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setValue(fv, vals[0], timeFormat)
+}
+
+// setValue 将字符串 val 转换成 fv 的类型并且设置
+// 支持所有的基本类型、time.Time、time.Duration、[]byte 以及它们的指针
+func setValue(fv reflect.Value, val string, timeFormat string) error {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setValue(fv.Elem(), val, timeFormat)
+	}
+
+	switch fv.Type() {
+	case timeType:
+		if timeFormat == "" {
+			timeFormat = time.RFC3339
+		}
+		t, err := time.Parse(timeFormat, val)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Complex64, reflect.Complex128:
+		cpx, err := strconv.ParseComplex(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetComplex(cpx)
+	case reflect.Slice:
+		// 只有 []byte 会走到这里
+		fv.SetBytes([]byte(val))
+	default:
+		return fmt.Errorf("web: 不支持的类型 %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindPage struct {
+	Page int  `query:"page"`
+	Size *int `query:"size"`
+}
+
+type bindUserReq struct {
+	bindPage
+	ID       int64         `path:"id"`
+	Active   bool          `query:"active"`
+	Score    float64       `query:"score"`
+	Age      uint8         `query:"age"`
+	Tags     []string      `query:"tag"`
+	IDs      []int64       `query:"ids"`
+	Timeout  time.Duration `query:"timeout"`
+	Birthday time.Time     `query:"birthday" time_format:"2006-01-02"`
+	Token    string        `header:"x-token"`
+	Name     string        `form:"name"`
+	Ignored  string        `query:"-"`
+}
+
+func TestContext_Bind(t *testing.T) {
+	form := strings.NewReader("name=Tom")
+	req := httptest.NewRequest(http.MethodPost,
+		"/user/123?page=2&size=10&active=true&score=9.5&age=18&tag=a&tag=b&ids=1&ids=2"+
+			"&timeout=3s&birthday=2000-01-02&Ignored=abc", form)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Token", "my-token")
+	ctx := &Context{Req: req, PathParams: map[string]string{"id": "123"}}
+
+	val := &bindUserReq{}
+	err := ctx.Bind(val)
+	require.NoError(t, err)
+	size := 10
+	assert.Equal(t, &bindUserReq{
+		bindPage: bindPage{Page: 2, Size: &size},
+		ID:       123,
+		Active:   true,
+		Score:    9.5,
+		Age:      18,
+		Tags:     []string{"a", "b"},
+		IDs:      []int64{1, 2},
+		Timeout:  3 * time.Second,
+		Birthday: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+		Token:    "my-token",
+		Name:     "Tom",
+	}, val)
+}
+
+func TestContext_BindQuery(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   string
+		val     any
+		wantVal any
+		wantErr string
+	}{
+		{
+			name:    "not pointer",
+			val:     bindPage{},
+			wantErr: "web: 只支持绑定到指向结构体的指针",
+		},
+		{
+			name:    "missing",
+			val:     &bindPage{},
+			wantVal: &bindPage{},
+		},
+		{
+			name:    "invalid",
+			query:   "page=abc",
+			val:     &bindPage{},
+			wantErr: `web: 绑定字段 Page 失败: strconv.ParseInt: parsing "abc": invalid syntax`,
+		},
+		{
+			name:    "overflow",
+			query:   "age=256",
+			val:     &bindUserReq{},
+			wantErr: `web: 绑定字段 Age 失败: strconv.ParseUint: parsing "256": value out of range`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil)}
+			err := ctx.BindQuery(tc.val)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantVal, tc.val)
+		})
+	}
+}
+
+func TestContext_BindForm_Multipart(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	require.NoError(t, writer.WriteField("name", "Tom"))
+	fw, err := writer.CreateFormFile("avatar", "avatar.png")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("png"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	ctx := &Context{Req: req}
+
+	val := &struct {
+		Name    string                  `form:"name"`
+		Avatar  *multipart.FileHeader   `form:"avatar"`
+		Avatars []*multipart.FileHeader `form:"avatar"`
+	}{}
+	err = ctx.BindForm(val)
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", val.Name)
+	require.NotNil(t, val.Avatar)
+	assert.Equal(t, "avatar.png", val.Avatar.Filename)
+	assert.Equal(t, int64(3), val.Avatar.Size)
+	assert.Len(t, val.Avatars, 1)
+}
+
+func TestContext_BindJSON_BodyTooLarge(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"name":"a very long name"}`))
+	req.Body = http.MaxBytesReader(recorder, req.Body, 8)
+	ctx := &Context{Req: req, Resp: recorder}
+	val := map[string]any{}
+	err := ctx.BindJSON(&val)
+	assert.Equal(t, ErrBodyTooLarge, err)
+}
@@ -3,9 +3,10 @@ package web
 import (
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
 	"net/url"
-	"strconv"
+	"strings"
 )
 
 type Context struct {
@@ -20,16 +21,89 @@ type Context struct {
 	RespStatusCode int
 	RespData []byte
 
+	// PathParams 命中的路由的路径参数
+	// 这个 map 会随着 Context 一起被复用，handler 返回之后就会被下一个请求修改，
+	// 如果需要在 handler 返回之后继续使用，要么复制一份，要么调用 Retain
 	PathParams map[string]string
 	// 命中的路由
 	MatchedRoute string
 
+	// UserValues 在 middleware 和 handler 之间传递数据，例如 session
+	UserValues map[string]any
+
+	// RequestID 请求的唯一标识，由 accesslog 之类的中间件设置
+	RequestID string
+
+	// Err HandleErrFunc 返回的 error，由 problem 之类的中间件统一转化为响应
+	Err error
+
 	// 万一将来有需求，可以考虑支持这个，但是需要复杂一点的机制
 	// Body []byte 用户返回的响应
-	// Err error 用户执行的 Error
 
 	// 缓存的数据
 	cacheQueryValues url.Values
+
+	// validator 用于校验 Bind 系列方法绑定的数据
+	validator Validator
+	// validationErrs 最近一次绑定的校验错误
+	validationErrs ValidationErrors
+
+	tplEngine TemplateEngine
+	// trustProxyHeaders 由 ServerWithProxyHeaders 开启
+	trustProxyHeaders bool
+
+	// respWriter 记录了响应码和写入的字节数，即 Resp 本身
+	respWriter *responseWriter
+	// streaming 进入流式响应之后，RespStatusCode 和 RespData 不再被回写
+	streaming bool
+
+	// retained 为 true 的时候，Context 不会被放回池子里面复用
+	retained bool
+	// 下面的字段在复用 Context 的时候会被保留，避免每次请求都分配内存
+	pathParams map[string]string
+	paramBuf   []paramValue
+	mdls       mdlCollector
+}
+
+// reset 复用 Context 处理新的请求
+func (c *Context) reset(s *HTTPServer, writer http.ResponseWriter, request *http.Request) {
+	rw := c.respWriter
+	*rw = responseWriter{ResponseWriter: writer}
+	*c = Context{
+		Req:               request,
+		Resp:              rw,
+		validator:         s.validator,
+		tplEngine:         s.tplEngine,
+		trustProxyHeaders: s.trustProxyHeaders,
+		respWriter:        rw,
+		pathParams:        c.pathParams,
+		paramBuf:          c.paramBuf[:0],
+		mdls:              c.mdls,
+	}
+}
+
+// Retain 声明 handler 返回之后还会继续使用 Context，例如在另外的 goroutine 里面处理请求
+// HTTPServer 会复用 Context，默认情况下 handler 返回之后 Context 就会被用于处理其它请求
+func (c *Context) Retain() {
+	c.retained = true
+}
+
+// setPathParams 设置路径参数，同名的参数后面的覆盖前面的
+func (c *Context) setPathParams(ps []paramValue) {
+	if len(ps) == 0 {
+		c.PathParams = nil
+		return
+	}
+	if c.pathParams == nil {
+		c.pathParams = make(map[string]string, len(ps))
+	}
+	for k := range c.pathParams {
+		delete(c.pathParams, k)
+	}
+	for _, p := range ps {
+		c.pathParams[p.key] = p.value
+	}
+	c.PathParams = c.pathParams
 }
 
 func (c *Context) BindJSON(val any) error {
@@ -38,14 +112,38 @@ func (c *Context) BindJSON(val any) error {
 	}
 	decoder := json.NewDecoder(c.Req.Body)
 	decoder.DisallowUnknownFields()
-	return decoder.Decode(val)
+	if err := decoder.Decode(val); err != nil {
+		return bodyErr(err)
+	}
+	return c.Validate(val)
+}
+
+// Validate 使用 Validator 校验绑定之后的数据
+// 分别使用 BindPath、BindQuery 之类的方法绑定的时候，需要在全部绑定完成之后调用
+// 校验失败的时候，会记录下 ValidationErrors，方便 middleware 统一处理
+func (c *Context) Validate(val any) error {
+	v := c.validator
+	if v == nil {
+		v = defaultValidator
+	}
+	err := v.Validate(val)
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		c.validationErrs = ve
+	}
+	return err
+}
+
+// ValidationErrors 返回最近一次绑定的校验错误
+func (c *Context) ValidationErrors() ValidationErrors {
+	return c.validationErrs
 }
 
 func (c *Context) FormValue(key string) StringValue{
 	if err := c.Req.ParseForm(); err != nil {
 		return StringValue{err: err}
 	}
-	return StringValue{val: c.Req.FormValue(key)}
+	return StringValue{val: c.Req.FormValue(key), vals: c.Req.Form[key]}
 }
 
 func (c *Context) QueryValue(key string) StringValue {
@@ -56,7 +154,7 @@ func (c *Context) QueryValue(key string) StringValue {
 	if !ok {
 		return StringValue{err: errors.New("web: 找不到这个 key")}
 	}
-	return StringValue{val: vals[0]}
+	return StringValue{val: vals[0], vals: vals}
 }
 
 func (c *Context) PathValue(key string) StringValue {
@@ -71,6 +169,51 @@ func (c *Context) SetCookie(cookie *http.Cookie) {
 	http.SetCookie(c.Resp, cookie)
 }
 
+// Render 使用模板引擎渲染页面，结果会放在 RespData 里面
+// 模板引擎需要通过 ServerWithTemplateEngine 指定
+// 已经设置了 RespStatusCode 的时候会保留它，例如渲染 404 页面；渲染失败的时候响应码是 500，并且清空 RespData
+func (c *Context) Render(tplName string, data any) error {
+	if c.tplEngine == nil {
+		return errors.New("web: 没有设置模板引擎")
+	}
+	var err error
+	c.RespData, err = c.tplEngine.Render(c.Req.Context(), tplName, data)
+	if err != nil {
+		// 渲染了一半的页面不能发送给客户端
+		c.RespData = nil
+		c.RespStatusCode = http.StatusInternalServerError
+		return err
+	}
+	c.Resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if c.RespStatusCode == 0 {
+		c.RespStatusCode = http.StatusOK
+	}
+	return nil
+}
+
+// ClientIP 客户端的 IP
+// 默认使用 TCP 连接的对端地址。通过 ServerWithProxyHeaders 开启之后，
+// 会依次尝试 X-Forwarded-For 的第一个值和 X-Real-IP。
+// 头部的值不是合法的 IP 的时候会被忽略，避免客户端借此注入日志之类的数据
+func (c *Context) ClientIP() string {
+	if c.trustProxyHeaders {
+		if xff := c.Req.Header.Get("X-Forwarded-For"); xff != "" {
+			ip, _, _ := strings.Cut(xff, ",")
+			if ip = strings.TrimSpace(ip); net.ParseIP(ip) != nil {
+				return ip
+			}
+		}
+		if ip := strings.TrimSpace(c.Req.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(c.Req.RemoteAddr)
+	if err != nil {
+		return c.Req.RemoteAddr
+	}
+	return host
+}
+
 func (c *Context) RespJSONOK(val any) error {
 	return c.RespJSON(http.StatusOK, val)
 }
@@ -92,24 +235,3 @@ func (c *Context) RespJSON(code int, val any) error {
 // 	}
 // 	return strconv.ParseInt(val, 10, 64)
 // }
-
-type StringValue struct {
-	val string
-	err error
-}
-
-func (s StringValue) String() (string, error) {
-	return s.val, s.err
-}
-
-func (s StringValue) ToInt64() (int64, error) {
-	if s.err != nil {
-		return 0, s.err
-	}
-	return strconv.ParseInt(s.val, 10, 64)
-}
-
-// 不能用泛型
-// func (s StringValue) To[T any]() (T, error) {
-//
-// }
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext_ClientIP(t *testing.T) {
+	testCases := []struct {
+		name   string
+		trust  bool
+		header map[string]string
+		wantIP string
+	}{
+		{name: "remote addr", trust: true, wantIP: "10.0.0.1"},
+		{
+			name:   "x-forwarded-for",
+			trust:  true,
+			header: map[string]string{"X-Forwarded-For": " 1.1.1.1 , 2.2.2.2", "X-Real-IP": "3.3.3.3"},
+			wantIP: "1.1.1.1",
+		},
+		{
+			name:   "x-real-ip",
+			trust:  true,
+			header: map[string]string{"X-Real-IP": "3.3.3.3"},
+			wantIP: "3.3.3.3",
+		},
+		{
+			name:   "invalid x-forwarded-for",
+			trust:  true,
+			header: map[string]string{"X-Forwarded-For": `1.1.1.1" "fake`, "X-Real-IP": "::1"},
+			wantIP: "::1",
+		},
+		{
+			name:   "invalid x-real-ip",
+			trust:  true,
+			header: map[string]string{"X-Real-IP": "3.3.3.3 - admin"},
+			wantIP: "10.0.0.1",
+		},
+		{
+			// 默认不信任代理的头部
+			name:   "untrusted",
+			header: map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Real-IP": "3.3.3.3"},
+			wantIP: "10.0.0.1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			ctx := &Context{Req: req, trustProxyHeaders: tc.trust}
+			assert.Equal(t, tc.wantIP, ctx.ClientIP())
+		})
+	}
+}
+
+func TestServerWithProxyHeaders(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   []HTTPServerOption
+		wantIP string
+	}{
+		{name: "default", wantIP: "10.0.0.1"},
+		{name: "trust proxy headers", opts: []HTTPServerOption{ServerWithProxyHeaders()}, wantIP: "1.1.1.1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewHTTPServer(tc.opts...)
+			s.Get("/ip", func(ctx *Context) {
+				ctx.RespData = []byte(ctx.ClientIP())
+			})
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("X-Forwarded-For", "1.1.1.1")
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantIP, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package web
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errBodyTooLarge 是 http.MaxBytesReader 超过限制之后返回的错误信息
+// Go 1.19 之后可以使用 http.MaxBytesError
+const errBodyTooLarge = "http: request body too large"
+
+// ErrBodyTooLarge 请求体超过了大小限制，Bind 系列方法会返回这个错误
+var ErrBodyTooLarge = errors.New("web: 请求体超过了大小限制")
+
+// bodyErr 把读取请求体时超过大小限制的错误统一转化为 ErrBodyTooLarge
+// multipart 之类的解析会在错误信息前面加上自己的前缀，所以只能判断是否包含
+func bodyErr(err error) error {
+	if err != nil && (errors.Is(err, ErrBodyTooLarge) || strings.Contains(err.Error(), errBodyTooLarge)) {
+		return ErrBodyTooLarge
+	}
+	return err
+}
+
+// multipartOverhead 限制上传文件大小的时候，留给 multipart 边界和其它字段的空间
+const multipartOverhead = 1 << 20
+
+// FileUploader 处理文件上传
+// 例如：
+//
+//	u := &FileUploader{
+//		FileField: "file",
+//		MaxSize:   10 << 20,
+//		DstPathFunc: func(fh *multipart.FileHeader) string {
+//			return filepath.Join("upload", uuid.New().String()+filepath.Ext(fh.Filename))
+//		},
+//	}
+//	s.Post("/upload", u.Handle())
+type FileUploader struct {
+	// FileField 文件在表单中的字段名
+	FileField string
+	// MaxSize 文件的最大字节数，小于等于 0 代表不限制
+	MaxSize int64
+	// DstPathFunc 计算文件的保存路径，目录不存在的时候会被创建
+	// 不要直接使用用户上传的文件名，否则会有路径穿越的风险
+	DstPathFunc func(fh *multipart.FileHeader) string
+}
+
+// Handle 没有设置 DstPathFunc 的时候会 panic
+func (u *FileUploader) Handle() HandleFunc {
+	if u.DstPathFunc == nil {
+		panic("web: FileUploader 必须设置 DstPathFunc")
+	}
+	return func(ctx *Context) {
+		if u.MaxSize > 0 {
+			ctx.Req.Body = http.MaxBytesReader(ctx.Resp, ctx.Req.Body, u.MaxSize+multipartOverhead)
+		}
+		src, fh, err := ctx.Req.FormFile(u.FileField)
+		if err != nil {
+			if bodyErr(err) == ErrBodyTooLarge {
+				ctx.RespStatusCode = http.StatusRequestEntityTooLarge
+				ctx.RespData = []byte("上传失败，文件过大")
+				return
+			}
+			ctx.RespStatusCode = http.StatusBadRequest
+			ctx.RespData = []byte("上传失败，找不到文件")
+			return
+		}
+		defer src.Close()
+		if u.MaxSize > 0 && fh.Size > u.MaxSize {
+			ctx.RespStatusCode = http.StatusRequestEntityTooLarge
+			ctx.RespData = []byte("上传失败，文件过大")
+			return
+		}
+		if err = saveFile(src, u.DstPathFunc(fh)); err != nil {
+			ctx.RespStatusCode = http.StatusInternalServerError
+			ctx.RespData = []byte("上传失败")
+			return
+		}
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("上传成功")
+	}
+}
+
+func saveFile(src io.Reader, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// FileDownloader 处理文件下载
+// 需要注册在命名通配符路由上，例如 s.Get("/download/*file", d.Handle())
+// 那么 /download/a/b.txt 会下载 Dir 下的 a/b.txt
+type FileDownloader struct {
+	Dir string
+	// ParamName 文件路径对应的路径参数，默认是 file
+	ParamName string
+}
+
+func (d *FileDownloader) Handle() HandleFunc {
+	paramName := d.ParamName
+	if paramName == "" {
+		paramName = "file"
+	}
+	return func(ctx *Context) {
+		name, ok := cleanFilePath(ctx.PathParams[paramName])
+		if !ok {
+			ctx.RespStatusCode = http.StatusBadRequest
+			ctx.RespData = []byte("非法的文件路径")
+			return
+		}
+		f, err := os.Open(filepath.Join(d.Dir, filepath.FromSlash(name)))
+		if err != nil {
+			ctx.RespStatusCode = http.StatusNotFound
+			ctx.RespData = []byte("文件不存在")
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			ctx.RespStatusCode = http.StatusNotFound
+			ctx.RespData = []byte("文件不存在")
+			return
+		}
+		header := ctx.Resp.Header()
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment",
+			map[string]string{"filename": path.Base(name)}))
+		header.Set("Content-Type", "application/octet-stream")
+		header.Set("Content-Transfer-Encoding", "binary")
+		// 文件可能很大，所以直接写入响应，而不是放到 RespData
+		http.ServeContent(ctx.Resp, ctx.Req, info.Name(), info.ModTime(), f)
+		ctx.RespStatusCode = ctx.WrittenStatus()
+	}
+}
+
+// cleanFilePath 清理用户传入的文件路径，返回相对路径
+// 第二个返回值为 false 代表路径非法，例如试图通过 .. 访问上层目录
+func cleanFilePath(name string) (string, bool) {
+	if name == "" || strings.Contains(name, "\x00") || strings.Contains(name, "\\") {
+		return "", false
+	}
+	for _, seg := range strings.Split(name, "/") {
+		if seg == ".." {
+			return "", false
+		}
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if !fs.ValidPath(cleaned) || cleaned == "." {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// StaticResourceHandler 处理静态资源
+// 需要注册在命名通配符路由上，例如 s.Get("/static/*filepath", h.Handle)
+// 小文件会被缓存在内存中，淘汰策略是 LRU。文件的修改时间或者大小变化之后，缓存会失效
+type StaticResourceHandler struct {
+	fsys      fs.FS
+	paramName string
+	// extContentTypes 扩展名 => Content-Type，优先于 mime.TypeByExtension
+	extContentTypes map[string]string
+
+	cache *fileCache
+	// maxCacheFileSize 超过这个大小的文件不会被缓存，而是直接写入响应
+	maxCacheFileSize int
+}
+
+type StaticResourceHandlerOption func(h *StaticResourceHandler)
+
+// NewStaticResourceHandler fsys 可以是 os.DirFS(dir)，也可以是 embed.FS
+func NewStaticResourceHandler(fsys fs.FS, opts ...StaticResourceHandlerOption) *StaticResourceHandler {
+	h := &StaticResourceHandler{
+		fsys:             fsys,
+		paramName:        "filepath",
+		extContentTypes:  make(map[string]string, 8),
+		cache:            newFileCache(64 << 20),
+		maxCacheFileSize: 1 << 20,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// StaticWithParamName 文件路径对应的路径参数，默认是 filepath
+func StaticWithParamName(name string) StaticResourceHandlerOption {
+	return func(h *StaticResourceHandler) {
+		h.paramName = name
+	}
+}
+
+// StaticWithCache 设置缓存。maxFileSize 是单个文件的最大字节数，maxTotalSize 是缓存的最大字节数
+// maxTotalSize 小于等于 0 代表不使用缓存
+func StaticWithCache(maxFileSize int, maxTotalSize int) StaticResourceHandlerOption {
+	return func(h *StaticResourceHandler) {
+		h.maxCacheFileSize = maxFileSize
+		if maxTotalSize <= 0 {
+			h.cache = nil
+			return
+		}
+		h.cache = newFileCache(maxTotalSize)
+	}
+}
+
+// StaticWithExtension 指定扩展名对应的 Content-Type，例如 ".wasm" => "application/wasm"
+func StaticWithExtension(extMap map[string]string) StaticResourceHandlerOption {
+	return func(h *StaticResourceHandler) {
+		for ext, contentType := range extMap {
+			h.extContentTypes[ext] = contentType
+		}
+	}
+}
+
+func (h *StaticResourceHandler) Handle(ctx *Context) {
+	name, ok := cleanFilePath(ctx.PathParams[h.paramName])
+	if !ok {
+		ctx.RespStatusCode = http.StatusNotFound
+		return
+	}
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil || info.IsDir() {
+		ctx.RespStatusCode = http.StatusNotFound
+		return
+	}
+	if item, ok := h.cache.get(name); ok && item.valid(info) {
+		h.writeItem(ctx, item)
+		return
+	}
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		ctx.RespStatusCode = http.StatusNotFound
+		return
+	}
+	defer f.Close()
+
+	if h.cache == nil || info.Size() > int64(h.maxCacheFileSize) {
+		h.serveFile(ctx, name, info, f)
+		return
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		ctx.RespStatusCode = http.StatusInternalServerError
+		return
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write(data)
+	item := &fileCacheItem{
+		name:        name,
+		data:        data,
+		contentType: h.contentType(name),
+		etag:        fmt.Sprintf(`"%x"`, hash.Sum64()),
+		modTime:     info.ModTime(),
+		size:        info.Size(),
+	}
+	if len(data) <= h.maxCacheFileSize {
+		h.cache.set(item)
+	}
+	h.writeItem(ctx, item)
+}
+
+// serveFile 不经过缓存，直接把文件写入响应
+// 可以 Seek 的文件由 http.ServeContent 处理 Range 和 If-Modified-Since，否则一边读取一边写入
+func (h *StaticResourceHandler) serveFile(ctx *Context, name string, info fs.FileInfo, f fs.File) {
+	header := ctx.Resp.Header()
+	header.Set("Content-Type", h.contentType(name))
+	etag := fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+	header.Set("ETag", etag)
+	if rs, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(ctx.Resp, ctx.Req, name, info.ModTime(), rs)
+		ctx.RespStatusCode = ctx.WrittenStatus()
+		return
+	}
+	if !info.ModTime().IsZero() {
+		header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+	if notModified(ctx.Req, &fileCacheItem{etag: strings.TrimPrefix(etag, "W/"), modTime: info.ModTime()}) {
+		ctx.RespStatusCode = http.StatusNotModified
+		return
+	}
+	header.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	ctx.RespStatusCode = http.StatusOK
+	ctx.Resp.WriteHeader(http.StatusOK)
+	// 响应头部已经发送了，出错的时候只能中断响应
+	_, _ = io.Copy(ctx.Resp, f)
+}
+
+func (h *StaticResourceHandler) writeItem(ctx *Context, item *fileCacheItem) {
+	header := ctx.Resp.Header()
+	header.Set("ETag", item.etag)
+	if !item.modTime.IsZero() {
+		header.Set("Last-Modified", item.modTime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(ctx.Req, item) {
+		ctx.RespStatusCode = http.StatusNotModified
+		return
+	}
+	header.Set("Content-Type", item.contentType)
+	ctx.RespStatusCode = http.StatusOK
+	ctx.RespData = item.data
+}
+
+func notModified(req *http.Request, item *fileCacheItem) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, etag := range strings.Split(inm, ",") {
+			etag = strings.TrimSpace(etag)
+			if etag == "*" || strings.TrimPrefix(etag, "W/") == item.etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !item.modTime.IsZero() {
+		t, err := http.ParseTime(ims)
+		// HTTP 时间只精确到秒
+		return err == nil && !item.modTime.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+func (h *StaticResourceHandler) contentType(name string) string {
+	ext := path.Ext(name)
+	if contentType, ok := h.extContentTypes[ext]; ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+type fileCacheItem struct {
+	name        string
+	data        []byte
+	contentType string
+	etag        string
+	modTime     time.Time
+	size        int64
+}
+
+// valid 文件的修改时间和大小都没有变化的时候，缓存仍然有效
+func (i *fileCacheItem) valid(info fs.FileInfo) bool {
+	return i.size == info.Size() && i.modTime.Equal(info.ModTime())
+}
+
+// fileCache 按照总字节数限制大小的 LRU 缓存
+// 为 nil 的时候，所有的操作都是空操作
+type fileCache struct {
+	mutex   sync.Mutex
+	maxSize int
+	size    int
+	items   map[string]*list.Element
+	lru     *list.List
+}
+
+func newFileCache(maxSize int) *fileCache {
+	return &fileCache{
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element, 64),
+		lru:     list.New(),
+	}
+}
+
+func (c *fileCache) get(name string) (*fileCacheItem, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elem, ok := c.items[name]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*fileCacheItem), true
+}
+
+func (c *fileCache) set(item *fileCacheItem) {
+	if c == nil || len(item.data) > c.maxSize {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.items[item.name]; ok {
+		c.size -= len(elem.Value.(*fileCacheItem).data)
+		c.lru.Remove(elem)
+	}
+	c.items[item.name] = c.lru.PushFront(item)
+	c.size += len(item.data)
+	for c.size > c.maxSize {
+		oldest := c.lru.Back()
+		old := oldest.Value.(*fileCacheItem)
+		c.lru.Remove(oldest)
+		delete(c.items, old.name)
+		c.size -= len(old.data)
+	}
+}
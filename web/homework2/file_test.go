@@ -0,0 +1,295 @@
+package web
+
+import (
+	"bytes"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileUploader_Handle(t *testing.T) {
+	dir := t.TempDir()
+	s := NewHTTPServer()
+	u := &FileUploader{
+		FileField: "file",
+		MaxSize:   8,
+		DstPathFunc: func(fh *multipart.FileHeader) string {
+			return filepath.Join(dir, "upload", fh.Filename)
+		},
+	}
+	s.Post("/upload", u.Handle())
+
+	testCases := []struct {
+		name     string
+		field    string
+		content  string
+		wantCode int
+		wantFile string
+	}{
+		{
+			name:     "upload",
+			field:    "file",
+			content:  "hello",
+			wantCode: http.StatusOK,
+			wantFile: "hello",
+		},
+		{
+			name:     "too large",
+			field:    "file",
+			content:  "hello, world",
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "no file",
+			field:    "abc",
+			content:  "hello",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			fw, err := writer.CreateFormFile(tc.field, tc.name+".txt")
+			require.NoError(t, err)
+			_, err = fw.Write([]byte(tc.content))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+			req := httptest.NewRequest(http.MethodPost, "/upload", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantFile == "" {
+				return
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "upload", tc.name+".txt"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantFile, string(data))
+		})
+	}
+}
+
+func TestFileUploader_NoDstPathFunc(t *testing.T) {
+	u := &FileUploader{FileField: "file"}
+	assert.PanicsWithValue(t, "web: FileUploader 必须设置 DstPathFunc", func() {
+		u.Handle()
+	})
+}
+
+func TestFileDownloader_Handle(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a", "b.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(dir), "secret.txt"), []byte("secret"), 0o644))
+
+	s := NewHTTPServer()
+	s.Get("/download/*file", (&FileDownloader{Dir: dir}).Handle())
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "download",
+			path:     "/download/a/b.txt",
+			wantCode: http.StatusOK,
+			wantBody: "hello",
+		},
+		{
+			name:     "not found",
+			path:     "/download/a/c.txt",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "dir",
+			path:     "/download/a",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "path traversal",
+			path:     "/download/../secret.txt",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "encoded path traversal",
+			path:     "/download/a/%2e%2e/%2e%2e/secret.txt",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, `attachment; filename=b.txt`, recorder.Header().Get("Content-Disposition"))
+		})
+	}
+}
+
+func TestStaticResourceHandler_Handle(t *testing.T) {
+	modTime := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"css/app.css": {Data: []byte("body{}"), ModTime: modTime},
+		"app.wasm":    {Data: []byte("wasm"), ModTime: modTime},
+		"big.js":      {Data: bytes.Repeat([]byte("a"), 32), ModTime: modTime},
+	}
+	h := NewStaticResourceHandler(fsys,
+		StaticWithCache(16, 1024),
+		StaticWithExtension(map[string]string{".wasm": "application/wasm"}))
+	s := NewHTTPServer()
+	s.Get("/static/*filepath", h.Handle)
+
+	req := httptest.NewRequest(http.MethodGet, "/static/css/app.css", nil)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "body{}", recorder.Body.String())
+	assert.Equal(t, "text/css; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "Sat, 01 Oct 2022 00:00:00 GMT", recorder.Header().Get("Last-Modified"))
+	etag := recorder.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	_, ok := h.cache.get("css/app.css")
+	assert.True(t, ok)
+
+	// 修改时间和大小都没有变化的时候，依旧命中缓存
+	fsys["css/app.css"] = &fstest.MapFile{Data: []byte("BODY{}"), ModTime: modTime}
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/static/css/app.css", nil))
+	assert.Equal(t, "body{}", recorder.Body.String())
+	req = httptest.NewRequest(http.MethodGet, "/static/css/app.css", nil)
+	req.Header.Set("If-None-Match", etag)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	assert.Equal(t, 0, recorder.Body.Len())
+
+	req = httptest.NewRequest(http.MethodGet, "/static/css/app.css", nil)
+	req.Header.Set("If-Modified-Since", modTime.Format(http.TimeFormat))
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/static/app.wasm", nil)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, "application/wasm", recorder.Header().Get("Content-Type"))
+
+	// 大文件不缓存
+	req = httptest.NewRequest(http.MethodGet, "/static/big.js", nil)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 32, recorder.Body.Len())
+	_, ok = h.cache.get("big.js")
+	assert.False(t, ok)
+
+	req = httptest.NewRequest(http.MethodGet, "/static/../secret", nil)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// 文件被修改之后，缓存失效
+	fsys["css/app.css"] = &fstest.MapFile{Data: []byte("changed"), ModTime: modTime.Add(time.Hour)}
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/static/css/app.css", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "changed", recorder.Body.String())
+	assert.NotEqual(t, etag, recorder.Header().Get("ETag"))
+}
+
+func TestStaticResourceHandler_Stream(t *testing.T) {
+	modTime := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"big.js": {Data: bytes.Repeat([]byte("a"), 32), ModTime: modTime},
+	}
+	testCases := []struct {
+		name string
+		fsys fs.FS
+	}{
+		{name: "seeker", fsys: fsys},
+		{name: "not seeker", fsys: noSeekFS{fsys}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewStaticResourceHandler(tc.fsys, StaticWithCache(16, 1024))
+			var status int
+			s := NewHTTPServer()
+			s.Get("/static/*filepath", h.Handle, func(next HandleFunc) HandleFunc {
+				return func(ctx *Context) {
+					next(ctx)
+					status = ctx.RespStatusCode
+				}
+			})
+
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/static/big.js", nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, 32, recorder.Body.Len())
+			assert.Equal(t, "32", recorder.Header().Get("Content-Length"))
+			etag := recorder.Header().Get("ETag")
+			require.NotEmpty(t, etag)
+
+			req := httptest.NewRequest(http.MethodGet, "/static/big.js", nil)
+			req.Header.Set("If-None-Match", etag)
+			recorder = httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, http.StatusNotModified, recorder.Code)
+			assert.Equal(t, http.StatusNotModified, status)
+			assert.Equal(t, 0, recorder.Body.Len())
+		})
+	}
+}
+
+// noSeekFS 打开的文件不能 Seek，例如压缩包里面的文件
+type noSeekFS struct {
+	fsys fs.FS
+}
+
+func (n noSeekFS) Open(name string) (fs.File, error) {
+	f, err := n.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return noSeekFile{f}, nil
+}
+
+type noSeekFile struct {
+	fs.File
+}
+
+func Test_fileCache(t *testing.T) {
+	c := newFileCache(10)
+	c.set(&fileCacheItem{name: "a", data: []byte("12345")})
+	c.set(&fileCacheItem{name: "b", data: []byte("12345")})
+	_, ok := c.get("a")
+	assert.True(t, ok)
+	// 淘汰最久没有访问的 b
+	c.set(&fileCacheItem{name: "c", data: []byte("123")})
+	_, ok = c.get("b")
+	assert.False(t, ok)
+	_, ok = c.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 8, c.size)
+	// 超过总大小的不缓存
+	c.set(&fileCacheItem{name: "d", data: []byte("12345678901")})
+	_, ok = c.get("d")
+	assert.False(t, ok)
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RouteGroup 路由分组
+// 同一个分组下的路由共享同一个前缀和同一组 middleware。
+// 分组的 middleware 会附加在通过分组注册的每一个路由上，
+// 所以前缀相同、但是不是通过这个分组注册的路由，不会执行分组的 middleware。
+type RouteGroup struct {
+	s      *HTTPServer
+	parent *RouteGroup
+	prefix string
+	mdls   []Middleware
+}
+
+// Group 创建一个路由分组
+// prefix 必须以 / 开头，并且不能以 / 结尾，例如 /api/v1
+func (s *HTTPServer) Group(prefix string, mdls ...Middleware) *RouteGroup {
+	return newRouteGroup(s, nil, prefix, mdls)
+}
+
+func newRouteGroup(s *HTTPServer, parent *RouteGroup, prefix string, mdls []Middleware) *RouteGroup {
+	if prefix == "" || prefix[0] != '/' {
+		panic(fmt.Sprintf("web: 分组前缀必须以 / 开头 [%s]", prefix))
+	}
+	if prefix != "/" && prefix[len(prefix)-1] == '/' {
+		panic(fmt.Sprintf("web: 分组前缀不能以 / 结尾 [%s]", prefix))
+	}
+	if prefix == "/" {
+		prefix = ""
+	}
+	if parent != nil {
+		prefix = parent.prefix + prefix
+	}
+	return &RouteGroup{
+		s:      s,
+		parent: parent,
+		prefix: prefix,
+		mdls:   mdls,
+	}
+}
+
+// Group 在当前分组之下创建子分组
+// 子分组的前缀是当前分组前缀加上 prefix，
+// 并且会同时执行当前分组和子分组的 middleware
+func (g *RouteGroup) Group(prefix string, mdls ...Middleware) *RouteGroup {
+	return newRouteGroup(g.s, g, prefix, mdls)
+}
+
+func (g *RouteGroup) Get(path string, handler HandleFunc, mdls ...Middleware) {
+	g.addRoute(http.MethodGet, path, handler, mdls...)
+}
+
+func (g *RouteGroup) Post(path string, handler HandleFunc, mdls ...Middleware) {
+	g.addRoute(http.MethodPost, path, handler, mdls...)
+}
+
+func (g *RouteGroup) Put(path string, handler HandleFunc, mdls ...Middleware) {
+	g.addRoute(http.MethodPut, path, handler, mdls...)
+}
+
+func (g *RouteGroup) Delete(path string, handler HandleFunc, mdls ...Middleware) {
+	g.addRoute(http.MethodDelete, path, handler, mdls...)
+}
+
+func (g *RouteGroup) Patch(path string, handler HandleFunc, mdls ...Middleware) {
+	g.addRoute(http.MethodPatch, path, handler, mdls...)
+}
+
+// addRoute 注册分组下的路由
+// path 是相对于分组前缀的路径，/ 代表分组前缀本身
+func (g *RouteGroup) addRoute(method string, path string, handler HandleFunc, mdls ...Middleware) {
+	g.s.addGroupRoute(method, g.fullPath(path), handler, g.groupMdls(), mdls...)
+}
+
+// groupMdls 从最外层的分组到当前分组的所有 middleware，保证执行顺序是从外到内
+func (g *RouteGroup) groupMdls() []Middleware {
+	if g.parent == nil {
+		return g.mdls
+	}
+	parent := g.parent.groupMdls()
+	res := make([]Middleware, 0, len(parent)+len(g.mdls))
+	res = append(res, parent...)
+	return append(res, g.mdls...)
+}
+
+func (g *RouteGroup) fullPath(path string) string {
+	if path == "/" || path == "" {
+		if g.prefix == "" {
+			return "/"
+		}
+		return g.prefix
+	}
+	return g.prefix + path
+}
@@ -0,0 +1,144 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteGroup(t *testing.T) {
+	var mdlBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				ctx.RespData = append(ctx.RespData, i)
+				next(ctx)
+			}
+		}
+	}
+	handler := func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}
+
+	s := NewHTTPServer()
+	api := s.Group("/api", mdlBuilder('a'))
+	api.Get("/user", handler)
+	api.Post("/user", handler, mdlBuilder('u'))
+	api.Get("/user/:id", handler)
+	api.Get("/", handler)
+
+	v1 := api.Group("/v1", mdlBuilder('1'))
+	v1.Put("/order", handler)
+	v1.Delete("/order", handler)
+	v1.Patch("/order", handler)
+
+	s.Get("/home", handler)
+	// 前缀相同，但是不是通过分组注册的路由
+	s.Get("/api/public", handler)
+	other := s.Group("/api", mdlBuilder('b'))
+	other.Get("/order", handler)
+	root := s.Group("/", mdlBuilder('r'))
+	root.Get("/root", handler)
+
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+		wantResp string
+	}{
+		{
+			name:     "group route",
+			method:   http.MethodGet,
+			path:     "/api/user",
+			wantCode: http.StatusOK,
+			wantResp: "a",
+		},
+		{
+			name:     "group route with middleware",
+			method:   http.MethodPost,
+			path:     "/api/user",
+			wantCode: http.StatusOK,
+			wantResp: "au",
+		},
+		{
+			// 分组的 middleware 只作用于通过分组注册的路由，不会作用于子路由，所以只执行一次
+			name:     "group param route",
+			method:   http.MethodGet,
+			path:     "/api/user/123",
+			wantCode: http.StatusOK,
+			wantResp: "a",
+		},
+		{
+			name:     "group prefix",
+			method:   http.MethodGet,
+			path:     "/api",
+			wantCode: http.StatusOK,
+			wantResp: "a",
+		},
+		{
+			name:     "nested group put",
+			method:   http.MethodPut,
+			path:     "/api/v1/order",
+			wantCode: http.StatusOK,
+			wantResp: "a1",
+		},
+		{
+			name:     "nested group delete",
+			method:   http.MethodDelete,
+			path:     "/api/v1/order",
+			wantCode: http.StatusOK,
+			wantResp: "a1",
+		},
+		{
+			name:     "nested group patch",
+			method:   http.MethodPatch,
+			path:     "/api/v1/order",
+			wantCode: http.StatusOK,
+			wantResp: "a1",
+		},
+		{
+			name:     "outside group",
+			method:   http.MethodGet,
+			path:     "/home",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "same prefix outside group",
+			method:   http.MethodGet,
+			path:     "/api/public",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "another group with same prefix",
+			method:   http.MethodGet,
+			path:     "/api/order",
+			wantCode: http.StatusOK,
+			wantResp: "b",
+		},
+		{
+			name:     "root group",
+			method:   http.MethodGet,
+			path:     "/root",
+			wantCode: http.StatusOK,
+			wantResp: "r",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+
+	assert.PanicsWithValue(t, "web: 分组前缀必须以 / 开头 [api]", func() {
+		s.Group("api")
+	})
+	assert.PanicsWithValue(t, "web: 分组前缀不能以 / 结尾 [/api/]", func() {
+		s.Group("/api/")
+	})
+}
@@ -0,0 +1,17 @@
+// Package httputil 是 web 的 middleware 共享的辅助方法，仅限于内部使用
+package httputil
+
+import (
+	"math"
+	"time"
+)
+
+// Seconds 把 d 转化为 Retry-After 之类的头部使用的秒数
+// 向上取整，至少是 1 秒，避免客户端立刻重试
+func Seconds(d time.Duration) int {
+	s := int(math.Ceil(d.Seconds()))
+	if s < 1 {
+		return 1
+	}
+	return s
+}
@@ -0,0 +1,26 @@
+package httputil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeconds(t *testing.T) {
+	testCases := []struct {
+		name string
+		d    time.Duration
+		want int
+	}{
+		{name: "negative", d: -time.Second, want: 1},
+		{name: "zero", d: 0, want: 1},
+		{name: "round up", d: 1500 * time.Millisecond, want: 2},
+		{name: "exact", d: 3 * time.Second, want: 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Seconds(tc.d))
+		})
+	}
+}
@@ -1,13 +1,25 @@
 package accesslog
 
 import (
-	"encoding/json"
-	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
 	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
 )
 
+const headerRequestID = "X-Request-ID"
+
 type MiddlewareBuilder struct {
-	logFunc func(accessLog string)
+	logFunc   func(accessLog string)
+	formatter Formatter
+	// sink 非 nil 的时候，访问日志交给它处理，不再经过 formatter 和 logFunc
+	sink          func(ctx *web.Context, l *AccessLog)
+	sampleRate    float64
+	requestIDFunc func() string
 }
 
 func (b *MiddlewareBuilder) LogFunc(logFunc func(accessLog string)) *MiddlewareBuilder {
@@ -15,35 +27,130 @@ func (b *MiddlewareBuilder) LogFunc(logFunc func(accessLog string)) *MiddlewareB
 	return b
 }
 
+// Formatter 访问日志的格式，默认是 JSONFormatter
+func (b *MiddlewareBuilder) Formatter(f Formatter) *MiddlewareBuilder {
+	b.formatter = f
+	return b
+}
+
+// SampleRate 采样率，取值范围是 (0, 1]，默认是 1，也就是记录所有的请求
+// 响应码大于等于 500 的请求总是会被记录
+func (b *MiddlewareBuilder) SampleRate(rate float64) *MiddlewareBuilder {
+	b.sampleRate = rate
+	return b
+}
+
+// RequestIDFunc 请求没有携带 X-Request-ID 的时候，用于生成 request ID，默认是 UUID
+func (b *MiddlewareBuilder) RequestIDFunc(fn func() string) *MiddlewareBuilder {
+	b.requestIDFunc = fn
+	return b
+}
+
 func NewBuilder() *MiddlewareBuilder {
 	return &MiddlewareBuilder{
 		logFunc: func(accessLog string) {
 			log.Println(accessLog)
 		},
+		formatter:     JSONFormatter,
+		sampleRate:    1,
+		requestIDFunc: uuid.NewString,
 	}
 }
 
-type accessLog struct {
-	Host       string
-	Route      string
+// AccessLog 一次请求的访问日志
+// 输出 JSON 的时候，所有的 key 都使用下划线风格，和 Slog 输出的 attribute 保持一致
+type AccessLog struct {
+	Host       string `json:"host"`
+	Route      string `json:"route"`
 	HTTPMethod string `json:"http_method"`
-	Path       string
+	Path       string `json:"path"`
+
+	Time   time.Time `json:"time"`
+	Proto  string    `json:"proto"`
+	Query  string    `json:"query,omitempty"`
+	Status int       `json:"status"`
+	Size   int       `json:"size"`
+	// Latency 处理请求的耗时，单位是纳秒
+	Latency   time.Duration `json:"latency"`
+	ClientIP  string        `json:"client_ip"`
+	UserAgent string        `json:"user_agent,omitempty"`
+	Referer   string        `json:"referer,omitempty"`
+	RequestID string        `json:"request_id"`
 }
 
 func (b *MiddlewareBuilder) Build() web.Middleware {
 	return func(next web.HandleFunc) web.HandleFunc {
 		return func(ctx *web.Context) {
+			ctx.RequestID = b.requestID(ctx.Req)
+			ctx.Resp.Header().Set(headerRequestID, ctx.RequestID)
+			start := time.Now()
 			defer func() {
-				l := accessLog{
+				l := &AccessLog{
 					Host:       ctx.Req.Host,
 					Route:      ctx.MatchedRoute,
 					Path:       ctx.Req.URL.Path,
 					HTTPMethod: ctx.Req.Method,
+					Time:       start,
+					Proto:      ctx.Req.Proto,
+					Query:      ctx.Req.URL.RawQuery,
+					Status:     ctx.WrittenStatus(),
+					Size:       ctx.RespSize(),
+					Latency:    time.Since(start),
+					ClientIP:   ctx.ClientIP(),
+					UserAgent:  ctx.Req.UserAgent(),
+					Referer:    ctx.Req.Referer(),
+					RequestID:  ctx.RequestID,
 				}
-				val, _ := json.Marshal(l)
-				b.logFunc(string(val))
+				if l.Status == 0 {
+					// 还没有写入响应，RespStatusCode 会在最后被回写
+					l.Status = ctx.RespStatusCode
+				}
+				if l.Status == 0 {
+					// 没有设置响应码的时候，net/http 默认返回 200
+					l.Status = http.StatusOK
+				}
+				if !b.sampled(l) {
+					return
+				}
+				if b.sink != nil {
+					b.sink(ctx, l)
+					return
+				}
+				b.logFunc(b.formatter(l))
 			}()
 			next(ctx)
 		}
 	}
 }
+
+// requestID 优先使用请求携带的 X-Request-ID，不合法的时候重新生成
+func (b *MiddlewareBuilder) requestID(req *http.Request) string {
+	id := req.Header.Get(headerRequestID)
+	if validRequestID(id) {
+		return id
+	}
+	return b.requestIDFunc()
+}
+
+// validRequestID 避免客户端通过 request ID 注入日志
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (b *MiddlewareBuilder) sampled(l *AccessLog) bool {
+	if l.Status >= http.StatusInternalServerError || b.sampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < b.sampleRate
+}
@@ -0,0 +1,153 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+func newServer(b *MiddlewareBuilder) *web.HTTPServer {
+	s := web.NewHTTPServer()
+	s.Use(b.Build())
+	s.Get("/user/:id", func(ctx *web.Context) {
+		ctx.RespData = []byte("hello, " + ctx.RequestID)
+	})
+	s.Get("/error", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusInternalServerError
+	})
+	s.Get("/direct", func(ctx *web.Context) {
+		// 直接写入 Resp，RespStatusCode 不会生效
+		ctx.RespStatusCode = http.StatusOK
+		ctx.Resp.WriteHeader(http.StatusNotModified)
+	})
+	return s
+}
+
+func TestMiddlewareBuilder_JSON(t *testing.T) {
+	var logs []string
+	b := NewBuilder().
+		LogFunc(func(accessLog string) { logs = append(logs, accessLog) }).
+		RequestIDFunc(func() string { return "generated-id" })
+	s := newServer(b)
+
+	testCases := []struct {
+		name          string
+		reqID         string
+		wantRequestID string
+	}{
+		{name: "generate", wantRequestID: "generated-id"},
+		{name: "from header", reqID: "abc-123", wantRequestID: "abc-123"},
+		{name: "invalid header", reqID: "abc\n123", wantRequestID: "generated-id"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs = nil
+			req := httptest.NewRequest(http.MethodGet, "/user/1?name=Tom", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("User-Agent", "test-agent")
+			if tc.reqID != "" {
+				req.Header.Set(headerRequestID, tc.reqID)
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantRequestID, recorder.Header().Get(headerRequestID))
+			assert.Equal(t, "hello, "+tc.wantRequestID, recorder.Body.String())
+
+			require.Len(t, logs, 1)
+			var keys map[string]any
+			require.NoError(t, json.Unmarshal([]byte(logs[0]), &keys))
+			for _, key := range []string{"host", "route", "http_method", "path", "client_ip", "request_id"} {
+				assert.Contains(t, keys, key)
+			}
+			l := &AccessLog{}
+			require.NoError(t, json.Unmarshal([]byte(logs[0]), l))
+			assert.Greater(t, l.Latency.Nanoseconds(), int64(0))
+			assert.False(t, l.Time.IsZero())
+			l.Latency = 0
+			assert.Equal(t, &AccessLog{
+				Host:       "example.com",
+				Route:      "/user/:id",
+				HTTPMethod: http.MethodGet,
+				Path:       "/user/1",
+				Time:       l.Time,
+				Proto:      "HTTP/1.1",
+				Query:      "name=Tom",
+				Status:     http.StatusOK,
+				Size:       len("hello, " + tc.wantRequestID),
+				ClientIP:   "10.0.0.1",
+				UserAgent:  "test-agent",
+				RequestID:  tc.wantRequestID,
+			}, l)
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Formatter(t *testing.T) {
+	testCases := []struct {
+		name      string
+		formatter Formatter
+		wantLog   *regexp.Regexp
+	}{
+		{
+			name:      "common",
+			formatter: CommonFormatter,
+			wantLog: regexp.MustCompile(`^10\.0\.0\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] ` +
+				`"GET /user/1\?name=Tom HTTP/1\.1" 200 9$`),
+		},
+		{
+			name:      "combined",
+			formatter: CombinedFormatter,
+			wantLog: regexp.MustCompile(`^10\.0\.0\.1 - - \[.+\] ` +
+				`"GET /user/1\?name=Tom HTTP/1\.1" 200 9 "-" "test \\"agent\\""$`),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs []string
+			b := NewBuilder().Formatter(tc.formatter).
+				LogFunc(func(accessLog string) { logs = append(logs, accessLog) }).
+				RequestIDFunc(func() string { return "id" })
+			s := newServer(b)
+			req := httptest.NewRequest(http.MethodGet, "/user/1?name=Tom", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("User-Agent", `test "agent"`)
+			s.ServeHTTP(httptest.NewRecorder(), req)
+			require.Len(t, logs, 1)
+			assert.Regexp(t, tc.wantLog, logs[0])
+		})
+	}
+}
+
+func TestMiddlewareBuilder_SampleRate(t *testing.T) {
+	var logs []string
+	b := NewBuilder().SampleRate(0).
+		LogFunc(func(accessLog string) { logs = append(logs, accessLog) })
+	s := newServer(b)
+	for i := 0; i < 10; i++ {
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/1", nil))
+	}
+	assert.Empty(t, logs)
+	// 服务端错误总是会被记录
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/error", nil))
+	assert.Len(t, logs, 1)
+}
+
+func TestMiddlewareBuilder_WrittenStatus(t *testing.T) {
+	var logs []string
+	b := NewBuilder().LogFunc(func(accessLog string) { logs = append(logs, accessLog) })
+	s := newServer(b)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/direct", nil))
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	require.Len(t, logs, 1)
+	l := &AccessLog{}
+	require.NoError(t, json.Unmarshal([]byte(logs[0]), l))
+	assert.Equal(t, http.StatusNotModified, l.Status)
+}
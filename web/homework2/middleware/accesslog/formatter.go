@@ -0,0 +1,66 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Formatter 把访问日志格式化为一行
+type Formatter func(l *AccessLog) string
+
+// clfTimeLayout Common Log Format 中的时间格式
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// JSONFormatter 以 JSON 格式输出，这是默认的格式
+func JSONFormatter(l *AccessLog) string {
+	val, _ := json.Marshal(l)
+	return string(val)
+}
+
+// CommonFormatter 以 Common Log Format 输出，例如：
+// 127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+func CommonFormatter(l *AccessLog) string {
+	var sb strings.Builder
+	writeCommon(&sb, l)
+	return sb.String()
+}
+
+// CombinedFormatter 以 Combined Log Format 输出，也就是在 Common Log Format 的基础上增加 Referer 和 User-Agent
+func CombinedFormatter(l *AccessLog) string {
+	var sb strings.Builder
+	writeCommon(&sb, l)
+	sb.WriteString(" ")
+	sb.WriteString(quote(l.Referer))
+	sb.WriteString(" ")
+	sb.WriteString(quote(l.UserAgent))
+	return sb.String()
+}
+
+func writeCommon(sb *strings.Builder, l *AccessLog) {
+	uri := l.Path
+	if l.Query != "" {
+		uri += "?" + l.Query
+	}
+	sb.WriteString(l.ClientIP)
+	sb.WriteString(" - - [")
+	sb.WriteString(l.Time.Format(clfTimeLayout))
+	sb.WriteString("] ")
+	sb.WriteString(quote(l.HTTPMethod + " " + uri + " " + l.Proto))
+	sb.WriteString(" ")
+	sb.WriteString(strconv.Itoa(l.Status))
+	sb.WriteString(" ")
+	if l.Size == 0 {
+		sb.WriteString("-")
+	} else {
+		sb.WriteString(strconv.Itoa(l.Size))
+	}
+}
+
+// quote 空值输出 "-"，并且转义引号，避免破坏日志的格式
+func quote(val string) string {
+	if val == "" {
+		return `"-"`
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(val) + `"`
+}
@@ -0,0 +1,45 @@
+//go:build go1.21
+
+// log/slog 是 Go 1.21 才加入标准库的，所以这个文件只会在 Go 1.21 及以上的版本编译。
+// go.mod 声明的版本是 1.18，使用更早的 Go 版本编译的时候没有 Slog 和 Attrs，
+// 此时可以使用 JSONFormatter 输出同样的字段。
+
+package accesslog
+
+import (
+	"log/slog"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// Slog 使用 slog 输出结构化的访问日志，每一个字段都是一个 attribute
+// 设置了之后，Formatter 和 LogFunc 都不再生效。需要 Go 1.21 及以上的版本
+func (b *MiddlewareBuilder) Slog(logger *slog.Logger) *MiddlewareBuilder {
+	b.sink = func(ctx *web.Context, l *AccessLog) {
+		level := slog.LevelInfo
+		if l.Status >= 500 {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(ctx.Req.Context(), level, "access", Attrs(l)...)
+	}
+	return b
+}
+
+// Attrs 把访问日志转化为 slog 的 attribute
+func Attrs(l *AccessLog) []slog.Attr {
+	return []slog.Attr{
+		slog.String("request_id", l.RequestID),
+		slog.String("host", l.Host),
+		slog.String("route", l.Route),
+		slog.String("http_method", l.HTTPMethod),
+		slog.String("path", l.Path),
+		slog.String("query", l.Query),
+		slog.String("proto", l.Proto),
+		slog.Int("status", l.Status),
+		slog.Int("size", l.Size),
+		slog.Duration("latency", l.Latency),
+		slog.String("client_ip", l.ClientIP),
+		slog.String("user_agent", l.UserAgent),
+		slog.String("referer", l.Referer),
+	}
+}
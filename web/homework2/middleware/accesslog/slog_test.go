@@ -0,0 +1,32 @@
+//go:build go1.21
+
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareBuilder_Slog(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buffer, nil))
+	s := newServer(NewBuilder().Slog(logger))
+	req := httptest.NewRequest(http.MethodGet, "/error", nil)
+	req.Header.Set(headerRequestID, "abc")
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	record := map[string]any{}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &record))
+	assert.Equal(t, "ERROR", record["level"])
+	assert.Equal(t, "access", record["msg"])
+	assert.Equal(t, "abc", record["request_id"])
+	assert.Equal(t, "/error", record["route"])
+	assert.Equal(t, float64(http.StatusInternalServerError), record["status"])
+}
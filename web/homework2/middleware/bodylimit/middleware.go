@@ -0,0 +1,77 @@
+package bodylimit
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// MiddlewareBuilder 限制请求体的大小
+// Content-Length 超过限制的请求直接返回 413；
+// 没有 Content-Length 的请求，读取超过限制之后，Bind 系列方法会返回 web.ErrBodyTooLarge，
+// 并且不管 handler 怎么处理这个错误，最终的响应都是 413
+type MiddlewareBuilder struct {
+	maxBytes int64
+	message  []byte
+}
+
+func NewMiddlewareBuilder(maxBytes int64) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		maxBytes: maxBytes,
+		message:  []byte("请求体太大"),
+	}
+}
+
+// Message 超过限制的时候的响应数据
+func (b *MiddlewareBuilder) Message(msg string) *MiddlewareBuilder {
+	b.message = []byte(msg)
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			if ctx.Req.ContentLength > b.maxBytes {
+				b.reject(ctx)
+				return
+			}
+			if ctx.Req.Body == nil || ctx.Req.Body == http.NoBody {
+				next(ctx)
+				return
+			}
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(ctx.Resp, ctx.Req.Body, b.maxBytes)}
+			ctx.Req.Body = body
+			next(ctx)
+			if atomic.LoadInt32(&body.exceeded) != 0 && !ctx.Streaming() {
+				b.reject(ctx)
+			}
+		}
+	}
+}
+
+func (b *MiddlewareBuilder) reject(ctx *web.Context) {
+	// 请求体没有读完，连接不能复用
+	ctx.Resp.Header().Set("Connection", "close")
+	ctx.RespStatusCode = http.StatusRequestEntityTooLarge
+	ctx.RespData = b.message
+}
+
+// limitedBody 把 http.MaxBytesReader 的错误转化为 web.ErrBodyTooLarge，并且记录下来
+type limitedBody struct {
+	io.ReadCloser
+	exceeded int32
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	n, err := l.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		// Go 1.19 之后可以使用 http.MaxBytesError 判断
+		if err.Error() == "http: request body too large" {
+			atomic.StoreInt32(&l.exceeded, 1)
+			err = web.ErrBodyTooLarge
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,76 @@
+package bodylimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	var bindErr error
+	s := web.NewHTTPServer()
+	s.Use(NewMiddlewareBuilder(16).Build())
+	s.Post("/user", func(ctx *web.Context) {
+		val := map[string]any{}
+		bindErr = ctx.BindJSON(&val)
+		if bindErr != nil {
+			ctx.RespStatusCode = http.StatusBadRequest
+			ctx.RespData = []byte(bindErr.Error())
+			return
+		}
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("ok")
+	})
+
+	testCases := []struct {
+		name string
+		body string
+		// chunked 不设置 Content-Length
+		chunked  bool
+		wantCode int
+		wantBody string
+		wantErr  error
+	}{
+		{name: "small", body: `{"a":1}`, wantCode: http.StatusOK, wantBody: "ok"},
+		{
+			name:     "content length too large",
+			body:     `{"name":"a very long name"}`,
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: "请求体太大",
+		},
+		{
+			name:     "chunked too large",
+			body:     `{"name":"a very long name"}`,
+			chunked:  true,
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: "请求体太大",
+			wantErr:  web.ErrBodyTooLarge,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bindErr = nil
+			var body io.Reader = strings.NewReader(tc.body)
+			if tc.chunked {
+				// 隐藏 Len 方法，httptest 就不会设置 Content-Length
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/user", body)
+			if tc.chunked {
+				req.ContentLength = -1
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.True(t, errors.Is(bindErr, tc.wantErr), "%v", bindErr)
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State 熔断器的状态
+type State int32
+
+const (
+	// StateClosed 正常放行，统计滑动窗口内的错误率和慢调用比例
+	StateClosed State = iota
+	// StateOpen 拒绝所有请求，直到 OpenTimeout 之后进入半开状态
+	StateOpen
+	// StateHalfOpen 放行少量探测请求，全部成功则关闭，任何一个失败则重新打开
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// stateChange 状态变更，ok 为 false 代表状态没有变化
+type stateChange struct {
+	from State
+	to   State
+	ok   bool
+}
+
+// ticket 放行的请求的凭证
+// 状态每变化一次 gen 就加一，之前的状态下放行的请求，它们的结果会被忽略
+type ticket struct {
+	gen   uint64
+	probe bool
+}
+
+// bucket 滑动窗口中的一个时间片
+type bucket struct {
+	// start 时间片的开始时间，单位是纳秒
+	start    int64
+	total    int
+	failures int
+	slow     int
+}
+
+// breaker 单个路由的熔断器
+type breaker struct {
+	b *MiddlewareBuilder
+
+	mutex    sync.Mutex
+	state    State
+	gen      uint64
+	openedAt time.Time
+	// probes 半开状态下已经放行的探测请求，successes 其中成功的数量
+	probes    int
+	successes int
+	buckets   []bucket
+}
+
+func newBreaker(b *MiddlewareBuilder) *breaker {
+	return &breaker{b: b, buckets: make([]bucket, b.buckets)}
+}
+
+// allow 判断请求是否可以放行
+func (br *breaker) allow(now time.Time) (ticket, stateChange, bool) {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+	var change stateChange
+	switch br.state {
+	case StateOpen:
+		if now.Sub(br.openedAt) < br.b.openTimeout {
+			return ticket{}, change, false
+		}
+		change = br.setState(StateHalfOpen, now)
+		fallthrough
+	case StateHalfOpen:
+		if br.probes >= br.b.halfOpenRequests {
+			return ticket{}, change, false
+		}
+		br.probes++
+		return ticket{gen: br.gen, probe: true}, change, true
+	}
+	return ticket{gen: br.gen}, change, true
+}
+
+// cancel 放行的请求最终没有执行，例如被 bulkhead 拒绝了
+func (br *breaker) cancel(t ticket) {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+	if t.probe && t.gen == br.gen {
+		br.probes--
+	}
+}
+
+// record 记录请求的结果
+func (br *breaker) record(t ticket, now time.Time, failed bool, slow bool) stateChange {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+	if t.gen != br.gen {
+		return stateChange{}
+	}
+	if br.state == StateHalfOpen {
+		if failed || slow {
+			return br.setState(StateOpen, now)
+		}
+		br.successes++
+		if br.successes >= br.b.halfOpenRequests {
+			return br.setState(StateClosed, now)
+		}
+		return stateChange{}
+	}
+
+	bk := br.bucket(now)
+	bk.total++
+	if failed {
+		bk.failures++
+	}
+	if slow {
+		bk.slow++
+	}
+	total, failures, slows := br.sum(now)
+	if total < br.b.minRequests {
+		return stateChange{}
+	}
+	if (br.b.errorRate > 0 && float64(failures) >= br.b.errorRate*float64(total)) ||
+		(br.b.slowThreshold > 0 && float64(slows) >= br.b.slowRate*float64(total)) {
+		return br.setState(StateOpen, now)
+	}
+	return stateChange{}
+}
+
+// retryAfter 打开状态下，距离进入半开状态的时间
+func (br *breaker) retryAfter(now time.Time) time.Duration {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+	if br.state != StateOpen {
+		return 0
+	}
+	return br.b.openTimeout - now.Sub(br.openedAt)
+}
+
+func (br *breaker) currentState() State {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+	return br.state
+}
+
+func (br *breaker) setState(to State, now time.Time) stateChange {
+	change := stateChange{from: br.state, to: to, ok: true}
+	br.state = to
+	br.gen++
+	br.probes, br.successes = 0, 0
+	switch to {
+	case StateOpen:
+		br.openedAt = now
+	case StateClosed:
+		// 重新开始统计，避免打开之前的数据再次触发熔断
+		for i := range br.buckets {
+			br.buckets[i] = bucket{}
+		}
+	}
+	return change
+}
+
+// bucket 返回 now 所在的时间片，过期的时间片会被重置
+func (br *breaker) bucket(now time.Time) *bucket {
+	size := int64(br.b.window) / int64(len(br.buckets))
+	nanos := now.UnixNano()
+	start := nanos - nanos%size
+	bk := &br.buckets[(nanos/size)%int64(len(br.buckets))]
+	if bk.start != start {
+		*bk = bucket{start: start}
+	}
+	return bk
+}
+
+// sum 统计滑动窗口内的请求
+func (br *breaker) sum(now time.Time) (total int, failures int, slows int) {
+	from := now.UnixNano() - int64(br.b.window)
+	for _, bk := range br.buckets {
+		if bk.start > from {
+			total += bk.total
+			failures += bk.failures
+			slows += bk.slow
+		}
+	}
+	return
+}
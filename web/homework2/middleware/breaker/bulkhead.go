@@ -0,0 +1,61 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var errBulkheadFull = errors.New("breaker: 并发数已满")
+
+// bulkhead 限制单个路由的并发数，避免下游变慢的时候 goroutine 堆积
+// 并发数满了之后，请求会排队等待，队列也满了或者等待超时就拒绝
+type bulkhead struct {
+	sem      chan struct{}
+	maxQueue int64
+	maxWait  time.Duration
+	// waiting 正在排队的请求数量
+	waiting int64
+}
+
+func newBulkhead(maxConcurrent int, maxQueue int, maxWait time.Duration) *bulkhead {
+	return &bulkhead{
+		sem:      make(chan struct{}, maxConcurrent),
+		maxQueue: int64(maxQueue),
+		maxWait:  maxWait,
+	}
+}
+
+// acquire 获取执行的许可，成功之后需要调用 release
+func (bh *bulkhead) acquire(ctx context.Context) error {
+	select {
+	case bh.sem <- struct{}{}:
+		return nil
+	default:
+	}
+	if atomic.AddInt64(&bh.waiting, 1) > bh.maxQueue {
+		atomic.AddInt64(&bh.waiting, -1)
+		return errBulkheadFull
+	}
+	defer atomic.AddInt64(&bh.waiting, -1)
+
+	var timeout <-chan time.Time
+	if bh.maxWait > 0 {
+		timer := time.NewTimer(bh.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case bh.sem <- struct{}{}:
+		return nil
+	case <-timeout:
+		return errBulkheadFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (bh *bulkhead) release() {
+	<-bh.sem
+}
@@ -0,0 +1,275 @@
+package breaker
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/internal/httputil"
+)
+
+// MiddlewareBuilder 按照路由熔断和限制并发
+// 熔断器统计滑动窗口内的错误率和慢调用比例，超过阈值之后打开，拒绝所有请求；
+// OpenTimeout 之后进入半开状态，放行少量探测请求，全部成功之后关闭，任何一个失败就重新打开。
+// bulkhead 限制每个路由同时执行的请求数量，超过之后排队，队列满了就拒绝。
+// 应该注册在路由或者分组上，这样才能拿到命中的路由，没有命中路由的请求会直接放行
+type MiddlewareBuilder struct {
+	keyFunc     func(ctx *web.Context) string
+	failureFunc func(ctx *web.Context) bool
+	onChange    func(key string, from State, to State)
+
+	window           time.Duration
+	buckets          int
+	minRequests      int
+	errorRate        float64
+	slowThreshold    time.Duration
+	slowRate         float64
+	openTimeout      time.Duration
+	halfOpenRequests int
+
+	maxConcurrent int
+	maxQueue      int
+	maxWait       time.Duration
+	maxKeys       int
+
+	// entries key => *entry，只在读取的时候不加锁，创建和清理都需要持有 mutex
+	entries sync.Map
+	mutex   sync.Mutex
+	size    int
+	now     func() time.Time
+}
+
+type entry struct {
+	breaker  *breaker
+	bulkhead *bulkhead
+	// lastUsed 最近一次请求的时间，单位是纳秒
+	lastUsed int64
+	// inflight 正在执行的请求数量
+	inflight int64
+}
+
+// NewMiddlewareBuilder 默认统计最近 10 秒，至少 20 个请求并且一半失败的时候熔断，5 秒之后尝试恢复
+// 默认响应码大于等于 500 的请求是失败的，不限制并发
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		keyFunc: func(ctx *web.Context) string {
+			return ctx.Req.Method + " " + ctx.MatchedRoute
+		},
+		failureFunc: func(ctx *web.Context) bool {
+			return ctx.RespStatusCode >= http.StatusInternalServerError
+		},
+		window:           10 * time.Second,
+		buckets:          10,
+		minRequests:      20,
+		errorRate:        0.5,
+		openTimeout:      5 * time.Second,
+		halfOpenRequests: 5,
+		maxKeys:          1024,
+		now:              time.Now,
+	}
+}
+
+// KeyFunc 区分熔断器的 key，默认是 HTTP 方法加上命中的路由
+// 每一个 key 都有自己的熔断器，所以 key 的数量必须是有限的，不要使用请求的路径或者用户 ID 之类的数据
+func (b *MiddlewareBuilder) KeyFunc(fn func(ctx *web.Context) string) *MiddlewareBuilder {
+	b.keyFunc = fn
+	return b
+}
+
+// FailureFunc 判断请求是否失败，在 handler 返回之后调用，panic 的请求总是失败的
+func (b *MiddlewareBuilder) FailureFunc(fn func(ctx *web.Context) bool) *MiddlewareBuilder {
+	b.failureFunc = fn
+	return b
+}
+
+// OnStateChange 熔断器状态变化的时候回调，例如发送告警
+// 回调的时候没有持有锁，但是它在请求的 goroutine 里面执行，不应该阻塞太久
+func (b *MiddlewareBuilder) OnStateChange(fn func(key string, from State, to State)) *MiddlewareBuilder {
+	b.onChange = fn
+	return b
+}
+
+// Window 滑动窗口的长度，以及它被分成多少个时间片
+// 时间片越多统计越精确，但是每次请求统计的开销也越大
+func (b *MiddlewareBuilder) Window(window time.Duration, buckets int) *MiddlewareBuilder {
+	b.window, b.buckets = window, buckets
+	return b
+}
+
+// MinRequests 滑动窗口内至少要有多少个请求才会计算错误率，避免请求很少的时候误判
+func (b *MiddlewareBuilder) MinRequests(n int) *MiddlewareBuilder {
+	b.minRequests = n
+	return b
+}
+
+// ErrorRate 错误率达到 rate 的时候熔断，rate 为 0 的时候不按照错误率熔断
+func (b *MiddlewareBuilder) ErrorRate(rate float64) *MiddlewareBuilder {
+	b.errorRate = rate
+	return b
+}
+
+// SlowCall 耗时达到 threshold 的请求是慢调用，慢调用的比例达到 rate 的时候熔断
+// threshold 为 0 的时候不统计慢调用，这也是默认值；统计慢调用的时候 rate 必须在 (0, 1] 之间
+func (b *MiddlewareBuilder) SlowCall(threshold time.Duration, rate float64) *MiddlewareBuilder {
+	b.slowThreshold, b.slowRate = threshold, rate
+	return b
+}
+
+// OpenTimeout 熔断之后经过多久进入半开状态
+func (b *MiddlewareBuilder) OpenTimeout(d time.Duration) *MiddlewareBuilder {
+	b.openTimeout = d
+	return b
+}
+
+// HalfOpenRequests 半开状态下放行的探测请求数量，它们全部成功之后熔断器才会关闭
+func (b *MiddlewareBuilder) HalfOpenRequests(n int) *MiddlewareBuilder {
+	b.halfOpenRequests = n
+	return b
+}
+
+// Bulkhead 每个路由最多同时执行 maxConcurrent 个请求，最多 maxQueue 个请求排队，
+// 排队超过 maxWait 的请求会被拒绝，maxWait 为 0 代表一直等到请求被取消
+func (b *MiddlewareBuilder) Bulkhead(maxConcurrent int, maxQueue int, maxWait time.Duration) *MiddlewareBuilder {
+	b.maxConcurrent, b.maxQueue, b.maxWait = maxConcurrent, maxQueue, maxWait
+	return b
+}
+
+// MaxKeys 最多同时记录多少个 key，默认是 1024
+// 达到上限的时候会清理空闲的 key，清理之后仍然没有空间的话，新的 key 的请求不经过熔断器直接放行
+func (b *MiddlewareBuilder) MaxKeys(n int) *MiddlewareBuilder {
+	b.maxKeys = n
+	return b
+}
+
+// State 返回 key 对应的熔断器的状态，还没有请求的 key 是关闭的
+func (b *MiddlewareBuilder) State(key string) State {
+	val, ok := b.entries.Load(key)
+	if !ok {
+		return StateClosed
+	}
+	return val.(*entry).breaker.currentState()
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	if b.window <= 0 || b.buckets <= 0 || int64(b.window) < int64(b.buckets) {
+		panic("breaker: 非法的滑动窗口")
+	}
+	if b.halfOpenRequests <= 0 {
+		panic("breaker: 半开状态至少要放行一个请求")
+	}
+	if b.maxKeys <= 0 {
+		panic("breaker: MaxKeys 必须大于 0")
+	}
+	// rate 为 0 的时候，任何请求都会让熔断器打开
+	if b.slowThreshold > 0 && (b.slowRate <= 0 || b.slowRate > 1) {
+		panic("breaker: 慢调用比例必须在 (0, 1] 之间")
+	}
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			if ctx.MatchedRoute == "" {
+				next(ctx)
+				return
+			}
+			now := b.now()
+			key := b.keyFunc(ctx)
+			e := b.entry(key, now)
+			if e == nil {
+				next(ctx)
+				return
+			}
+			atomic.AddInt64(&e.inflight, 1)
+			defer atomic.AddInt64(&e.inflight, -1)
+			atomic.StoreInt64(&e.lastUsed, now.UnixNano())
+
+			t, change, ok := e.breaker.allow(now)
+			b.notify(key, change)
+			if !ok {
+				retry := e.breaker.retryAfter(now)
+				ctx.Resp.Header().Set("Retry-After", strconv.Itoa(httputil.Seconds(retry)))
+				b.reject(ctx)
+				return
+			}
+			if e.bulkhead != nil {
+				if err := e.bulkhead.acquire(ctx.Req.Context()); err != nil {
+					// 没有执行的请求不计入熔断器的统计
+					e.breaker.cancel(t)
+					// 排队的请求最多等待 maxWait，所以建议客户端在这之后重试
+					ctx.Resp.Header().Set("Retry-After", strconv.Itoa(httputil.Seconds(b.maxWait)))
+					b.reject(ctx)
+					return
+				}
+				defer e.bulkhead.release()
+			}
+
+			start := b.now()
+			defer func() {
+				if p := recover(); p != nil {
+					b.record(key, e, t, start, true)
+					panic(p)
+				}
+			}()
+			next(ctx)
+			b.record(key, e, t, start, b.failureFunc(ctx))
+		}
+	}
+}
+
+// entry 返回 key 对应的熔断器，key 的数量达到上限的时候返回 nil
+func (b *MiddlewareBuilder) entry(key string, now time.Time) *entry {
+	if val, ok := b.entries.Load(key); ok {
+		return val.(*entry)
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if val, ok := b.entries.Load(key); ok {
+		return val.(*entry)
+	}
+	if b.size >= b.maxKeys {
+		b.sweep(now)
+		if b.size >= b.maxKeys {
+			return nil
+		}
+	}
+	e := &entry{breaker: newBreaker(b), lastUsed: now.UnixNano()}
+	if b.maxConcurrent > 0 {
+		e.bulkhead = newBulkhead(b.maxConcurrent, b.maxQueue, b.maxWait)
+	}
+	b.entries.Store(key, e)
+	b.size++
+	return e
+}
+
+// sweep 删除空闲的 key，需要持有 mutex
+// 空闲指的是没有正在执行的请求，熔断器是关闭的，并且滑动窗口内没有请求，删除它们不会丢失任何统计
+func (b *MiddlewareBuilder) sweep(now time.Time) {
+	idle := now.Add(-b.window).UnixNano()
+	b.entries.Range(func(key, val any) bool {
+		e := val.(*entry)
+		if atomic.LoadInt64(&e.inflight) == 0 && atomic.LoadInt64(&e.lastUsed) < idle &&
+			e.breaker.currentState() == StateClosed {
+			b.entries.Delete(key)
+			b.size--
+		}
+		return true
+	})
+}
+
+func (b *MiddlewareBuilder) record(key string, e *entry, t ticket, start time.Time, failed bool) {
+	now := b.now()
+	slow := b.slowThreshold > 0 && now.Sub(start) >= b.slowThreshold
+	b.notify(key, e.breaker.record(t, now, failed, slow))
+}
+
+func (b *MiddlewareBuilder) notify(key string, change stateChange) {
+	if change.ok && b.onChange != nil {
+		b.onChange(key, change.from, change.to)
+	}
+}
+
+func (b *MiddlewareBuilder) reject(ctx *web.Context) {
+	ctx.RespStatusCode = http.StatusServiceUnavailable
+	ctx.RespData = []byte(http.StatusText(http.StatusServiceUnavailable))
+}
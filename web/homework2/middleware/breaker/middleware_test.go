@@ -0,0 +1,337 @@
+package breaker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+type mockClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *mockClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *mockClock) Add(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type stateChangeRecord struct {
+	key  string
+	from State
+	to   State
+}
+
+func TestMiddlewareBuilder_Breaker(t *testing.T) {
+	clock := &mockClock{now: time.Unix(1000, 0)}
+	var changes []stateChangeRecord
+	b := NewMiddlewareBuilder().
+		Window(10*time.Second, 10).
+		MinRequests(4).
+		ErrorRate(0.5).
+		OpenTimeout(5 * time.Second).
+		HalfOpenRequests(2).
+		OnStateChange(func(key string, from State, to State) {
+			changes = append(changes, stateChangeRecord{key: key, from: from, to: to})
+		})
+	b.now = clock.Now
+
+	// code 是下一次请求的响应码
+	code := http.StatusOK
+	handled := 0
+	s := web.NewHTTPServer()
+	s.Get("/user/:id", func(ctx *web.Context) {
+		handled++
+		ctx.RespStatusCode = code
+	}, b.Build())
+	s.Get("/order/:id", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}, b.Build())
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+	const key = "GET /user/:id"
+
+	code = http.StatusOK
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, serve("/user/1").Code)
+	}
+	// 请求数量不够，不会熔断
+	code = http.StatusInternalServerError
+	assert.Equal(t, http.StatusInternalServerError, serve("/user/2").Code)
+	assert.Equal(t, StateClosed, b.State(key))
+	// 4 个请求里面 2 个失败
+	code = http.StatusBadGateway
+	assert.Equal(t, http.StatusBadGateway, serve("/user/3").Code)
+	assert.Equal(t, StateOpen, b.State(key))
+	assert.Equal(t, []stateChangeRecord{{key: key, from: StateClosed, to: StateOpen}}, changes)
+
+	// 熔断之后请求不会到达 handler
+	handled = 0
+	clock.Add(2 * time.Second)
+	recorder := serve("/user/4")
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "3", recorder.Header().Get("Retry-After"))
+	assert.Equal(t, 0, handled)
+	// 其它路由不受影响
+	assert.Equal(t, http.StatusOK, serve("/order/1").Code)
+
+	// 半开状态下探测失败，重新熔断
+	clock.Add(3 * time.Second)
+	code = http.StatusInternalServerError
+	assert.Equal(t, http.StatusInternalServerError, serve("/user/5").Code)
+	assert.Equal(t, StateOpen, b.State(key))
+	assert.Equal(t, http.StatusServiceUnavailable, serve("/user/6").Code)
+
+	// 探测全部成功，恢复正常
+	clock.Add(5 * time.Second)
+	code = http.StatusOK
+	assert.Equal(t, http.StatusOK, serve("/user/7").Code)
+	assert.Equal(t, StateHalfOpen, b.State(key))
+	assert.Equal(t, http.StatusOK, serve("/user/8").Code)
+	assert.Equal(t, StateClosed, b.State(key))
+	// 关闭之后重新统计，之前的失败不会再次触发熔断
+	code = http.StatusInternalServerError
+	assert.Equal(t, http.StatusInternalServerError, serve("/user/9").Code)
+	assert.Equal(t, StateClosed, b.State(key))
+
+	assert.Equal(t, []stateChangeRecord{
+		{key: key, from: StateClosed, to: StateOpen},
+		{key: key, from: StateOpen, to: StateHalfOpen},
+		{key: key, from: StateHalfOpen, to: StateOpen},
+		{key: key, from: StateOpen, to: StateHalfOpen},
+		{key: key, from: StateHalfOpen, to: StateClosed},
+	}, changes)
+}
+
+func TestMiddlewareBuilder_SlidingWindow(t *testing.T) {
+	clock := &mockClock{now: time.Unix(1000, 0)}
+	b := NewMiddlewareBuilder().Window(10*time.Second, 10).MinRequests(4)
+	b.now = clock.Now
+	mdl := b.Build()
+	code := http.StatusInternalServerError
+	h := mdl(func(ctx *web.Context) {
+		ctx.RespStatusCode = code
+	})
+	serve := func() {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		h(&web.Context{Req: req, Resp: httptest.NewRecorder(), MatchedRoute: "/user"})
+	}
+	for i := 0; i < 3; i++ {
+		serve()
+	}
+	// 之前的失败已经滑出窗口
+	clock.Add(11 * time.Second)
+	code = http.StatusOK
+	for i := 0; i < 3; i++ {
+		serve()
+	}
+	// 6 个请求里面 3 个失败
+	code = http.StatusInternalServerError
+	serve()
+	serve()
+	assert.Equal(t, StateClosed, b.State("GET /user"))
+	serve()
+	assert.Equal(t, StateOpen, b.State("GET /user"))
+}
+
+func TestMiddlewareBuilder_SlowCall(t *testing.T) {
+	clock := &mockClock{now: time.Unix(1000, 0)}
+	b := NewMiddlewareBuilder().MinRequests(2).SlowCall(time.Second, 0.5)
+	b.now = clock.Now
+	cost := 2 * time.Second
+	s := web.NewHTTPServer()
+	s.Get("/slow", func(ctx *web.Context) {
+		clock.Add(cost)
+		ctx.RespStatusCode = http.StatusOK
+	}, b.Build())
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+	assert.Equal(t, StateOpen, b.State("GET /slow"))
+}
+
+func TestMiddlewareBuilder_SlowCallInvalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		rate float64
+	}{
+		{name: "zero", rate: 0},
+		{name: "negative", rate: -0.5},
+		{name: "too large", rate: 1.5},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Panics(t, func() {
+				NewMiddlewareBuilder().SlowCall(time.Second, tc.rate).Build()
+			})
+		})
+	}
+	// 不统计慢调用的时候不需要设置比例
+	assert.NotPanics(t, func() {
+		NewMiddlewareBuilder().SlowCall(0, 0).Build()
+	})
+}
+
+func TestMiddlewareBuilder_Panic(t *testing.T) {
+	b := NewMiddlewareBuilder().MinRequests(1)
+	h := b.Build()(func(ctx *web.Context) {
+		panic("boom")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	assert.PanicsWithValue(t, "boom", func() {
+		h(&web.Context{Req: req, Resp: httptest.NewRecorder(), MatchedRoute: "/panic"})
+	})
+	assert.Equal(t, StateOpen, b.State("GET /panic"))
+}
+
+func TestMiddlewareBuilder_Bulkhead(t *testing.T) {
+	testCases := []struct {
+		name     string
+		maxQueue int
+		maxWait  time.Duration
+		// 最后一个请求的响应码，前面的请求占满了并发数或者队列
+		wantCode int
+	}{
+		{
+			name:     "no queue",
+			maxQueue: 0,
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "queue full",
+			maxQueue: 1,
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "wait timeout",
+			maxQueue: 2,
+			maxWait:  10 * time.Millisecond,
+			wantCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewMiddlewareBuilder().Bulkhead(1, tc.maxQueue, tc.maxWait)
+			started, release := make(chan struct{}, 2), make(chan struct{})
+			s := web.NewHTTPServer()
+			s.Get("/user", func(ctx *web.Context) {
+				started <- struct{}{}
+				<-release
+				ctx.RespStatusCode = http.StatusOK
+			}, b.Build())
+
+			var wg sync.WaitGroup
+			codes := make(chan int, 2)
+			serve := func() {
+				defer wg.Done()
+				recorder := httptest.NewRecorder()
+				s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+				codes <- recorder.Code
+			}
+			wg.Add(1)
+			go serve()
+			<-started
+			if tc.maxQueue == 1 {
+				// 第二个请求在排队
+				wg.Add(1)
+				go serve()
+				require.Eventually(t, func() bool {
+					e, _ := b.entries.Load("GET /user")
+					return atomic.LoadInt64(&e.(*entry).bulkhead.waiting) == 1
+				}, time.Second, time.Millisecond)
+			}
+
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+
+			close(release)
+			wg.Wait()
+			close(codes)
+			for code := range codes {
+				assert.Equal(t, http.StatusOK, code)
+			}
+			// 被拒绝的请求不计入熔断器
+			assert.Equal(t, StateClosed, b.State("GET /user"))
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Unmatched(t *testing.T) {
+	b := NewMiddlewareBuilder().MinRequests(1)
+	s := web.NewHTTPServer()
+	// 注册在 Server 上的时候，没有命中路由的请求直接放行
+	s.Use(b.Build())
+	for i := 0; i < 3; i++ {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/missing", nil))
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	}
+	assert.Equal(t, StateClosed, b.State("GET /missing"))
+	assert.Equal(t, StateClosed, b.State("GET "))
+	assert.Equal(t, 0, b.size)
+}
+
+func TestMiddlewareBuilder_MaxKeys(t *testing.T) {
+	clock := &mockClock{now: time.Unix(1000, 0)}
+	b := NewMiddlewareBuilder().Window(10*time.Second, 10).MinRequests(1).MaxKeys(2).
+		KeyFunc(func(ctx *web.Context) string {
+			return ctx.PathParams["tenant"]
+		})
+	b.now = clock.Now
+	s := web.NewHTTPServer()
+	s.Get("/tenant/:tenant", func(ctx *web.Context) {
+		if ctx.PathParams["tenant"] == "a" {
+			ctx.RespStatusCode = http.StatusInternalServerError
+			return
+		}
+		ctx.RespStatusCode = http.StatusOK
+	}, b.Build())
+	serve := func(tenant string) int {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/tenant/"+tenant, nil))
+		return recorder.Code
+	}
+
+	serve("a")
+	serve("b")
+	require.Equal(t, StateOpen, b.State("a"))
+	// 达到上限，并且没有空闲的 key，新的 key 不经过熔断器
+	assert.Equal(t, http.StatusOK, serve("c"))
+	assert.Equal(t, 2, b.size)
+	_, ok := b.entries.Load("c")
+	assert.False(t, ok)
+
+	// b 空闲超过滑动窗口之后被清理，a 还处于熔断状态，不会被清理
+	clock.Add(11 * time.Second)
+	assert.Equal(t, http.StatusOK, serve("c"))
+	assert.Equal(t, 2, b.size)
+	_, ok = b.entries.Load("b")
+	assert.False(t, ok)
+	assert.Equal(t, StateOpen, b.State("a"))
+	_, ok = b.entries.Load("c")
+	assert.True(t, ok)
+}
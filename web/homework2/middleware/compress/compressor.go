@@ -0,0 +1,89 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"sync"
+)
+
+// Compressor 压缩响应数据，和 rpc 里面的 compression.Compressor 类似，
+// 只不过 HTTP 里面使用 Content-Encoding 中的名字来标识压缩算法
+type Compressor interface {
+	// Encoding Content-Encoding 中的名字，例如 gzip
+	Encoding() string
+	Compress(data []byte) ([]byte, error)
+}
+
+// GzipCompressor gzip 压缩，Writer 会被复用
+type GzipCompressor struct {
+	pool sync.Pool
+}
+
+// NewGzipCompressor level 的取值参考 gzip 包，例如 gzip.DefaultCompression
+func NewGzipCompressor(level int) (*GzipCompressor, error) {
+	// 提前校验 level，避免在 pool 里面出错
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		return nil, err
+	}
+	return &GzipCompressor{
+		pool: sync.Pool{New: func() any {
+			w, _ := gzip.NewWriterLevel(nil, level)
+			return w
+		}},
+	}, nil
+}
+
+func (c *GzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+func (c *GzipCompressor) Compress(data []byte) ([]byte, error) {
+	w := c.pool.Get().(*gzip.Writer)
+	defer c.pool.Put(w)
+	buf := &bytes.Buffer{}
+	w.Reset(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DeflateCompressor HTTP 里面的 deflate 实际上是 zlib 格式
+type DeflateCompressor struct {
+	pool sync.Pool
+}
+
+// NewDeflateCompressor level 的取值参考 zlib 包，例如 zlib.DefaultCompression
+func NewDeflateCompressor(level int) (*DeflateCompressor, error) {
+	if _, err := zlib.NewWriterLevel(nil, level); err != nil {
+		return nil, err
+	}
+	return &DeflateCompressor{
+		pool: sync.Pool{New: func() any {
+			w, _ := zlib.NewWriterLevel(nil, level)
+			return w
+		}},
+	}, nil
+}
+
+func (c *DeflateCompressor) Encoding() string {
+	return "deflate"
+}
+
+func (c *DeflateCompressor) Compress(data []byte) ([]byte, error) {
+	w := c.pool.Get().(*zlib.Writer)
+	defer c.pool.Put(w)
+	buf := &bytes.Buffer{}
+	w.Reset(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
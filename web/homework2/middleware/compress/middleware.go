@@ -0,0 +1,141 @@
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"net/http"
+	"strconv"
+	"strings"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// MiddlewareBuilder 根据 Accept-Encoding 压缩 RespData
+// 流式响应、太小的响应和不在白名单里面的 Content-Type 都不会被压缩
+type MiddlewareBuilder struct {
+	compressors  []Compressor
+	minSize      int
+	contentTypes []string
+}
+
+// NewMiddlewareBuilder 默认支持 gzip 和 deflate，优先使用 gzip，
+// 只压缩超过 1KB 的文本、JSON、JavaScript 和 XML
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	gz, _ := NewGzipCompressor(gzip.DefaultCompression)
+	deflate, _ := NewDeflateCompressor(zlib.DefaultCompression)
+	return &MiddlewareBuilder{
+		compressors: []Compressor{gz, deflate},
+		minSize:     1024,
+		contentTypes: []string{"text/*", "application/json", "application/javascript",
+			"application/xml", "image/svg+xml"},
+	}
+}
+
+// Compressors 支持的压缩算法，客户端同样接受多个算法的时候，排在前面的优先
+func (b *MiddlewareBuilder) Compressors(cs ...Compressor) *MiddlewareBuilder {
+	b.compressors = cs
+	return b
+}
+
+// MinSize 小于 size 字节的响应不压缩
+func (b *MiddlewareBuilder) MinSize(size int) *MiddlewareBuilder {
+	b.minSize = size
+	return b
+}
+
+// ContentTypes 需要压缩的 Content-Type，text/* 代表所有的 text 类型
+func (b *MiddlewareBuilder) ContentTypes(types ...string) *MiddlewareBuilder {
+	b.contentTypes = types
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			next(ctx)
+			if ctx.Streaming() {
+				return
+			}
+			header := ctx.Resp.Header()
+			if !b.compressible(ctx, header) {
+				return
+			}
+			// 响应是否被压缩取决于 Accept-Encoding
+			header.Add("Vary", "Accept-Encoding")
+			c := b.negotiate(ctx.Req.Header.Get("Accept-Encoding"))
+			if c == nil {
+				return
+			}
+			data, err := c.Compress(ctx.RespData)
+			if err != nil || len(data) >= len(ctx.RespData) {
+				return
+			}
+			ctx.RespData = data
+			header.Set("Content-Encoding", c.Encoding())
+			header.Del("Content-Length")
+			// 压缩之后内容不再逐字节相同，强 ETag 需要改成弱 ETag
+			if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+}
+
+func (b *MiddlewareBuilder) compressible(ctx *web.Context, header http.Header) bool {
+	code := ctx.RespStatusCode
+	if code == http.StatusNoContent || code == http.StatusNotModified ||
+		(code > 0 && code < http.StatusOK) {
+		return false
+	}
+	if len(ctx.RespData) < b.minSize || header.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := header.Get("Content-Type")
+	if ct == "" {
+		// net/http 也是这么推断 Content-Type 的
+		ct = http.DetectContentType(ctx.RespData)
+	}
+	mediaType, _, _ := strings.Cut(ct, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, t := range b.contentTypes {
+		if t == mediaType {
+			return true
+		}
+		if strings.HasSuffix(t, "*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate 选出客户端接受的、q 值最高的压缩算法
+func (b *MiddlewareBuilder) negotiate(acceptEncoding string) Compressor {
+	if acceptEncoding == "" {
+		return nil
+	}
+	qs := make(map[string]float64, 4)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		params = strings.TrimSpace(params)
+		if strings.HasPrefix(params, "q=") {
+			if f, err := strconv.ParseFloat(params[2:], 64); err == nil {
+				q = f
+			}
+		}
+		qs[name] = q
+	}
+	var res Compressor
+	best := 0.0
+	for _, c := range b.compressors {
+		q, ok := qs[c.Encoding()]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if ok && q > best {
+			res, best = c, q
+		}
+	}
+	return res
+}
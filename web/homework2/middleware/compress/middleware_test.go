@@ -0,0 +1,131 @@
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	large := `{"data":"` + strings.Repeat("hello", 500) + `"}`
+	s := web.NewHTTPServer()
+	s.Use(NewMiddlewareBuilder().MinSize(100).Build())
+	s.Get("/json", func(ctx *web.Context) {
+		ctx.Resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(large)
+	})
+	s.Get("/small", func(ctx *web.Context) {
+		ctx.Resp.Header().Set("Content-Type", "application/json")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(`{"a":1}`)
+	})
+	s.Get("/png", func(ctx *web.Context) {
+		ctx.Resp.Header().Set("Content-Type", "image/png")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(large)
+	})
+	s.Get("/text", func(ctx *web.Context) {
+		// 没有设置 Content-Type，推断为 text/plain
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(large)
+	})
+	s.Get("/etag", func(ctx *web.Context) {
+		ctx.Resp.Header().Set("Content-Type", "application/json")
+		ctx.Resp.Header().Set("ETag", `"abc"`)
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(large)
+	})
+
+	testCases := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantEncoding   string
+		wantVary       bool
+		wantETag       string
+	}{
+		{name: "gzip", path: "/json", acceptEncoding: "gzip, deflate", wantEncoding: "gzip", wantVary: true},
+		{name: "deflate", path: "/json", acceptEncoding: "deflate", wantEncoding: "deflate", wantVary: true},
+		{name: "q value", path: "/json", acceptEncoding: "gzip;q=0.5, deflate;q=0.8", wantEncoding: "deflate", wantVary: true},
+		{name: "q zero", path: "/json", acceptEncoding: "gzip;q=0, deflate;q=0", wantVary: true},
+		{name: "wildcard", path: "/json", acceptEncoding: "*", wantEncoding: "gzip", wantVary: true},
+		{name: "unsupported", path: "/json", acceptEncoding: "br", wantVary: true},
+		{name: "no accept encoding", path: "/json", wantVary: true},
+		{name: "too small", path: "/small", acceptEncoding: "gzip"},
+		{name: "content type", path: "/png", acceptEncoding: "gzip"},
+		{name: "detect content type", path: "/text", acceptEncoding: "gzip", wantEncoding: "gzip", wantVary: true},
+		{name: "weak etag", path: "/etag", acceptEncoding: "gzip", wantEncoding: "gzip", wantVary: true, wantETag: `W/"abc"`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.wantEncoding, recorder.Header().Get("Content-Encoding"))
+			assert.Equal(t, tc.wantVary, recorder.Header().Get("Vary") == "Accept-Encoding")
+			if tc.wantETag != "" {
+				assert.Equal(t, tc.wantETag, recorder.Header().Get("ETag"))
+			}
+
+			var r io.Reader = recorder.Body
+			switch tc.wantEncoding {
+			case "gzip":
+				gr, err := gzip.NewReader(r)
+				require.NoError(t, err)
+				r = gr
+			case "deflate":
+				zr, err := zlib.NewReader(r)
+				require.NoError(t, err)
+				r = zr
+			}
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			if tc.path != "/small" {
+				assert.Equal(t, large, string(data))
+			}
+		})
+	}
+}
+
+func TestCompressor_Reuse(t *testing.T) {
+	gz, err := NewGzipCompressor(gzip.BestSpeed)
+	require.NoError(t, err)
+	deflate, err := NewDeflateCompressor(zlib.BestSpeed)
+	require.NoError(t, err)
+	_, err = NewGzipCompressor(100)
+	assert.Error(t, err)
+
+	// 同一个 Writer 被复用多次，结果依旧正确
+	for i := 0; i < 3; i++ {
+		data := strings.Repeat("a", i*100+1)
+		bs, err := gz.Compress([]byte(data))
+		require.NoError(t, err)
+		gr, err := gzip.NewReader(strings.NewReader(string(bs)))
+		require.NoError(t, err)
+		res, err := io.ReadAll(gr)
+		require.NoError(t, err)
+		assert.Equal(t, data, string(res))
+
+		bs, err = deflate.Compress([]byte(data))
+		require.NoError(t, err)
+		zr, err := zlib.NewReader(strings.NewReader(string(bs)))
+		require.NoError(t, err)
+		res, err = io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, data, string(res))
+	}
+}
@@ -0,0 +1,229 @@
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// MiddlewareBuilder 处理跨域请求
+// 它需要通过 HTTPServer.Use 注册，这样才能在路由匹配之前拦截预检请求，
+// 因此即便没有注册 OPTIONS 路由，预检请求也能得到正确的响应
+type MiddlewareBuilder struct {
+	allowAll     bool
+	origins      []string
+	wildcards    []wildcard
+	originFunc   func(origin string) bool
+	methods      []string
+	headers      []string
+	allowHeaders bool
+	credentials  bool
+	expose       []string
+	maxAge       time.Duration
+}
+
+// wildcard 形如 https://*.example.com 的域名
+type wildcard struct {
+	prefix string
+	suffix string
+}
+
+func (w wildcard) match(origin string) bool {
+	return len(origin) > len(w.prefix)+len(w.suffix) &&
+		strings.HasPrefix(origin, w.prefix) && strings.HasSuffix(origin, w.suffix)
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		methods: []string{http.MethodGet, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodHead},
+		headers: []string{"Origin", "Accept", "Content-Type", "Authorization"},
+	}
+}
+
+// AllowOrigins 允许的来源，例如 https://example.com
+// * 代表允许所有的来源，https://*.example.com 代表允许 example.com 的所有子域名
+func (b *MiddlewareBuilder) AllowOrigins(origins ...string) *MiddlewareBuilder {
+	for _, origin := range origins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			b.allowAll = true
+		case strings.Contains(origin, "*"):
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			b.wildcards = append(b.wildcards, wildcard{prefix: prefix, suffix: suffix})
+		default:
+			b.origins = append(b.origins, origin)
+		}
+	}
+	return b
+}
+
+// AllowOriginFunc 使用 fn 判断是否允许这个来源，和 AllowOrigins 是或的关系
+func (b *MiddlewareBuilder) AllowOriginFunc(fn func(origin string) bool) *MiddlewareBuilder {
+	b.originFunc = fn
+	return b
+}
+
+// AllowMethods 预检请求允许的方法，默认是 GET、POST、PUT、PATCH、DELETE 和 HEAD
+func (b *MiddlewareBuilder) AllowMethods(methods ...string) *MiddlewareBuilder {
+	b.methods = make([]string, 0, len(methods))
+	for _, m := range methods {
+		b.methods = append(b.methods, strings.ToUpper(m))
+	}
+	return b
+}
+
+// AllowHeaders 预检请求允许的头部，默认是 Origin、Accept、Content-Type 和 Authorization
+// * 代表允许所有的头部
+func (b *MiddlewareBuilder) AllowHeaders(headers ...string) *MiddlewareBuilder {
+	b.headers = make([]string, 0, len(headers))
+	b.allowHeaders = false
+	for _, h := range headers {
+		if h == "*" {
+			b.allowHeaders = true
+			continue
+		}
+		b.headers = append(b.headers, http.CanonicalHeaderKey(h))
+	}
+	return b
+}
+
+// AllowCredentials 允许携带 cookie 之类的凭证
+// 它不能和 AllowOrigins("*") 一起使用，否则任何网站都能以用户的身份发起请求，Build 的时候会 panic；
+// 确实需要的时候，可以使用 AllowOriginFunc 明确地判断来源
+func (b *MiddlewareBuilder) AllowCredentials() *MiddlewareBuilder {
+	b.credentials = true
+	return b
+}
+
+// ExposeHeaders 允许前端读取的响应头部
+func (b *MiddlewareBuilder) ExposeHeaders(headers ...string) *MiddlewareBuilder {
+	b.expose = headers
+	return b
+}
+
+// MaxAge 预检请求的结果可以被缓存多久
+func (b *MiddlewareBuilder) MaxAge(d time.Duration) *MiddlewareBuilder {
+	b.maxAge = d
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	if b.allowAll && b.credentials {
+		panic("cors: 允许所有来源的时候不能允许携带凭证")
+	}
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			origin := ctx.Req.Header.Get("Origin")
+			if origin == "" {
+				// 不是跨域请求
+				next(ctx)
+				return
+			}
+			header := ctx.Resp.Header()
+			header.Add("Vary", "Origin")
+			preflight := ctx.Req.Method == http.MethodOptions &&
+				ctx.Req.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				b.handlePreflight(ctx, origin)
+				return
+			}
+			if b.allowOrigin(origin) {
+				b.setOrigin(header, origin)
+				if len(b.expose) > 0 {
+					header.Set("Access-Control-Expose-Headers", strings.Join(b.expose, ", "))
+				}
+			}
+			next(ctx)
+		}
+	}
+}
+
+// handlePreflight 直接响应预检请求，不会执行后续的中间件和路由
+func (b *MiddlewareBuilder) handlePreflight(ctx *web.Context, origin string) {
+	header := ctx.Resp.Header()
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	method := strings.ToUpper(ctx.Req.Header.Get("Access-Control-Request-Method"))
+	reqHeaders := splitHeaders(ctx.Req.Header.Get("Access-Control-Request-Headers"))
+	if !b.allowOrigin(origin) || !contains(b.methods, method) || !b.allowRequestHeaders(reqHeaders) {
+		ctx.RespStatusCode = http.StatusForbidden
+		return
+	}
+	b.setOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(b.methods, ", "))
+	if len(reqHeaders) > 0 {
+		if b.allowHeaders {
+			header.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+		} else {
+			header.Set("Access-Control-Allow-Headers", strings.Join(b.headers, ", "))
+		}
+	}
+	if b.maxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(b.maxAge/time.Second)))
+	}
+	ctx.RespStatusCode = http.StatusNoContent
+}
+
+func (b *MiddlewareBuilder) setOrigin(header http.Header, origin string) {
+	if b.allowAll {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if b.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (b *MiddlewareBuilder) allowOrigin(origin string) bool {
+	if b.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if contains(b.origins, lower) {
+		return true
+	}
+	for _, w := range b.wildcards {
+		if w.match(lower) {
+			return true
+		}
+	}
+	return b.originFunc != nil && b.originFunc(origin)
+}
+
+func (b *MiddlewareBuilder) allowRequestHeaders(headers []string) bool {
+	if b.allowHeaders {
+		return true
+	}
+	for _, h := range headers {
+		if !contains(b.headers, h) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitHeaders 拆分 Access-Control-Request-Headers，并且转化为标准的形式
+func splitHeaders(val string) []string {
+	var res []string
+	for _, h := range strings.Split(val, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			res = append(res, http.CanonicalHeaderKey(h))
+		}
+	}
+	return res
+}
+
+func contains(vals []string, target string) bool {
+	for _, val := range vals {
+		if val == target {
+			return true
+		}
+	}
+	return false
+}
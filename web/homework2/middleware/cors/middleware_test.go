@@ -0,0 +1,191 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name    string
+		builder *MiddlewareBuilder
+		method  string
+		header  map[string]string
+
+		wantCode   int
+		wantHeader map[string]string
+	}{
+		{
+			name:       "not cross origin",
+			builder:    NewMiddlewareBuilder().AllowOrigins("https://a.com"),
+			method:     http.MethodGet,
+			wantCode:   http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:     "exact origin",
+			builder:  NewMiddlewareBuilder().AllowOrigins("https://a.com").ExposeHeaders("X-Total"),
+			method:   http.MethodGet,
+			header:   map[string]string{"Origin": "https://a.com"},
+			wantCode: http.StatusOK,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":   "https://a.com",
+				"Access-Control-Expose-Headers": "X-Total",
+				"Vary":                          "Origin",
+			},
+		},
+		{
+			name:    "origin not allowed",
+			builder: NewMiddlewareBuilder().AllowOrigins("https://a.com"),
+			method:  http.MethodGet,
+			header:  map[string]string{"Origin": "https://b.com"},
+			// 实际请求依旧会被处理，由浏览器拦截响应
+			wantCode:   http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "wildcard subdomain",
+			builder:    NewMiddlewareBuilder().AllowOrigins("https://*.a.com"),
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://api.a.com"},
+			wantCode:   http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "https://api.a.com"},
+		},
+		{
+			name:       "wildcard not match root",
+			builder:    NewMiddlewareBuilder().AllowOrigins("https://*.a.com"),
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://evil-a.com"},
+			wantCode:   http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name: "origin func",
+			builder: NewMiddlewareBuilder().AllowOriginFunc(func(origin string) bool {
+				return strings.HasSuffix(origin, ":8080")
+			}),
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "http://localhost:8080"},
+			wantCode:   http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "http://localhost:8080"},
+		},
+		{
+			name:       "all",
+			builder:    NewMiddlewareBuilder().AllowOrigins("*"),
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://b.com"},
+			wantCode:   http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name: "func with credentials",
+			builder: NewMiddlewareBuilder().AllowCredentials().AllowOriginFunc(func(origin string) bool {
+				return strings.HasSuffix(origin, ".b.com")
+			}),
+			method:   http.MethodGet,
+			header:   map[string]string{"Origin": "https://x.b.com"},
+			wantCode: http.StatusOK,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "https://x.b.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name: "preflight",
+			builder: NewMiddlewareBuilder().AllowOrigins("https://a.com").
+				AllowMethods("get", "delete").AllowHeaders("X-Token", "content-type").MaxAge(time.Hour),
+			method: http.MethodOptions,
+			header: map[string]string{
+				"Origin":                         "https://a.com",
+				"Access-Control-Request-Method":  "DELETE",
+				"Access-Control-Request-Headers": "x-token, Content-Type",
+			},
+			wantCode: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "https://a.com",
+				"Access-Control-Allow-Methods": "GET, DELETE",
+				"Access-Control-Allow-Headers": "X-Token, Content-Type",
+				"Access-Control-Max-Age":       "3600",
+			},
+		},
+		{
+			name:    "preflight any header",
+			builder: NewMiddlewareBuilder().AllowOrigins("https://a.com").AllowHeaders("*"),
+			method:  http.MethodOptions,
+			header: map[string]string{
+				"Origin":                         "https://a.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "x-anything",
+			},
+			wantCode:   http.StatusNoContent,
+			wantHeader: map[string]string{"Access-Control-Allow-Headers": "X-Anything"},
+		},
+		{
+			name:    "preflight method not allowed",
+			builder: NewMiddlewareBuilder().AllowOrigins("https://a.com").AllowMethods("GET"),
+			method:  http.MethodOptions,
+			header: map[string]string{
+				"Origin":                        "https://a.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			wantCode:   http.StatusForbidden,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "preflight header not allowed",
+			builder: NewMiddlewareBuilder().AllowOrigins("https://a.com"),
+			method:  http.MethodOptions,
+			header: map[string]string{
+				"Origin":                         "https://a.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "X-Token",
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:    "preflight origin not allowed",
+			builder: NewMiddlewareBuilder().AllowOrigins("https://a.com"),
+			method:  http.MethodOptions,
+			header: map[string]string{
+				"Origin":                        "https://b.com",
+				"Access-Control-Request-Method": "POST",
+			},
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := web.NewHTTPServer()
+			s.Use(tc.builder.Build())
+			// 没有注册 OPTIONS 路由
+			handler := func(ctx *web.Context) {
+				ctx.RespStatusCode = http.StatusOK
+			}
+			s.Get("/user", handler)
+			s.Post("/user", handler)
+			req := httptest.NewRequest(tc.method, "/user", nil)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			for k, v := range tc.wantHeader {
+				assert.Equal(t, v, recorder.Header().Get(k), k)
+			}
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Build_AllWithCredentials(t *testing.T) {
+	assert.PanicsWithValue(t, "cors: 允许所有来源的时候不能允许携带凭证", func() {
+		NewMiddlewareBuilder().AllowOrigins("https://a.com", "*").AllowCredentials().Build()
+	})
+}
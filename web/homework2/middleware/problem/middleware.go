@@ -0,0 +1,194 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// Problem RFC 7807 定义的错误响应
+// handler 也可以直接返回 *Problem，中间件会原样输出
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// 下面是扩展字段
+	// Code 业务错误码
+	Code      string               `json:"code,omitempty"`
+	RequestID string               `json:"request_id,omitempty"`
+	Errors    web.ValidationErrors `json:"errors,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// mapping 错误和响应码、错误码的映射
+type mapping struct {
+	match  func(err error) bool
+	status int
+	code   string
+}
+
+// MiddlewareBuilder 把 ctx.Err 和 panic 转化为 application/problem+json 响应
+// ctx.Err 通常来自于 web.HandleErr 包装的 handler。
+// 错误按照注册的顺序匹配，没有匹配上的错误一律返回 500，并且不会把错误信息暴露给客户端
+type MiddlewareBuilder struct {
+	mappings []mapping
+	typeBase string
+	// logFunc 记录没有匹配上的错误和 panic
+	logFunc func(ctx *web.Context, err error)
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	b := &MiddlewareBuilder{
+		mappings: make([]mapping, 0, 8),
+		logFunc: func(ctx *web.Context, err error) {
+			// 默认忽略
+		},
+	}
+	return b.Register(web.ErrBodyTooLarge, http.StatusRequestEntityTooLarge, "body_too_large")
+}
+
+// Register 注册一个错误，使用 errors.Is 判断
+func (b *MiddlewareBuilder) Register(target error, status int, code string) *MiddlewareBuilder {
+	b.mappings = append(b.mappings, mapping{
+		match: func(err error) bool {
+			return errors.Is(err, target)
+		},
+		status: status,
+		code:   code,
+	})
+	return b
+}
+
+// RegisterType 注册一种错误类型，使用 errors.As 判断，例如
+//
+//	problem.RegisterType[*NotFoundError](b, http.StatusNotFound, "not_found")
+//
+// 因为方法不支持类型参数，所以只能是一个函数
+func RegisterType[T error](b *MiddlewareBuilder, status int, code string) *MiddlewareBuilder {
+	b.mappings = append(b.mappings, mapping{
+		match: func(err error) bool {
+			var target T
+			return errors.As(err, &target)
+		},
+		status: status,
+		code:   code,
+	})
+	return b
+}
+
+// TypeBase 设置之后，type 字段是 TypeBase + "/" + code，指向错误码的文档，
+// 否则是 about:blank
+func (b *MiddlewareBuilder) TypeBase(base string) *MiddlewareBuilder {
+	b.typeBase = base
+	return b
+}
+
+func (b *MiddlewareBuilder) LogFunc(fn func(ctx *web.Context, err error)) *MiddlewareBuilder {
+	b.logFunc = fn
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			defer func() {
+				if p := recover(); p != nil {
+					if ctx.Streaming() {
+						// 已经开始输出响应，没有办法再修改了
+						panic(p)
+					}
+					err := fmt.Errorf("problem: panic: %v", p)
+					b.logFunc(ctx, err)
+					b.write(ctx, b.newProblem(ctx, http.StatusInternalServerError, ""))
+				}
+			}()
+			next(ctx)
+			if ctx.Err == nil || ctx.Streaming() {
+				return
+			}
+			b.write(ctx, b.resolve(ctx, ctx.Err))
+		}
+	}
+}
+
+func (b *MiddlewareBuilder) resolve(ctx *web.Context, err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		res := *p
+		if res.Status == 0 {
+			res.Status = http.StatusInternalServerError
+		}
+		if res.Title == "" {
+			res.Title = http.StatusText(res.Status)
+		}
+		if res.Type == "" {
+			res.Type = b.typeOf(res.Code)
+		}
+		if res.Instance == "" {
+			res.Instance = ctx.Req.URL.Path
+		}
+		if res.RequestID == "" {
+			res.RequestID = ctx.RequestID
+		}
+		return &res
+	}
+	for _, m := range b.mappings {
+		if m.match(err) {
+			p = b.newProblem(ctx, m.status, m.code)
+			p.Detail = err.Error()
+			return p
+		}
+	}
+	var ve web.ValidationErrors
+	if errors.As(err, &ve) {
+		p = b.newProblem(ctx, http.StatusBadRequest, "validation_failed")
+		p.Detail = "参数校验失败"
+		p.Errors = ve
+		return p
+	}
+	b.logFunc(ctx, err)
+	return b.newProblem(ctx, http.StatusInternalServerError, "")
+}
+
+func (b *MiddlewareBuilder) newProblem(ctx *web.Context, status int, code string) *Problem {
+	return &Problem{
+		Type:      b.typeOf(code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Instance:  ctx.Req.URL.Path,
+		Code:      code,
+		RequestID: ctx.RequestID,
+	}
+}
+
+func (b *MiddlewareBuilder) typeOf(code string) string {
+	if b.typeBase == "" || code == "" {
+		return "about:blank"
+	}
+	return b.typeBase + "/" + code
+}
+
+func (b *MiddlewareBuilder) write(ctx *web.Context, p *Problem) {
+	bs, err := json.Marshal(p)
+	if err != nil {
+		b.logFunc(ctx, err)
+		ctx.RespStatusCode = http.StatusInternalServerError
+		ctx.RespData = []byte(http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	ctx.Resp.Header().Set("Content-Type", "application/problem+json")
+	ctx.RespStatusCode = p.Status
+	ctx.RespData = bs
+}
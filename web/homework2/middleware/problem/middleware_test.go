@@ -0,0 +1,150 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+var errUserNotFound = errors.New("用户不存在")
+
+type balanceError struct {
+	need int
+}
+
+func (e *balanceError) Error() string {
+	return "余额不足"
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	type userReq struct {
+		Name string `json:"name" validate:"required"`
+	}
+	var logged []error
+	b := NewMiddlewareBuilder().
+		Register(errUserNotFound, http.StatusNotFound, "user_not_found").
+		TypeBase("https://example.com/errors").
+		LogFunc(func(ctx *web.Context, err error) {
+			logged = append(logged, err)
+		})
+	RegisterType[*balanceError](b, http.StatusPaymentRequired, "insufficient_balance")
+
+	s := web.NewHTTPServer()
+	s.Use(b.Build())
+	s.Get("/ok", web.HandleErr(func(ctx *web.Context) error {
+		return ctx.RespJSONOK(map[string]string{"name": "Tom"})
+	}))
+	s.Get("/not_found", web.HandleErr(func(ctx *web.Context) error {
+		return errUserNotFound
+	}))
+	s.Get("/wrapped", web.HandleErr(func(ctx *web.Context) error {
+		return &balanceError{need: 10}
+	}))
+	s.Get("/problem", web.HandleErr(func(ctx *web.Context) error {
+		return &Problem{Status: http.StatusConflict, Detail: "用户名已经存在", Code: "duplicate_name"}
+	}))
+	s.Get("/unknown", web.HandleErr(func(ctx *web.Context) error {
+		return errors.New("db: connection refused")
+	}))
+	s.Get("/json", web.HandleErr(func(ctx *web.Context) error {
+		return ctx.RespJSONOK(make(chan int))
+	}))
+	s.Get("/panic", func(ctx *web.Context) {
+		panic("boom")
+	})
+	s.Post("/user", web.HandleErr(func(ctx *web.Context) error {
+		var req userReq
+		if err := ctx.BindJSON(&req); err != nil {
+			return err
+		}
+		return ctx.RespJSONOK(req)
+	}))
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantCode   int
+		wantResp   string
+		wantLogged int
+	}{
+		{
+			name:     "ok",
+			path:     "/ok",
+			wantCode: http.StatusOK,
+			wantResp: `{"name":"Tom"}`,
+		},
+		{
+			name:     "registered error",
+			path:     "/not_found",
+			wantCode: http.StatusNotFound,
+			wantResp: `{"type":"https://example.com/errors/user_not_found","title":"Not Found","status":404,"detail":"用户不存在","instance":"/not_found","code":"user_not_found"}`,
+		},
+		{
+			name:     "registered type",
+			path:     "/wrapped",
+			wantCode: http.StatusPaymentRequired,
+			wantResp: `{"type":"https://example.com/errors/insufficient_balance","title":"Payment Required","status":402,"detail":"余额不足","instance":"/wrapped","code":"insufficient_balance"}`,
+		},
+		{
+			name:     "problem",
+			path:     "/problem",
+			wantCode: http.StatusConflict,
+			wantResp: `{"type":"https://example.com/errors/duplicate_name","title":"Conflict","status":409,"detail":"用户名已经存在","instance":"/problem","code":"duplicate_name"}`,
+		},
+		{
+			name:       "unknown",
+			path:       "/unknown",
+			wantCode:   http.StatusInternalServerError,
+			wantResp:   `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/unknown"}`,
+			wantLogged: 1,
+		},
+		{
+			name:       "resp json",
+			path:       "/json",
+			wantCode:   http.StatusInternalServerError,
+			wantResp:   `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/json"}`,
+			wantLogged: 1,
+		},
+		{
+			name:       "panic",
+			path:       "/panic",
+			wantCode:   http.StatusInternalServerError,
+			wantResp:   `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/panic"}`,
+			wantLogged: 1,
+		},
+		{
+			name:     "validation",
+			method:   http.MethodPost,
+			path:     "/user",
+			body:     `{}`,
+			wantCode: http.StatusBadRequest,
+			wantResp: `{"type":"https://example.com/errors/validation_failed","title":"Bad Request","status":400,"detail":"参数校验失败","instance":"/user","code":"validation_failed","errors":[{"field":"name","rule":"required","message":"name 是必填字段"}]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logged = nil
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tc.path, strings.NewReader(tc.body))
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+			assert.Len(t, logged, tc.wantLogged)
+			if tc.wantCode != http.StatusOK {
+				assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
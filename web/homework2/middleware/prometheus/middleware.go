@@ -21,23 +21,35 @@ func (m *MiddlewareBuilder) Build() web.Middleware {
 		ConstLabels: m.ConstLabels,
 		Help:        m.Help,
 	}, []string{"pattern", "method", "status"})
+	// 响应体的大小，流式响应统计的是实际写入的字节数
+	sizeVec := prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:        m.Name + "_response_size",
+		Subsystem:   m.Subsystem,
+		ConstLabels: m.ConstLabels,
+		Help:        m.Help,
+	}, []string{"pattern", "method", "status"})
 
 	return func(next web.HandleFunc) web.HandleFunc {
 		return func(ctx *web.Context) {
 			startTime := time.Now()
 			next(ctx)
 			endTime := time.Now()
-			go report(endTime.Sub(startTime), ctx, summaryVec)
+			// 在当前 goroutine 里面取值，避免和回写响应并发读写 ctx
+			labels := labelValues(ctx)
+			size := ctx.RespSize()
+			go func() {
+				summaryVec.WithLabelValues(labels...).Observe(float64(endTime.Sub(startTime) / time.Millisecond))
+				sizeVec.WithLabelValues(labels...).Observe(float64(size))
+			}()
 		}
 	}
 }
 
-func report(dur time.Duration, ctx *web.Context, vec prometheus.ObserverVec) {
+func labelValues(ctx *web.Context) []string {
 	status := ctx.RespStatusCode
 	route := "unknown"
 	if ctx.MatchedRoute != "" {
 		route = ctx.MatchedRoute
 	}
-	ms := dur / time.Millisecond
-	vec.WithLabelValues(route, ctx.Req.Method, strconv.Itoa(status)).Observe(float64(ms))
+	return []string{route, ctx.Req.Method, strconv.Itoa(status)}
 }
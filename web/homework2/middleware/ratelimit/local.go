@@ -0,0 +1,196 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// store 本地限流器保存状态的 map，会定期清理已经失效的状态
+type store[T any] struct {
+	mutex sync.Mutex
+	data  map[string]*T
+	// expired 判断状态是否已经失效，失效的状态和初始状态没有区别
+	expired   func(val *T, now time.Time) bool
+	interval  time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newStore[T any](interval time.Duration, expired func(val *T, now time.Time) bool) *store[T] {
+	return &store[T]{
+		data:     make(map[string]*T, 64),
+		expired:  expired,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// with 在锁里面执行 fn，val 不存在的时候是 nil
+func (s *store[T]) with(key string, fn func(val *T, now time.Time) *T) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) > s.interval {
+		for k, v := range s.data {
+			if s.expired(v, now) {
+				delete(s.data, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.data[key] = fn(s.data[key], now)
+}
+
+// mustPositive 校验限流器的参数，限制的请求数量和时间都必须大于 0
+func mustPositive(limit int, d time.Duration) {
+	if limit <= 0 {
+		panic("ratelimit: 限制的请求数量必须大于 0")
+	}
+	if d <= 0 {
+		panic("ratelimit: 时间必须大于 0")
+	}
+}
+
+type fixedWindow struct {
+	start time.Time
+	cnt   int
+}
+
+// LocalFixedWindow 本地的固定窗口限流器
+type LocalFixedWindow struct {
+	limit  int
+	window time.Duration
+	store  *store[fixedWindow]
+}
+
+// NewLocalFixedWindow 每一个 window 内最多允许 limit 个请求
+func NewLocalFixedWindow(limit int, window time.Duration) *LocalFixedWindow {
+	mustPositive(limit, window)
+	return &LocalFixedWindow{
+		limit:  limit,
+		window: window,
+		store: newStore(window, func(val *fixedWindow, now time.Time) bool {
+			return !now.Before(val.start.Add(window))
+		}),
+	}
+}
+
+func (l *LocalFixedWindow) Allow(ctx context.Context, key string) (Result, error) {
+	res := Result{Limit: l.limit}
+	l.store.with(key, func(val *fixedWindow, now time.Time) *fixedWindow {
+		if val == nil || !now.Before(val.start.Add(l.window)) {
+			val = &fixedWindow{start: now}
+		}
+		res.ResetAfter = val.start.Add(l.window).Sub(now)
+		if val.cnt < l.limit {
+			val.cnt++
+			res.Allowed = true
+		} else {
+			res.RetryAfter = res.ResetAfter
+		}
+		res.Remaining = l.limit - val.cnt
+		return val
+	})
+	return res, nil
+}
+
+type slidingWindow struct {
+	// reqs 窗口内的请求时间，按照时间先后排列
+	reqs []time.Time
+}
+
+// LocalSlidingWindow 本地的滑动窗口限流器
+// 记录窗口内每一个请求的时间，任意长度为 window 的时间段内最多允许 limit 个请求
+type LocalSlidingWindow struct {
+	limit  int
+	window time.Duration
+	store  *store[slidingWindow]
+}
+
+func NewLocalSlidingWindow(limit int, window time.Duration) *LocalSlidingWindow {
+	mustPositive(limit, window)
+	return &LocalSlidingWindow{
+		limit:  limit,
+		window: window,
+		store: newStore(window, func(val *slidingWindow, now time.Time) bool {
+			return len(val.reqs) == 0 || !now.Before(val.reqs[len(val.reqs)-1].Add(window))
+		}),
+	}
+}
+
+func (l *LocalSlidingWindow) Allow(ctx context.Context, key string) (Result, error) {
+	res := Result{Limit: l.limit}
+	l.store.with(key, func(val *slidingWindow, now time.Time) *slidingWindow {
+		if val == nil {
+			val = &slidingWindow{reqs: make([]time.Time, 0, l.limit)}
+		}
+		// 移除窗口之外的请求
+		boundary := now.Add(-l.window)
+		i := 0
+		for i < len(val.reqs) && !val.reqs[i].After(boundary) {
+			i++
+		}
+		val.reqs = append(val.reqs[:0], val.reqs[i:]...)
+
+		if len(val.reqs) < l.limit {
+			val.reqs = append(val.reqs, now)
+			res.Allowed = true
+		} else {
+			res.RetryAfter = val.reqs[0].Add(l.window).Sub(now)
+		}
+		res.Remaining = l.limit - len(val.reqs)
+		res.ResetAfter = val.reqs[len(val.reqs)-1].Add(l.window).Sub(now)
+		return val
+	})
+	return res, nil
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// LocalTokenBucket 本地的令牌桶限流器
+// 桶里面最多有 capacity 个令牌，每隔 interval 生成一个令牌，每一个请求消耗一个令牌
+type LocalTokenBucket struct {
+	capacity int
+	interval time.Duration
+	store    *store[tokenBucket]
+}
+
+func NewLocalTokenBucket(capacity int, interval time.Duration) *LocalTokenBucket {
+	mustPositive(capacity, interval)
+	full := interval * time.Duration(capacity)
+	return &LocalTokenBucket{
+		capacity: capacity,
+		interval: interval,
+		store: newStore(full, func(val *tokenBucket, now time.Time) bool {
+			// 桶已经满了
+			return now.Sub(val.last) >= full
+		}),
+	}
+}
+
+func (l *LocalTokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	res := Result{Limit: l.capacity}
+	l.store.with(key, func(val *tokenBucket, now time.Time) *tokenBucket {
+		if val == nil {
+			val = &tokenBucket{tokens: float64(l.capacity), last: now}
+		}
+		val.tokens = math.Min(float64(l.capacity),
+			val.tokens+float64(now.Sub(val.last))/float64(l.interval))
+		val.last = now
+		if val.tokens >= 1 {
+			val.tokens--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = time.Duration(math.Ceil((1 - val.tokens) * float64(l.interval)))
+		}
+		res.Remaining = int(val.tokens)
+		res.ResetAfter = time.Duration(math.Ceil((float64(l.capacity) - val.tokens) * float64(l.interval)))
+		return val
+	})
+	return res, nil
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock 测试用的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+type step struct {
+	// 请求之前先经过多长时间
+	advance time.Duration
+	want    Result
+}
+
+func runSteps(t *testing.T, l Limiter, clock *fakeClock, steps []step) {
+	for i, s := range steps {
+		clock.Add(s.advance)
+		res, err := l.Allow(context.Background(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, s.want, res, "第 %d 个请求", i)
+	}
+}
+
+func TestLocalFixedWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	l := NewLocalFixedWindow(2, time.Second)
+	l.store.now = clock.Now
+	runSteps(t, l, clock, []step{
+		{want: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second}},
+		{advance: 100 * time.Millisecond,
+			want: Result{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: 900 * time.Millisecond}},
+		{advance: 100 * time.Millisecond,
+			want: Result{Limit: 2, RetryAfter: 800 * time.Millisecond, ResetAfter: 800 * time.Millisecond}},
+		// 新的窗口
+		{advance: 800 * time.Millisecond,
+			want: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second}},
+	})
+}
+
+func TestLocalSlidingWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	l := NewLocalSlidingWindow(2, time.Second)
+	l.store.now = clock.Now
+	runSteps(t, l, clock, []step{
+		{want: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second}},
+		{advance: 600 * time.Millisecond,
+			want: Result{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second}},
+		{advance: 200 * time.Millisecond,
+			want: Result{Limit: 2, RetryAfter: 200 * time.Millisecond, ResetAfter: 800 * time.Millisecond}},
+		// 第一个请求离开了窗口，但是第二个请求还在
+		{advance: 200 * time.Millisecond,
+			want: Result{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second}},
+		{advance: 100 * time.Millisecond,
+			want: Result{Limit: 2, RetryAfter: 500 * time.Millisecond, ResetAfter: 900 * time.Millisecond}},
+	})
+}
+
+func TestLocalTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	l := NewLocalTokenBucket(2, 100*time.Millisecond)
+	l.store.now = clock.Now
+	runSteps(t, l, clock, []step{
+		{want: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: 100 * time.Millisecond}},
+		{want: Result{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: 200 * time.Millisecond}},
+		{advance: 50 * time.Millisecond,
+			want: Result{Limit: 2, RetryAfter: 50 * time.Millisecond, ResetAfter: 150 * time.Millisecond}},
+		{advance: 50 * time.Millisecond,
+			want: Result{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: 200 * time.Millisecond}},
+		// 很久之后桶是满的，但是不会超过容量
+		{advance: time.Minute,
+			want: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: 100 * time.Millisecond}},
+	})
+}
+
+func TestStore_Sweep(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	l := NewLocalFixedWindow(1, time.Second)
+	l.store.now = clock.Now
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := l.Allow(context.Background(), key)
+		require.NoError(t, err)
+	}
+	assert.Len(t, l.store.data, 3)
+	clock.Add(2 * time.Second)
+	_, err := l.Allow(context.Background(), "d")
+	require.NoError(t, err)
+	assert.Len(t, l.store.data, 1)
+}
+
+func TestNewLocalLimiter_Invalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		limit  int
+		window time.Duration
+	}{
+		{name: "zero limit", limit: 0, window: time.Second},
+		{name: "negative limit", limit: -1, window: time.Second},
+		{name: "zero window", limit: 10, window: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Panics(t, func() { NewLocalFixedWindow(tc.limit, tc.window) })
+			assert.Panics(t, func() { NewLocalSlidingWindow(tc.limit, tc.window) })
+			assert.Panics(t, func() { NewLocalTokenBucket(tc.limit, tc.window) })
+		})
+	}
+}
@@ -0,0 +1,10 @@
+-- KEYS[1] 限流的 key
+-- ARGV[1] 窗口大小，毫秒
+local cnt = redis.call('incr', KEYS[1])
+local ttl = redis.call('pttl', KEYS[1])
+if ttl < 0 then
+    -- 新窗口，设置过期时间
+    redis.call('pexpire', KEYS[1], ARGV[1])
+    ttl = tonumber(ARGV[1])
+end
+return {cnt, ttl}
@@ -0,0 +1,29 @@
+-- KEYS[1] 限流的 key
+-- ARGV[1] 窗口大小，毫秒
+-- ARGV[2] 窗口内允许的请求数
+-- ARGV[3] 这一次请求的唯一标识
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+-- 使用 redis 的时间，避免多个实例之间的时钟不一致
+local t = redis.call('time')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+-- 移除窗口之外的请求
+redis.call('zremrangebyscore', key, '-inf', now - window)
+local cnt = redis.call('zcard', key)
+local allowed = 0
+if cnt < limit then
+    redis.call('zadd', key, now, ARGV[3])
+    cnt = cnt + 1
+    allowed = 1
+end
+redis.call('pexpire', key, window)
+
+-- 最早的请求离开窗口的时间
+local wait = window
+local oldest = redis.call('zrange', key, 0, 0, 'withscores')
+if #oldest > 0 then
+    wait = tonumber(oldest[2]) + window - now
+end
+return {allowed, cnt, wait}
@@ -0,0 +1,33 @@
+-- KEYS[1] 限流的 key
+-- ARGV[1] 桶的容量
+-- ARGV[2] 生成一个令牌的间隔，毫秒
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local t = redis.call('time')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('hmget', key, 'tokens', 'last')
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil or last == nil then
+    -- 第一次请求，桶是满的
+    tokens = capacity
+    last = now
+end
+tokens = math.min(capacity, tokens + (now - last) / interval)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+else
+    wait = math.ceil((1 - tokens) * interval)
+end
+-- 令牌数可能是小数，使用字符串保存
+redis.call('hset', key, 'tokens', tostring(tokens), 'last', now)
+-- 桶满了之后，状态就没有必要保存了
+local full = math.ceil((capacity - tokens) * interval)
+redis.call('pexpire', key, full + interval)
+return {allowed, math.floor(tokens), wait, full}
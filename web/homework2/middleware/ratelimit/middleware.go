@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/internal/httputil"
+)
+
+// KeyFunc 计算限流的 key，返回空字符串代表这个请求不限流
+type KeyFunc func(ctx *web.Context) string
+
+// KeyByIP 按照客户端的 IP 限流
+// 使用的是 TCP 连接的对端地址，部署在代理后面的时候，应该使用 KeyByHeader("X-Real-IP") 之类的方式
+func KeyByIP() KeyFunc {
+	return func(ctx *web.Context) string {
+		host, _, err := net.SplitHostPort(ctx.Req.RemoteAddr)
+		if err != nil {
+			return ctx.Req.RemoteAddr
+		}
+		return host
+	}
+}
+
+// KeyByRoute 按照命中的路由限流，所有的客户端共享一个限额
+// 在路由上注册的时候才能拿到命中的路由，否则使用请求的路径
+func KeyByRoute() KeyFunc {
+	return func(ctx *web.Context) string {
+		route := ctx.MatchedRoute
+		if route == "" {
+			route = ctx.Req.URL.Path
+		}
+		return ctx.Req.Method + " " + route
+	}
+}
+
+// KeyByHeader 按照某个头部的值限流，例如 API Key
+// 没有这个头部的请求不限流
+func KeyByHeader(name string) KeyFunc {
+	return func(ctx *web.Context) string {
+		return ctx.Req.Header.Get(name)
+	}
+}
+
+// CombineKeys 组合多个 KeyFunc，例如同时按照 IP 和路由限流
+// 任何一个返回空字符串，都代表不限流
+func CombineKeys(fns ...KeyFunc) KeyFunc {
+	return func(ctx *web.Context) string {
+		keys := make([]string, 0, len(fns))
+		for _, fn := range fns {
+			key := fn(ctx)
+			if key == "" {
+				return ""
+			}
+			keys = append(keys, key)
+		}
+		return strings.Join(keys, ":")
+	}
+}
+
+type MiddlewareBuilder struct {
+	limiter Limiter
+	keyFunc KeyFunc
+	prefix  string
+	logFunc func(msg string, args ...any)
+}
+
+// NewMiddlewareBuilder 默认按照 IP 限流
+func NewMiddlewareBuilder(limiter Limiter) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		limiter: limiter,
+		keyFunc: KeyByIP(),
+		prefix:  "ratelimit",
+		logFunc: func(msg string, args ...any) {
+			log.Printf(msg, args...)
+		},
+	}
+}
+
+func (b *MiddlewareBuilder) KeyFunc(fn KeyFunc) *MiddlewareBuilder {
+	b.keyFunc = fn
+	return b
+}
+
+// Prefix key 的前缀，多个限流中间件共享同一个 Redis 的时候用于区分，默认是 ratelimit
+func (b *MiddlewareBuilder) Prefix(prefix string) *MiddlewareBuilder {
+	b.prefix = prefix
+	return b
+}
+
+// LogFunc 限流器出错的时候记录日志，此时请求会被放行
+func (b *MiddlewareBuilder) LogFunc(fn func(msg string, args ...any)) *MiddlewareBuilder {
+	b.logFunc = fn
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			key := b.keyFunc(ctx)
+			if key == "" {
+				next(ctx)
+				return
+			}
+			res, err := b.limiter.Allow(ctx.Req.Context(), b.prefix+":"+key)
+			if err != nil {
+				// 限流器不可用的时候放行，避免影响正常的业务
+				b.logFunc("ratelimit: 限流器出错 %v", err)
+				next(ctx)
+				return
+			}
+			remaining := res.Remaining
+			if remaining < 0 {
+				remaining = 0
+			}
+			header := ctx.Resp.Header()
+			header.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			header.Set("X-RateLimit-Reset", strconv.Itoa(httputil.Seconds(res.ResetAfter)))
+			if !res.Allowed {
+				header.Set("Retry-After", strconv.Itoa(httputil.Seconds(res.RetryAfter)))
+				ctx.RespStatusCode = http.StatusTooManyRequests
+				ctx.RespData = []byte(http.StatusText(http.StatusTooManyRequests))
+				return
+			}
+			next(ctx)
+		}
+	}
+}
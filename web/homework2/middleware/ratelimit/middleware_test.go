@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+type errLimiter struct{}
+
+func (errLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return Result{}, errors.New("redis 挂了")
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name    string
+		builder func() *MiddlewareBuilder
+		reqs    []*http.Request
+		// 最后一个请求的响应
+		wantCode   int
+		wantHeader map[string]string
+	}{
+		{
+			name: "by ip",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddlewareBuilder(NewLocalFixedWindow(1, time.Minute))
+			},
+			reqs: []*http.Request{
+				newReq("/user/1", "1.1.1.1:1234", ""),
+				newReq("/user/1", "1.1.1.1:5678", ""),
+			},
+			wantCode: http.StatusTooManyRequests,
+			wantHeader: map[string]string{
+				"X-RateLimit-Limit":     "1",
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     "60",
+				"Retry-After":           "60",
+			},
+		},
+		{
+			name: "different ip",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddlewareBuilder(NewLocalFixedWindow(1, time.Minute))
+			},
+			reqs: []*http.Request{
+				newReq("/user/1", "1.1.1.1:1234", ""),
+				newReq("/user/1", "2.2.2.2:1234", ""),
+			},
+			wantCode: http.StatusOK,
+			wantHeader: map[string]string{
+				"X-RateLimit-Limit":     "1",
+				"X-RateLimit-Remaining": "0",
+			},
+		},
+		{
+			name: "by route",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddlewareBuilder(NewLocalSlidingWindow(1, time.Minute)).KeyFunc(KeyByRoute())
+			},
+			reqs: []*http.Request{
+				newReq("/user/1", "1.1.1.1:1234", ""),
+				newReq("/user/2", "2.2.2.2:1234", ""),
+			},
+			wantCode: http.StatusTooManyRequests,
+		},
+		{
+			name: "by header",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddlewareBuilder(NewLocalTokenBucket(1, time.Minute)).KeyFunc(KeyByHeader("X-Api-Key"))
+			},
+			reqs: []*http.Request{
+				newReq("/user/1", "1.1.1.1:1234", "abc"),
+				newReq("/user/1", "2.2.2.2:1234", "abc"),
+			},
+			wantCode:   http.StatusTooManyRequests,
+			wantHeader: map[string]string{"Retry-After": "60"},
+		},
+		{
+			name: "no key",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddlewareBuilder(NewLocalFixedWindow(1, time.Minute)).KeyFunc(KeyByHeader("X-Api-Key"))
+			},
+			reqs: []*http.Request{
+				newReq("/user/1", "1.1.1.1:1234", ""),
+				newReq("/user/1", "1.1.1.1:1234", ""),
+			},
+			wantCode:   http.StatusOK,
+			wantHeader: map[string]string{"X-RateLimit-Limit": ""},
+		},
+		{
+			name: "combine",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddlewareBuilder(NewLocalFixedWindow(1, time.Minute)).
+					KeyFunc(CombineKeys(KeyByIP(), KeyByRoute()))
+			},
+			reqs: []*http.Request{
+				newReq("/user/1", "1.1.1.1:1234", ""),
+				newReq("/order", "1.1.1.1:1234", ""),
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "limiter error",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddlewareBuilder(errLimiter{}).LogFunc(func(msg string, args ...any) {})
+			},
+			reqs:     []*http.Request{newReq("/user/1", "1.1.1.1:1234", "")},
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := web.NewHTTPServer()
+			mdl := tc.builder().Build()
+			handler := func(ctx *web.Context) {
+				ctx.RespStatusCode = http.StatusOK
+			}
+			s.Get("/user/:id", handler, mdl)
+			s.Get("/order", handler, mdl)
+			var recorder *httptest.ResponseRecorder
+			for _, req := range tc.reqs {
+				recorder = httptest.NewRecorder()
+				s.ServeHTTP(recorder, req)
+			}
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			for k, v := range tc.wantHeader {
+				assert.Equal(t, v, recorder.Header().Get(k), k)
+			}
+		})
+	}
+}
+
+func newReq(path string, remoteAddr string, apiKey string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	if apiKey != "" {
+		req.Header.Set("X-Api-Key", apiKey)
+	}
+	return req
+}
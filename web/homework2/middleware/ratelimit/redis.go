@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+)
+
+var (
+	//go:embed lua/fixed_window.lua
+	luaFixedWindow string
+	//go:embed lua/sliding_window.lua
+	luaSlidingWindow string
+	//go:embed lua/token_bucket.lua
+	luaTokenBucket string
+
+	errUnexpectedResult = errors.New("ratelimit: Lua 脚本返回了非预期的结果")
+)
+
+// RedisFixedWindow 基于 Redis 的固定窗口限流器
+type RedisFixedWindow struct {
+	client redis.Cmdable
+	limit  int
+	window time.Duration
+}
+
+func NewRedisFixedWindow(client redis.Cmdable, limit int, window time.Duration) *RedisFixedWindow {
+	return &RedisFixedWindow{
+		client: client,
+		limit:  limit,
+		window: window,
+	}
+}
+
+func (r *RedisFixedWindow) Allow(ctx context.Context, key string) (Result, error) {
+	vals, err := evalInts(ctx, r.client, luaFixedWindow, key, 2, r.window.Milliseconds())
+	if err != nil {
+		return Result{}, err
+	}
+	cnt, ttl := int(vals[0]), time.Duration(vals[1])*time.Millisecond
+	res := Result{
+		Allowed:    cnt <= r.limit,
+		Limit:      r.limit,
+		Remaining:  r.limit - cnt,
+		ResetAfter: ttl,
+	}
+	if !res.Allowed {
+		res.Remaining = 0
+		res.RetryAfter = ttl
+	}
+	return res, nil
+}
+
+// RedisSlidingWindow 基于 Redis 有序集合的滑动窗口限流器
+type RedisSlidingWindow struct {
+	client redis.Cmdable
+	limit  int
+	window time.Duration
+}
+
+func NewRedisSlidingWindow(client redis.Cmdable, limit int, window time.Duration) *RedisSlidingWindow {
+	return &RedisSlidingWindow{
+		client: client,
+		limit:  limit,
+		window: window,
+	}
+}
+
+func (r *RedisSlidingWindow) Allow(ctx context.Context, key string) (Result, error) {
+	vals, err := evalInts(ctx, r.client, luaSlidingWindow, key, 3,
+		r.window.Milliseconds(), r.limit, uuid.New().String())
+	if err != nil {
+		return Result{}, err
+	}
+	wait := time.Duration(vals[2]) * time.Millisecond
+	res := Result{
+		Allowed:    vals[0] == 1,
+		Limit:      r.limit,
+		Remaining:  r.limit - int(vals[1]),
+		ResetAfter: r.window,
+	}
+	if !res.Allowed {
+		res.RetryAfter = wait
+	}
+	return res, nil
+}
+
+// RedisTokenBucket 基于 Redis 的令牌桶限流器
+type RedisTokenBucket struct {
+	client   redis.Cmdable
+	capacity int
+	interval time.Duration
+}
+
+func NewRedisTokenBucket(client redis.Cmdable, capacity int, interval time.Duration) *RedisTokenBucket {
+	return &RedisTokenBucket{
+		client:   client,
+		capacity: capacity,
+		interval: interval,
+	}
+}
+
+func (r *RedisTokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	vals, err := evalInts(ctx, r.client, luaTokenBucket, key, 4,
+		r.capacity, r.interval.Milliseconds())
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    vals[0] == 1,
+		Limit:      r.capacity,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Millisecond,
+		ResetAfter: time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
+
+// evalInts 执行脚本，脚本返回的是 n 个整数
+func evalInts(ctx context.Context, client redis.Cmdable, script string, key string, n int, args ...any) ([]int64, error) {
+	res, err := client.Eval(ctx, script, []string{key}, args...).Result()
+	if err != nil {
+		return nil, err
+	}
+	vals, ok := res.([]any)
+	if !ok || len(vals) != n {
+		return nil, errUnexpectedResult
+	}
+	ints := make([]int64, 0, n)
+	for _, val := range vals {
+		i, ok := val.(int64)
+		if !ok {
+			return nil, errUnexpectedResult
+		}
+		ints = append(ints, i)
+	}
+	return ints, nil
+}
@@ -0,0 +1,49 @@
+//go:build e2e
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 需要本地启动 Redis：go test -tags=e2e ./...
+func TestRedisLimiters(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	testCases := []struct {
+		name    string
+		limiter Limiter
+	}{
+		{name: "fixed window", limiter: NewRedisFixedWindow(client, 2, time.Second)},
+		{name: "sliding window", limiter: NewRedisSlidingWindow(client, 2, time.Second)},
+		{name: "token bucket", limiter: NewRedisTokenBucket(client, 2, 500*time.Millisecond)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "ratelimit:e2e:" + tc.name
+			require.NoError(t, client.Del(ctx, key).Err())
+
+			for i := 0; i < 2; i++ {
+				res, err := tc.limiter.Allow(ctx, key)
+				require.NoError(t, err)
+				assert.True(t, res.Allowed)
+				assert.Equal(t, 1-i, res.Remaining)
+			}
+			res, err := tc.limiter.Allow(ctx, key)
+			require.NoError(t, err)
+			assert.False(t, res.Allowed)
+			assert.Greater(t, res.RetryAfter, time.Duration(0))
+
+			time.Sleep(res.RetryAfter + 50*time.Millisecond)
+			res, err = tc.limiter.Allow(ctx, key)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+		})
+	}
+}
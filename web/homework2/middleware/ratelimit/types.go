@@ -0,0 +1,26 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limiter 限流器，限流的状态保存在它的实现里面
+// 本地实现只能限制单个实例，Redis 实现可以在多个实例之间共享状态
+type Limiter interface {
+	// Allow 判断 key 的这一次请求能否通过
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// Result 限流的结果
+type Result struct {
+	Allowed bool
+	// Limit 窗口内允许的请求数，令牌桶则是桶的容量
+	Limit int
+	// Remaining 还剩余多少次请求
+	Remaining int
+	// RetryAfter 被拒绝的时候，多久之后可以重试
+	RetryAfter time.Duration
+	// ResetAfter 多久之后限流状态完全恢复
+	ResetAfter time.Duration
+}
@@ -0,0 +1,329 @@
+package respcache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	cache "gitee.com/geektime-geekbang/geektime-go/cache/homework1"
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// RouteConfig 单个路由的缓存配置
+type RouteConfig struct {
+	// TTL 缓存的过期时间，为 0 的时候使用 MiddlewareBuilder 的默认值
+	TTL time.Duration
+	// Query 参与构造缓存 key 的查询参数，其余的查询参数会被忽略，即便它们会影响响应
+	// 为空的时候全部查询参数都会参与构造缓存 key
+	Query []string
+	// Tags 用于批量失效，例如 users
+	Tags []string
+	// Shared 响应和请求者的身份无关，可以在不同的用户之间共享
+	// 默认情况下，带有 Authorization 或者 Cookie 的请求不会读写缓存，设置了 Vary 的响应也不会被缓存
+	Shared bool
+}
+
+// MiddlewareBuilder 缓存 GET 请求的响应，包括响应码、handler 设置的头部和 RespData
+// 缓存 key 由命中的路由、路径参数和查询参数组成，所以它只能注册在路由上：
+//
+//	s.Get("/users/:id", handler, b.Build())
+//
+// 没有命中路由的请求不会读写缓存。
+// 带有 Authorization 或者 Cookie 的请求，以及设置了 Vary 的响应，只有 RouteConfig.Shared 的时候才会缓存。
+// 请求带上 Cache-Control: no-cache 的时候会跳过缓存直接执行 handler，并且刷新缓存；
+// 带上 no-store 的时候既不读缓存也不写缓存。
+// 同一个 key 的并发请求只会有一个执行 handler，其余的请求共享它的结果
+type MiddlewareBuilder struct {
+	c      cache.Cache
+	prefix string
+	ttl    time.Duration
+	routes map[string]RouteConfig
+	group  singleflight.Group
+	// LogFunc 记录缓存读写的错误，缓存出错的时候请求会直接交给 handler 处理
+	logFunc func(ctx *web.Context, err error)
+
+	// index 记录写入过的 key，用于按照标签或者路径前缀失效
+	// 它保存在进程内，多个实例共享同一个缓存的时候，需要在每一个实例上调用失效方法
+	mutex     sync.Mutex
+	index     map[string]indexEntry
+	nextSweep int
+	now       func() time.Time
+}
+
+type indexEntry struct {
+	path     string
+	tags     []string
+	expireAt time.Time
+}
+
+// entry 缓存的响应
+type entry struct {
+	StatusCode int                 `json:"status_code"`
+	Header     map[string][]string `json:"header,omitempty"`
+	Data       []byte              `json:"data,omitempty"`
+}
+
+func NewMiddlewareBuilder(c cache.Cache, ttl time.Duration) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		c:      c,
+		prefix: "resp",
+		ttl:    ttl,
+		routes: make(map[string]RouteConfig, 4),
+		logFunc: func(ctx *web.Context, err error) {
+			// 默认忽略
+		},
+		index:     make(map[string]indexEntry, 64),
+		nextSweep: 64,
+		now:       time.Now,
+	}
+}
+
+// Prefix 缓存 key 的前缀，默认是 resp
+func (b *MiddlewareBuilder) Prefix(prefix string) *MiddlewareBuilder {
+	b.prefix = prefix
+	return b
+}
+
+// Route 设置路由的缓存配置，route 是注册路由时使用的路径，例如 /users/:id
+func (b *MiddlewareBuilder) Route(route string, cfg RouteConfig) *MiddlewareBuilder {
+	b.routes[route] = cfg
+	return b
+}
+
+func (b *MiddlewareBuilder) LogFunc(fn func(ctx *web.Context, err error)) *MiddlewareBuilder {
+	b.logFunc = fn
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			method := ctx.Req.Method
+			if method != http.MethodGet && method != http.MethodHead {
+				next(ctx)
+				return
+			}
+			// 注册在 Server 上的时候还没有路由信息，无法区分不同的路由
+			if ctx.MatchedRoute == "" {
+				next(ctx)
+				return
+			}
+			cc := ctx.Req.Header.Get("Cache-Control")
+			if hasDirective(cc, "no-store") {
+				next(ctx)
+				return
+			}
+			cfg := b.routes[ctx.MatchedRoute]
+			if !cfg.Shared && hasCredentials(ctx.Req) {
+				next(ctx)
+				return
+			}
+			key := b.key(ctx, cfg)
+
+			if !hasDirective(cc, "no-cache") {
+				if val, err := b.c.Get(ctx.Req.Context(), key); err == nil {
+					var e entry
+					if err = json.Unmarshal(val, &e); err == nil {
+						b.apply(ctx, &e)
+						ctx.Resp.Header().Set("X-Cache", "HIT")
+						return
+					}
+					b.logFunc(ctx, err)
+				}
+			}
+
+			executed := false
+			val, _, _ := b.group.Do(key, func() (any, error) {
+				executed = true
+				return b.load(ctx, next, key, cfg), nil
+			})
+			if executed {
+				ctx.Resp.Header().Set("X-Cache", "MISS")
+				return
+			}
+			e, _ := val.(*entry)
+			if e == nil {
+				// 响应不能缓存，只能自己执行一遍 handler
+				next(ctx)
+				return
+			}
+			b.apply(ctx, e)
+			ctx.Resp.Header().Set("X-Cache", "HIT")
+		}
+	}
+}
+
+// load 执行 handler，响应可以缓存的时候写入缓存并返回
+func (b *MiddlewareBuilder) load(ctx *web.Context, next web.HandleFunc, key string, cfg RouteConfig) *entry {
+	header := ctx.Resp.Header()
+	before := header.Clone()
+	next(ctx)
+	if ctx.Streaming() || (ctx.RespStatusCode != 0 && ctx.RespStatusCode != http.StatusOK) {
+		return nil
+	}
+	// handler 直接通过 Resp 写了响应，例如 http.ServeContent 和 FileDownloader，
+	// 此时 RespData 里面没有响应，缓存下来的会是一个空白的响应
+	if ctx.WrittenStatus() != 0 || (ctx.RespSize() > 0 && len(ctx.RespData) == 0) {
+		return nil
+	}
+	cc := header.Get("Cache-Control")
+	if hasDirective(cc, "no-store") || hasDirective(cc, "private") || header.Get("Set-Cookie") != "" {
+		return nil
+	}
+	// 缓存 key 里面没有 Vary 指定的头部，不同的请求会拿到同一个响应
+	if !cfg.Shared && header.Get("Vary") != "" {
+		return nil
+	}
+	e := &entry{
+		StatusCode: ctx.RespStatusCode,
+		Header:     make(map[string][]string, len(header)),
+		Data:       ctx.RespData,
+	}
+	// 只缓存 handler 设置的头部，前面的中间件设置的头部，例如 X-Request-ID，每次都不一样
+	for k, vals := range header {
+		if !equal(before[k], vals) {
+			e.Header[k] = vals
+		}
+	}
+	val, err := json.Marshal(e)
+	if err == nil {
+		ttl := cfg.TTL
+		if ttl <= 0 {
+			ttl = b.ttl
+		}
+		// 即便请求被取消了也要写入缓存，因为其它请求可能在等待
+		if err = b.c.Set(context.Background(), key, val, ttl); err == nil {
+			b.record(key, indexEntry{path: ctx.Req.URL.Path, tags: cfg.Tags, expireAt: b.now().Add(ttl)})
+		}
+	}
+	if err != nil {
+		b.logFunc(ctx, err)
+	}
+	return e
+}
+
+func (b *MiddlewareBuilder) apply(ctx *web.Context, e *entry) {
+	header := ctx.Resp.Header()
+	for k, vals := range e.Header {
+		header[k] = append([]string(nil), vals...)
+	}
+	ctx.RespStatusCode = e.StatusCode
+	ctx.RespData = e.Data
+}
+
+// key 的格式是 prefix:route?参数，查询参数带上 q. 前缀，参数按照名字排序，例如
+// resp:/users/:id?id=123&q.page=1
+func (b *MiddlewareBuilder) key(ctx *web.Context, cfg RouteConfig) string {
+	query := ctx.Req.URL.Query()
+	vals := make(url.Values, len(ctx.PathParams)+len(query))
+	for k, v := range ctx.PathParams {
+		vals.Set(k, v)
+	}
+	if len(cfg.Query) > 0 {
+		for _, k := range cfg.Query {
+			if v, ok := query[k]; ok {
+				vals["q."+k] = v
+			}
+		}
+	} else {
+		for k, v := range query {
+			vals["q."+k] = v
+		}
+	}
+	// Encode 会按照 key 排序
+	return b.prefix + ":" + ctx.MatchedRoute + "?" + vals.Encode()
+}
+
+func (b *MiddlewareBuilder) record(key string, ie indexEntry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.index[key] = ie
+	if len(b.index) < b.nextSweep {
+		return
+	}
+	now := b.now()
+	for k, v := range b.index {
+		if now.After(v.expireAt) {
+			delete(b.index, k)
+		}
+	}
+	b.nextSweep = 2 * len(b.index)
+	if b.nextSweep < 64 {
+		b.nextSweep = 64
+	}
+}
+
+// InvalidateTags 删除带有任意一个标签的缓存
+func (b *MiddlewareBuilder) InvalidateTags(ctx context.Context, tags ...string) error {
+	return b.invalidate(ctx, func(ie indexEntry) bool {
+		for _, tag := range ie.tags {
+			for _, t := range tags {
+				if tag == t {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// InvalidatePrefix 删除请求路径以 prefix 开头的缓存，例如 /users/123
+func (b *MiddlewareBuilder) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return b.invalidate(ctx, func(ie indexEntry) bool {
+		return strings.HasPrefix(ie.path, prefix)
+	})
+}
+
+func (b *MiddlewareBuilder) invalidate(ctx context.Context, match func(ie indexEntry) bool) error {
+	b.mutex.Lock()
+	keys := make([]string, 0, 8)
+	for k, ie := range b.index {
+		if match(ie) {
+			keys = append(keys, k)
+			delete(b.index, k)
+		}
+	}
+	b.mutex.Unlock()
+	var res error
+	for _, k := range keys {
+		// 删除失败的缓存只能等待过期，继续删除其它的缓存
+		if err := b.c.Delete(ctx, k); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+// hasCredentials 判断请求有没有携带身份信息，这种请求的响应通常是属于某个用户的
+func hasCredentials(req *http.Request) bool {
+	return req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != ""
+}
+
+// hasDirective 判断 Cache-Control 里面有没有 directive
+func hasDirective(cacheControl string, directive string) bool {
+	for _, d := range strings.Split(cacheControl, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(name, directive) {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,312 @@
+package respcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	c := newMapCache()
+	b := NewMiddlewareBuilder(c, time.Minute).
+		Route("/users/:id", RouteConfig{TTL: time.Second, Query: []string{"fields"}, Tags: []string{"users"}})
+	var calls int64
+	s := web.NewHTTPServer()
+	s.Get("/users/:id", func(ctx *web.Context) {
+		n := atomic.AddInt64(&calls, 1)
+		ctx.Resp.Header().Set("Content-Type", "application/json")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(fmt.Sprintf(`{"id":"%s","n":%d}`, ctx.PathParams["id"], n))
+	}, b.Build())
+	s.Get("/orders/:id", func(ctx *web.Context) {
+		atomic.AddInt64(&calls, 1)
+		ctx.RespStatusCode = http.StatusNotFound
+	}, b.Build())
+	s.Get("/private", func(ctx *web.Context) {
+		atomic.AddInt64(&calls, 1)
+		ctx.Resp.Header().Set("Cache-Control", "private")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("private")
+	}, b.Build())
+	s.Get("/download", func(ctx *web.Context) {
+		atomic.AddInt64(&calls, 1)
+		http.ServeContent(ctx.Resp, ctx.Req, "a.txt", time.Time{}, strings.NewReader("download"))
+	}, b.Build())
+
+	testCases := []struct {
+		name         string
+		path         string
+		cacheControl string
+		wantCalls    int64
+		wantXCache   string
+		wantBody     string
+	}{
+		{name: "miss", path: "/users/1?fields=name", wantCalls: 1, wantXCache: "MISS", wantBody: `{"id":"1","n":1}`},
+		{name: "hit", path: "/users/1?fields=name", wantCalls: 1, wantXCache: "HIT", wantBody: `{"id":"1","n":1}`},
+		{name: "ignored query", path: "/users/1?fields=name&t=123", wantCalls: 1, wantXCache: "HIT", wantBody: `{"id":"1","n":1}`},
+		{name: "other query", path: "/users/1?fields=age", wantCalls: 2, wantXCache: "MISS", wantBody: `{"id":"1","n":2}`},
+		{name: "other param", path: "/users/2?fields=name", wantCalls: 3, wantXCache: "MISS", wantBody: `{"id":"2","n":3}`},
+		{name: "no-store", path: "/users/1?fields=name", cacheControl: "no-store", wantCalls: 4, wantBody: `{"id":"1","n":4}`},
+		{name: "still cached", path: "/users/1?fields=name", wantCalls: 4, wantXCache: "HIT", wantBody: `{"id":"1","n":1}`},
+		{name: "no-cache", path: "/users/1?fields=name", cacheControl: "no-cache", wantCalls: 5, wantXCache: "MISS", wantBody: `{"id":"1","n":5}`},
+		{name: "refreshed", path: "/users/1?fields=name", wantCalls: 5, wantXCache: "HIT", wantBody: `{"id":"1","n":5}`},
+		{name: "not found", path: "/orders/1", wantCalls: 6, wantXCache: "MISS"},
+		{name: "not found not cached", path: "/orders/1", wantCalls: 7, wantXCache: "MISS"},
+		{name: "private", path: "/private", wantCalls: 8, wantXCache: "MISS", wantBody: "private"},
+		{name: "private not cached", path: "/private", wantCalls: 9, wantXCache: "MISS", wantBody: "private"},
+		{name: "written directly", path: "/download", wantCalls: 10, wantXCache: "MISS", wantBody: "download"},
+		{name: "written directly not cached", path: "/download", wantCalls: 11, wantXCache: "MISS", wantBody: "download"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.cacheControl != "" {
+				req.Header.Set("Cache-Control", tc.cacheControl)
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCalls, atomic.LoadInt64(&calls))
+			assert.Equal(t, tc.wantXCache, recorder.Header().Get("X-Cache"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			if tc.wantBody != "" && tc.path != "/private" && tc.path != "/download" {
+				assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+	assert.Equal(t, time.Second, c.ttl["resp:/users/:id?id=1&q.fields=name"])
+}
+
+func TestMiddlewareBuilder_SingleFlight(t *testing.T) {
+	b := NewMiddlewareBuilder(newMapCache(), time.Minute)
+	var calls int64
+	start := make(chan struct{})
+	s := web.NewHTTPServer()
+	s.Get("/slow", func(ctx *web.Context) {
+		atomic.AddInt64(&calls, 1)
+		<-start
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("slow")
+	}, b.Build())
+
+	const n = 10
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			bodies[i] = recorder.Body.String()
+		}(i)
+	}
+	// 等待请求都进入 singleflight
+	time.Sleep(100 * time.Millisecond)
+	close(start)
+	wg.Wait()
+	assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+	for _, body := range bodies {
+		assert.Equal(t, "slow", body)
+	}
+}
+
+func TestMiddlewareBuilder_Invalidate(t *testing.T) {
+	c := newMapCache()
+	b := NewMiddlewareBuilder(c, time.Minute).
+		Route("/users/:id", RouteConfig{Tags: []string{"users"}}).
+		Route("/orders/:id", RouteConfig{Tags: []string{"orders"}})
+	s := web.NewHTTPServer()
+	handler := func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(ctx.Req.URL.Path)
+	}
+	s.Get("/users/:id", handler, b.Build())
+	s.Get("/orders/:id", handler, b.Build())
+	warmUp := func() {
+		for _, path := range []string{"/users/1", "/users/2", "/orders/1"} {
+			s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+	}
+
+	warmUp()
+	require.Equal(t, 3, c.len())
+	require.NoError(t, b.InvalidatePrefix(context.Background(), "/users/1"))
+	assert.Equal(t, []string{"resp:/orders/:id?id=1", "resp:/users/:id?id=2"}, c.keys())
+
+	warmUp()
+	require.NoError(t, b.InvalidateTags(context.Background(), "users"))
+	assert.Equal(t, []string{"resp:/orders/:id?id=1"}, c.keys())
+
+	warmUp()
+	c.deleteErr = errors.New("mock error")
+	assert.Equal(t, c.deleteErr, b.InvalidatePrefix(context.Background(), "/"))
+}
+
+func TestMiddlewareBuilder_Key(t *testing.T) {
+	c := newMapCache()
+	b := NewMiddlewareBuilder(c, time.Minute)
+	var calls int64
+	s := web.NewHTTPServer()
+	s.Use(b.Build())
+	s.Get("/search/:kind", func(ctx *web.Context) {
+		atomic.AddInt64(&calls, 1)
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(ctx.Req.URL.RawQuery)
+	}, b.Build())
+
+	testCases := []struct {
+		name       string
+		path       string
+		wantCalls  int64
+		wantXCache string
+		wantBody   string
+	}{
+		{name: "miss", path: "/search/web?q=go&page=1", wantCalls: 1, wantXCache: "MISS", wantBody: "q=go&page=1"},
+		// 没有配置 Query 的时候使用全部查询参数，与顺序无关
+		{name: "reordered", path: "/search/web?page=1&q=go", wantCalls: 1, wantXCache: "HIT", wantBody: "q=go&page=1"},
+		{name: "other query", path: "/search/web?q=go&page=2", wantCalls: 2, wantXCache: "MISS", wantBody: "q=go&page=2"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCalls, atomic.LoadInt64(&calls))
+			assert.Equal(t, tc.wantXCache, recorder.Header().Get("X-Cache"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+	// 注册在 Server 上的 middleware 没有路由信息，不会写入缓存
+	assert.Equal(t, []string{
+		"resp:/search/:kind?kind=web&q.page=1&q.q=go",
+		"resp:/search/:kind?kind=web&q.page=2&q.q=go",
+	}, c.keys())
+}
+
+func TestMiddlewareBuilder_Credentials(t *testing.T) {
+	b := NewMiddlewareBuilder(newMapCache(), time.Minute).
+		Route("/shared", RouteConfig{Shared: true})
+	var calls int64
+	handler := func(ctx *web.Context) {
+		n := atomic.AddInt64(&calls, 1)
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(fmt.Sprintf("%d", n))
+	}
+	s := web.NewHTTPServer()
+	s.Get("/profile", handler, b.Build())
+	s.Get("/shared", handler, b.Build())
+	s.Get("/vary", func(ctx *web.Context) {
+		ctx.Resp.Header().Set("Vary", "Accept-Language")
+		handler(ctx)
+	}, b.Build())
+
+	testCases := []struct {
+		name       string
+		path       string
+		header     http.Header
+		wantXCache string
+		wantBody   string
+	}{
+		{name: "authorization", path: "/profile", header: http.Header{"Authorization": {"Bearer a"}}, wantBody: "1"},
+		// 带有身份信息的请求不会写入缓存，也不会读取缓存
+		{name: "cookie", path: "/profile", header: http.Header{"Cookie": {"sid=b"}}, wantBody: "2"},
+		{name: "anonymous", path: "/profile", wantXCache: "MISS", wantBody: "3"},
+		{name: "anonymous hit", path: "/profile", wantXCache: "HIT", wantBody: "3"},
+		{name: "cookie not hit", path: "/profile", header: http.Header{"Cookie": {"sid=b"}}, wantBody: "4"},
+		{name: "shared", path: "/shared", header: http.Header{"Authorization": {"Bearer a"}}, wantXCache: "MISS", wantBody: "5"},
+		{name: "shared hit", path: "/shared", header: http.Header{"Cookie": {"sid=b"}}, wantXCache: "HIT", wantBody: "5"},
+		{name: "vary", path: "/vary", wantXCache: "MISS", wantBody: "6"},
+		{name: "vary not cached", path: "/vary", wantXCache: "MISS", wantBody: "7"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantXCache, recorder.Header().Get("X-Cache"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+// mapCache 测试用的 cache.Cache，忽略过期时间
+type mapCache struct {
+	mutex     sync.Mutex
+	data      map[string][]byte
+	ttl       map[string]time.Duration
+	deleteErr error
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{data: map[string][]byte{}, ttl: map[string]time.Duration{}}
+}
+
+func (m *mapCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	val, ok := m.data[key]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
+	return val, nil
+}
+
+func (m *mapCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data[key] = val
+	m.ttl[key] = expiration
+	return nil
+}
+
+func (m *mapCache) Delete(ctx context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.deleteErr != nil {
+		return m.deleteErr
+	}
+	delete(m.data, key)
+	return nil
+}
+
+func (m *mapCache) LoadAndDelete(ctx context.Context, key string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	val := m.data[key]
+	delete(m.data, key)
+	return val, nil
+}
+
+func (m *mapCache) OnEvicted(func(key string, val []byte)) {}
+
+func (m *mapCache) len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.data)
+}
+
+func (m *mapCache) keys() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	res := make([]string, 0, len(m.data))
+	for k := range m.data {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
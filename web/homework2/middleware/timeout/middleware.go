@@ -0,0 +1,167 @@
+package timeout
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// MiddlewareBuilder 给请求设置超时时间
+// 超时时间会被设置到 ctx.Req.Context() 上，handler 应该监听它；
+// 超时之后中间件会立刻返回 503，handler 之后写入的响应都会被丢弃。
+// 通常把它注册在路由上，这样不同的路由可以有不同的超时时间
+type MiddlewareBuilder struct {
+	timeout    time.Duration
+	statusCode int
+	message    []byte
+}
+
+func NewMiddlewareBuilder(timeout time.Duration) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		timeout:    timeout,
+		statusCode: http.StatusServiceUnavailable,
+		message:    []byte("请求超时"),
+	}
+}
+
+// StatusCode 超时的响应码，默认是 503，也可以使用 504
+func (b *MiddlewareBuilder) StatusCode(code int) *MiddlewareBuilder {
+	b.statusCode = code
+	return b
+}
+
+// Message 超时的响应数据
+func (b *MiddlewareBuilder) Message(msg string) *MiddlewareBuilder {
+	b.message = []byte(msg)
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			c, cancel := context.WithTimeout(ctx.Req.Context(), b.timeout)
+			defer cancel()
+
+			resp, req := ctx.Resp, ctx.Req
+			tw := &timeoutWriter{ResponseWriter: resp, header: resp.Header().Clone()}
+			// handler 使用 Context 的副本，超时之后它的修改不会影响到真正的响应
+			tctx := *ctx
+			tctx.Req = req.WithContext(c)
+			tctx.Resp = tw
+
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+				next(&tctx)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				// 在当前 goroutine 里面重新 panic，交给 recovery 之类的中间件处理
+				panic(p)
+			case <-done:
+				tw.mutex.Lock()
+				defer tw.mutex.Unlock()
+				*ctx = tctx
+				ctx.Req, ctx.Resp = req, resp
+				if !tw.wroteHeader {
+					copyHeader(resp.Header(), tw.header)
+				}
+			case <-c.Done():
+				tw.mutex.Lock()
+				defer tw.mutex.Unlock()
+				tw.timedOut = true
+				// handler 还在使用 tctx，它和 ctx 共享了路径参数之类的数据，所以 ctx 不能被复用
+				ctx.Retain()
+				if tw.wroteHeader || !errors.Is(c.Err(), context.DeadlineExceeded) {
+					// 已经开始写响应，或者是客户端断开了连接，都没有办法再响应了
+					return
+				}
+				ctx.RespStatusCode = b.statusCode
+				ctx.RespData = b.message
+			}
+		}
+	}
+}
+
+// timeoutWriter 超时之后丢弃所有的写入
+// handler 设置的头部会先缓存起来，直到 handler 直接写入响应或者正常结束
+type timeoutWriter struct {
+	http.ResponseWriter
+	header http.Header
+
+	mutex       sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.timedOut {
+		return
+	}
+	w.writeHeader()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) Write(bs []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.writeHeader()
+	return w.ResponseWriter.Write(bs)
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.timedOut {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.writeHeader()
+		f.Flush()
+	}
+}
+
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("timeout: 设置了超时的请求不支持 Hijack")
+}
+
+// writeHeader 第一次直接写入响应的时候，把缓存的头部复制过去
+func (w *timeoutWriter) writeHeader() {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		copyHeader(w.ResponseWriter.Header(), w.header)
+	}
+}
+
+func copyHeader(dst http.Header, src http.Header) {
+	for k := range dst {
+		if _, ok := src[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+}
@@ -0,0 +1,88 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/middleware/recovery"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	// 超时之后 handler 还在运行，等它结束之后检查它的写入是否被丢弃
+	lateWrite := make(chan error, 1)
+
+	s := web.NewHTTPServer()
+	s.Use((&recovery.MiddlewareBuilder{
+		StatusCode: http.StatusInternalServerError,
+		ErrMsg:     "panic",
+		LogFunc:    func(ctx *web.Context) {},
+	}).Build())
+	s.Get("/fast", func(ctx *web.Context) {
+		ctx.Resp.Header().Set("X-Handler", "fast")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("fast")
+	}, NewMiddlewareBuilder(time.Second).Build())
+	s.Get("/deadline", func(ctx *web.Context) {
+		deadline, ok := ctx.Req.Context().Deadline()
+		ctx.RespStatusCode = http.StatusOK
+		if ok && time.Until(deadline) <= time.Second {
+			ctx.RespData = []byte("has deadline")
+		}
+	}, NewMiddlewareBuilder(time.Second).Build())
+	s.Get("/slow", func(ctx *web.Context) {
+		time.Sleep(100 * time.Millisecond)
+		ctx.Resp.Header().Set("X-Handler", "slow")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("slow")
+		_, err := ctx.Resp.Write([]byte("slow"))
+		lateWrite <- err
+	}, NewMiddlewareBuilder(10*time.Millisecond).StatusCode(http.StatusGatewayTimeout).Build())
+	s.Get("/ctx", func(ctx *web.Context) {
+		<-ctx.Req.Context().Done()
+	}, NewMiddlewareBuilder(10*time.Millisecond).Build())
+	s.Get("/panic", func(ctx *web.Context) {
+		panic("boom")
+	}, NewMiddlewareBuilder(time.Second).Build())
+
+	testCases := []struct {
+		name       string
+		path       string
+		wantCode   int
+		wantBody   string
+		wantHeader string
+	}{
+		{name: "fast", path: "/fast", wantCode: http.StatusOK, wantBody: "fast", wantHeader: "fast"},
+		{name: "deadline", path: "/deadline", wantCode: http.StatusOK, wantBody: "has deadline"},
+		{name: "slow", path: "/slow", wantCode: http.StatusGatewayTimeout, wantBody: "请求超时"},
+		{name: "context done", path: "/ctx", wantCode: http.StatusServiceUnavailable, wantBody: "请求超时"},
+		{name: "panic", path: "/panic", wantCode: http.StatusInternalServerError, wantBody: "panic"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantHeader, recorder.Header().Get("X-Handler"))
+		})
+	}
+	assert.Equal(t, http.ErrHandlerTimeout, <-lateWrite)
+}
+
+func TestMiddlewareBuilder_Stream(t *testing.T) {
+	s := web.NewHTTPServer()
+	s.Get("/stream", func(ctx *web.Context) {
+		_ = ctx.WriteChunk([]byte("a"))
+		_ = ctx.WriteChunk([]byte("b"))
+	}, NewMiddlewareBuilder(time.Second).Build())
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "ab", recorder.Body.String())
+	assert.True(t, recorder.Flushed)
+}
@@ -0,0 +1,59 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// MiddlewareBuilder 将 Bind 系列方法产生的校验错误转化为统一的 JSON 响应
+// 只有在 handler 没有写入响应数据的时候才会生效，
+// 所以 handler 在绑定失败之后直接返回即可
+type MiddlewareBuilder struct {
+	statusCode int
+	message    string
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		statusCode: http.StatusBadRequest,
+		message:    "参数校验失败",
+	}
+}
+
+// StatusCode 校验失败的响应码，默认是 400
+func (m *MiddlewareBuilder) StatusCode(code int) *MiddlewareBuilder {
+	m.statusCode = code
+	return m
+}
+
+// Message 校验失败的响应中的提示信息
+func (m *MiddlewareBuilder) Message(msg string) *MiddlewareBuilder {
+	m.message = msg
+	return m
+}
+
+type errResp struct {
+	Message string               `json:"message"`
+	Errors  web.ValidationErrors `json:"errors"`
+}
+
+func (m *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			next(ctx)
+			errs := ctx.ValidationErrors()
+			if len(errs) == 0 || len(ctx.RespData) > 0 {
+				return
+			}
+			bs, err := json.Marshal(errResp{Message: m.message, Errors: errs})
+			if err != nil {
+				return
+			}
+			ctx.Resp.Header().Set("Content-Type", "application/json")
+			ctx.RespStatusCode = m.statusCode
+			ctx.RespData = bs
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	type userReq struct {
+		Page int `query:"page" validate:"min=1"`
+	}
+	s := web.NewHTTPServer()
+	s.Use(NewMiddlewareBuilder().Build())
+	s.Get("/user", func(ctx *web.Context) {
+		var req userReq
+		if err := ctx.BindQuery(&req); err != nil {
+			return
+		}
+		if err := ctx.Validate(&req); err != nil {
+			return
+		}
+		ctx.RespData = []byte("hello, world")
+	})
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantResp string
+	}{
+		{
+			name:     "valid",
+			path:     "/user?page=1",
+			wantCode: http.StatusOK,
+			wantResp: "hello, world",
+		},
+		{
+			name:     "invalid",
+			path:     "/user?page=0",
+			wantCode: http.StatusBadRequest,
+			wantResp: `{"message":"参数校验失败","errors":[{"field":"page","rule":"min","param":"1","message":"page 不能小于 1"}]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// Generator 依据 HTTPServer 的路由表生成 OpenAPI 3.0 文档
+// 路由本身只能提供路径和路径参数，请求和响应的结构来自 HTTPServer.Describe 设置的 RouteMeta
+type Generator struct {
+	info    Info
+	servers []Server
+}
+
+type GeneratorOption func(g *Generator)
+
+func NewGenerator(title string, version string, opts ...GeneratorOption) *Generator {
+	g := &Generator{
+		info: Info{Title: title, Version: version},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+func GeneratorWithDescription(desc string) GeneratorOption {
+	return func(g *Generator) {
+		g.info.Description = desc
+	}
+}
+
+// GeneratorWithServers API 的访问地址，例如 https://api.example.com
+func GeneratorWithServers(urls ...string) GeneratorOption {
+	return func(g *Generator) {
+		for _, url := range urls {
+			g.servers = append(g.servers, Server{URL: url})
+		}
+	}
+}
+
+// Generate 生成 routes 对应的文档
+func (g *Generator) Generate(routes []web.RouteInfo) *Document {
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    g.info,
+		Servers: g.servers,
+		Paths:   make(map[string]*PathItem, len(routes)),
+	}
+	sb := newSchemaBuilder()
+	for _, r := range routes {
+		path, params := convertPath(r.Route)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(r.Method)] = g.operation(sb, r, params)
+	}
+	if len(sb.schemas) > 0 {
+		doc.Components = &Components{Schemas: sb.schemas}
+	}
+	return doc
+}
+
+func (g *Generator) operation(sb *schemaBuilder, r web.RouteInfo, params []*Parameter) *Operation {
+	op := &Operation{
+		Parameters: params,
+		Responses:  map[string]*Response{"200": {Description: http.StatusText(http.StatusOK)}},
+	}
+	meta := r.Meta
+	if meta == nil {
+		return op
+	}
+	op.Summary = meta.Summary
+	op.Description = meta.Description
+	op.Tags = meta.Tags
+	op.Deprecated = meta.Deprecated
+	if meta.Request != nil {
+		g.request(sb, op, r.Method, reflect.TypeOf(meta.Request))
+	}
+	if meta.Response != nil {
+		op.Responses["200"].Content = map[string]*MediaType{
+			"application/json": {Schema: sb.schemaOf(reflect.TypeOf(meta.Response))},
+		}
+	}
+	return op
+}
+
+// 绑定使用的标签，和 web 包保持一致
+var paramTags = []struct {
+	tag string
+	in  string
+}{
+	{tag: "path", in: "path"},
+	{tag: "query", in: "query"},
+	{tag: "header", in: "header"},
+}
+
+// request 带有 path、query、header 标签的字段是参数，
+// 带有 form 标签的字段是表单，其余的字段是 JSON 请求体
+func (g *Generator) request(sb *schemaBuilder, op *Operation, method string, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	hasBody := method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+	bound, hasForm, hasFile := false, false, false
+	walkFields(t, func(fd reflect.StructField) {
+		for _, pt := range paramTags {
+			name, ok := tagName(fd, pt.tag)
+			if !ok {
+				continue
+			}
+			bound = true
+			g.addParam(sb, op, pt.in, name, fd)
+		}
+		if _, ok := tagName(fd, "form"); ok {
+			bound, hasForm = true, true
+			ft := fd.Type
+			for ft.Kind() == reflect.Pointer || ft.Kind() == reflect.Slice {
+				ft = ft.Elem()
+			}
+			hasFile = hasFile || ft == fileHeaderType
+		}
+	})
+	if !hasBody {
+		return
+	}
+	if hasForm {
+		contentType := "application/x-www-form-urlencoded"
+		if hasFile {
+			contentType = "multipart/form-data"
+		}
+		schema := sb.structSchema(t, "form", func(fd reflect.StructField) bool {
+			_, ok := tagName(fd, "form")
+			return !ok
+		})
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{contentType: {Schema: schema}}}
+		return
+	}
+	var schema *Schema
+	if !bound {
+		schema = sb.schemaOf(t)
+	} else {
+		schema = sb.structSchema(t, "json", func(fd reflect.StructField) bool {
+			for _, pt := range paramTags {
+				if _, ok := tagName(fd, pt.tag); ok {
+					return true
+				}
+			}
+			return false
+		})
+		if len(schema.Properties) == 0 {
+			return
+		}
+	}
+	op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{"application/json": {Schema: schema}}}
+}
+
+func (g *Generator) addParam(sb *schemaBuilder, op *Operation, in string, name string, fd reflect.StructField) {
+	schema := sb.schemaOf(fd.Type)
+	required := applyValidate(schema, fd.Tag.Get("validate"))
+	if in == "path" {
+		for _, p := range op.Parameters {
+			// 路由上没有类型约束的时候，使用字段的类型
+			if p.In == "path" && p.Name == name {
+				if p.Schema.Type == "string" && p.Schema.Pattern == "" && p.Schema.Format == "" {
+					p.Schema = schema
+				}
+				return
+			}
+		}
+		// 路由上没有这个参数，绑定的时候也不会有值
+		return
+	}
+	op.Parameters = append(op.Parameters, &Parameter{
+		Name:     name,
+		In:       in,
+		Required: required,
+		Schema:   schema,
+	})
+}
+
+// walkFields 遍历需要绑定的字段，没有标签的嵌套结构体会被展开，和 web 包的绑定逻辑一致
+func walkFields(t reflect.Type, fn func(fd reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		fd := t.Field(i)
+		if fd.Anonymous && fd.Type.Kind() == reflect.Struct && !hasAnyTag(fd) {
+			walkFields(fd.Type, fn)
+			continue
+		}
+		if fd.IsExported() {
+			fn(fd)
+		}
+	}
+}
+
+func hasAnyTag(fd reflect.StructField) bool {
+	for _, tag := range []string{"path", "query", "header", "form", "json"} {
+		if _, ok := fd.Tag.Lookup(tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func tagName(fd reflect.StructField, tag string) (string, bool) {
+	val, ok := fd.Tag.Lookup(tag)
+	if !ok || val == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(val, ",")
+	return name, name != ""
+}
+
+// convertPath 把路由转化为 OpenAPI 的路径模板，例如 /user/:id<int> => /user/{id}
+func convertPath(route string) (string, []*Parameter) {
+	if route == "/" {
+		return route, nil
+	}
+	segs := strings.Split(route[1:], "/")
+	var params []*Parameter
+	wildcards := 0
+	for i, seg := range segs {
+		var p *Parameter
+		switch {
+		case seg == "*":
+			// 匿名通配符没有名字，只能起一个
+			wildcards++
+			name := "wildcard"
+			if wildcards > 1 {
+				name += strconv.Itoa(wildcards)
+			}
+			p = &Parameter{Name: name, Description: "匹配任意一段路径", Schema: &Schema{Type: "string"}}
+		case seg[0] == '*':
+			p = &Parameter{Name: seg[1:], Description: "匹配剩余的所有路径", Schema: &Schema{Type: "string"}}
+		case seg[0] == ':':
+			p = pathParam(seg)
+		default:
+			continue
+		}
+		p.In, p.Required = "path", true
+		params = append(params, p)
+		segs[i] = "{" + p.Name + "}"
+	}
+	return "/" + strings.Join(segs, "/"), params
+}
+
+// pathParam 解析 :name、:name(reg_expr) 和 :name<type>
+func pathParam(seg string) *Parameter {
+	switch seg[len(seg)-1] {
+	case ')':
+		if idx := strings.IndexByte(seg, '('); idx > 0 {
+			return &Parameter{Name: seg[1:idx], Schema: &Schema{Type: "string", Pattern: seg[idx+1 : len(seg)-1]}}
+		}
+	case '>':
+		if idx := strings.IndexByte(seg, '<'); idx > 0 {
+			p := &Parameter{Name: seg[1:idx]}
+			switch seg[idx+1 : len(seg)-1] {
+			case "int":
+				p.Schema = &Schema{Type: "integer", Format: "int64"}
+			case "uint":
+				zero := 0.0
+				p.Schema = &Schema{Type: "integer", Minimum: &zero}
+			case "uuid":
+				p.Schema = &Schema{Type: "string", Format: "uuid"}
+			default:
+				p.Schema = &Schema{Type: "string"}
+			}
+			return p
+		}
+	}
+	return &Parameter{Name: seg[1:], Schema: &Schema{Type: "string"}}
+}
+
+// Register 在 path 上提供 JSON 格式的 OpenAPI 文档，例如 /openapi.json
+// 文档是通过 Server 级别的 middleware 提供的，不在路由表里面，所以 SwapRouter 之后仍然可以访问。
+// 文档根据正在使用的路由表生成，路由表被替换或者有了新的路由之后会重新生成，
+// 所以 Register 可以在注册其它路由之前调用
+func Register(s *web.HTTPServer, path string, g *Generator) {
+	var (
+		mutex  sync.Mutex
+		routes []web.RouteInfo
+		doc    *Document
+	)
+	s.Use(func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			if ctx.Req.URL.Path != path ||
+				(ctx.Req.Method != http.MethodGet && ctx.Req.Method != http.MethodHead) {
+				next(ctx)
+				return
+			}
+			current := s.Routes()
+			mutex.Lock()
+			if doc == nil || !reflect.DeepEqual(routes, current) {
+				routes, doc = current, g.Generate(current)
+			}
+			res := doc
+			mutex.Unlock()
+			if err := ctx.RespJSONOK(res); err != nil {
+				ctx.RespStatusCode = http.StatusInternalServerError
+				return
+			}
+			ctx.Resp.Header().Set("Content-Type", "application/json")
+		}
+	})
+}
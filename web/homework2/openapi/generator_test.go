@@ -0,0 +1,184 @@
+package openapi
+
+import (
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+type Address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type User struct {
+	ID       int64     `json:"id"`
+	Name     string    `json:"name" validate:"required,min=1,max=32"`
+	Email    string    `json:"email,omitempty" validate:"email"`
+	Role     string    `json:"role" validate:"oneof=admin member"`
+	Address  *Address  `json:"address"`
+	Tags     []string  `json:"tags"`
+	CreateAt time.Time `json:"create_at"`
+	password string
+}
+
+type createUserReq struct {
+	Token string `header:"X-Token" validate:"required"`
+	Name  string `json:"name" validate:"required"`
+}
+
+type listUserReq struct {
+	Page int `query:"page" validate:"min=1"`
+}
+
+type getUserReq struct {
+	ID int64 `path:"id"`
+}
+
+type uploadReq struct {
+	Avatar *multipart.FileHeader `form:"avatar"`
+	Name   string                `form:"name"`
+}
+
+func TestRegister(t *testing.T) {
+	handler := func(ctx *web.Context) {}
+	s := web.NewHTTPServer()
+	Register(s, "/openapi.json", NewGenerator("用户服务", "1.0.0",
+		GeneratorWithDescription("用户相关的接口"),
+		GeneratorWithServers("https://api.example.com")))
+	s.Get("/users", handler)
+	s.Describe(http.MethodGet, "/users", web.RouteMeta{
+		Summary: "用户列表", Tags: []string{"user"}, Request: listUserReq{}, Response: []User{},
+	})
+	s.Post("/users", handler)
+	s.Describe(http.MethodPost, "/users", web.RouteMeta{Request: &createUserReq{}, Response: &User{}})
+	s.Get("/users/:id", handler)
+	s.Describe(http.MethodGet, "/users/:id", web.RouteMeta{Request: getUserReq{}, Response: User{}, Deprecated: true})
+	s.Post("/avatar", handler)
+	s.Describe(http.MethodPost, "/avatar", web.RouteMeta{Request: uploadReq{}})
+	s.Get("/orders/:id<uuid>/*", handler)
+	s.Get("/static/*filepath", handler)
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+  "openapi": "3.0.3",
+  "info": {"title": "用户服务", "description": "用户相关的接口", "version": "1.0.0"},
+  "servers": [{"url": "https://api.example.com"}],
+  "paths": {
+    "/avatar": {
+      "post": {
+        "requestBody": {
+          "required": true,
+          "content": {"multipart/form-data": {"schema": {"type": "object", "properties": {
+            "avatar": {"type": "string", "format": "binary"},
+            "name": {"type": "string"}
+          }}}}
+        },
+        "responses": {"200": {"description": "OK"}}
+      }
+    },
+    "/orders/{id}/{wildcard}": {
+      "get": {
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string", "format": "uuid"}},
+          {"name": "wildcard", "in": "path", "required": true, "description": "匹配任意一段路径", "schema": {"type": "string"}}
+        ],
+        "responses": {"200": {"description": "OK"}}
+      }
+    },
+    "/static/{filepath}": {
+      "get": {
+        "parameters": [
+          {"name": "filepath", "in": "path", "required": true, "description": "匹配剩余的所有路径", "schema": {"type": "string"}}
+        ],
+        "responses": {"200": {"description": "OK"}}
+      }
+    },
+    "/users": {
+      "get": {
+        "summary": "用户列表",
+        "tags": ["user"],
+        "parameters": [
+          {"name": "page", "in": "query", "schema": {"type": "integer", "format": "int64", "minimum": 1}}
+        ],
+        "responses": {"200": {"description": "OK", "content": {"application/json": {"schema": {
+          "type": "array", "items": {"$ref": "#/components/schemas/User"}
+        }}}}}
+      },
+      "post": {
+        "parameters": [
+          {"name": "X-Token", "in": "header", "required": true, "schema": {"type": "string"}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"type": "object", "required": ["name"], "properties": {
+            "name": {"type": "string"}
+          }}}}
+        },
+        "responses": {"200": {"description": "OK", "content": {"application/json": {"schema": {
+          "$ref": "#/components/schemas/User"
+        }}}}}
+      }
+    },
+    "/users/{id}": {
+      "get": {
+        "deprecated": true,
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "format": "int64"}}
+        ],
+        "responses": {"200": {"description": "OK", "content": {"application/json": {"schema": {
+          "$ref": "#/components/schemas/User"
+        }}}}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Address": {"type": "object", "required": ["city"], "properties": {"city": {"type": "string"}}},
+      "User": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {
+          "id": {"type": "integer", "format": "int64"},
+          "name": {"type": "string", "minLength": 1, "maxLength": 32},
+          "email": {"type": "string", "format": "email"},
+          "role": {"type": "string", "enum": ["admin", "member"]},
+          "address": {"$ref": "#/components/schemas/Address"},
+          "tags": {"type": "array", "items": {"type": "string"}},
+          "create_at": {"type": "string", "format": "date-time"}
+        }
+      }
+    }
+  }
+}`, recorder.Body.String())
+}
+
+func TestRegister_SwapRouter(t *testing.T) {
+	handler := func(ctx *web.Context) {}
+	s := web.NewHTTPServer()
+	Register(s, "/openapi.json", NewGenerator("用户服务", "1.0.0"))
+	s.Get("/users", handler)
+
+	paths := func() string {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		return recorder.Body.String()
+	}
+	assert.Contains(t, paths(), `"/users"`)
+
+	r := web.NewRouter()
+	assert.NoError(t, r.Handle(http.MethodGet, "/orders", handler))
+	s.SwapRouter(r)
+	body := paths()
+	assert.Contains(t, body, `"/orders"`)
+	assert.NotContains(t, body, `"/users"`)
+}
@@ -0,0 +1,208 @@
+package openapi
+
+import (
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+	bytesType      = reflect.TypeOf([]byte(nil))
+)
+
+// schemaBuilder 通过反射生成 Schema
+// 有名字的结构体会被放到 components 里面，使用 $ref 引用
+type schemaBuilder struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas: make(map[string]*Schema, 16),
+		names:   make(map[reflect.Type]string, 16),
+	}
+}
+
+func (b *schemaBuilder) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64"}
+	case fileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	case bytesType:
+		// encoding/json 把 []byte 编码为 base64 字符串
+		return &Schema{Type: "string", Format: "byte"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t, "json", nil)
+		}
+		return &Schema{Ref: "#/components/schemas/" + b.register(t)}
+	}
+	// interface 之类的类型，可以是任意值
+	return &Schema{}
+}
+
+// register 把结构体放到 components 里面，返回它的名字
+// 不同的包里面可能有同名的结构体，这种时候会在名字后面加上数字
+func (b *schemaBuilder) register(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	for i := 2; ; i++ {
+		if _, ok := b.schemas[name]; !ok {
+			break
+		}
+		name = t.Name() + strconv.Itoa(i)
+	}
+	b.names[t] = name
+	// 先占位，避免递归的结构体死循环
+	b.schemas[name] = &Schema{}
+	*b.schemas[name] = *b.structSchema(t, "json", nil)
+	return name
+}
+
+// structSchema 使用 tag 标签里面的名字作为属性名，没有标签的时候使用字段名
+// skip 返回 true 的字段会被忽略
+func (b *schemaBuilder) structSchema(t reflect.Type, tag string, skip func(fd reflect.StructField) bool) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema, t.NumField())}
+	b.collectFields(s, t, tag, skip)
+	return s
+}
+
+func (b *schemaBuilder) collectFields(s *Schema, t reflect.Type, tag string, skip func(fd reflect.StructField) bool) {
+	for i := 0; i < t.NumField(); i++ {
+		fd := t.Field(i)
+		key, hasTag := fd.Tag.Lookup(tag)
+		// 没有标签的嵌套结构体，它的字段会被平铺，和 encoding/json 的行为一致
+		if fd.Anonymous && !hasTag {
+			ft := fd.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.collectFields(s, ft, tag, skip)
+				continue
+			}
+		}
+		if !fd.IsExported() || key == "-" || (skip != nil && skip(fd)) {
+			continue
+		}
+		name, _, _ := strings.Cut(key, ",")
+		if name == "" {
+			name = fd.Name
+		}
+		fs := b.schemaOf(fd.Type)
+		if applyValidate(fs, fd.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// applyValidate 把 validate 标签转化为 Schema 的约束，返回字段是否必填
+// $ref 不能和其它约束同时使用，所以引用结构体的时候只处理 required
+func applyValidate(s *Schema, tag string) bool {
+	required := false
+	for _, r := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(r, "=")
+		if name == "required" {
+			required = true
+			continue
+		}
+		if s.Ref != "" {
+			continue
+		}
+		switch name {
+		case "min", "max", "len":
+			applyRange(s, name, param)
+		case "oneof":
+			for _, opt := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(s.Type, opt))
+			}
+		case "regexp":
+			s.Pattern = param
+		case "email":
+			s.Format = "email"
+		}
+	}
+	return required
+}
+
+func applyRange(s *Schema, rule string, param string) {
+	switch s.Type {
+	case "integer", "number":
+		f, err := strconv.ParseFloat(param, 64)
+		// len 对数字没有意义
+		if err != nil || rule == "len" {
+			return
+		}
+		if rule != "max" {
+			s.Minimum = &f
+		}
+		if rule != "min" {
+			s.Maximum = &f
+		}
+	case "string", "array":
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+		minPtr, maxPtr := &s.MinLength, &s.MaxLength
+		if s.Type == "array" {
+			minPtr, maxPtr = &s.MinItems, &s.MaxItems
+		}
+		if rule != "max" {
+			*minPtr = &n
+		}
+		if rule != "min" {
+			*maxPtr = &n
+		}
+	}
+}
+
+func enumValue(typ string, opt string) any {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(opt, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(opt, 64); err == nil {
+			return f
+		}
+	}
+	return opt
+}
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"sort"
+	"strings"
+)
+
+// radixNode 压缩前缀树的节点，由 node 组成的路由树编译而来
+// node 每一层只保存一段路径，方便做冲突检测和收集 middleware；
+// radixNode 把只有一个子节点的静态路径合并成一条边，例如只注册了 /user/home 的时候，
+// user/home 只占用一个节点。匹配的时候直接比较 path 的字节，不需要切割 path
+type radixNode struct {
+	typ nodeType
+	// path 静态节点的边，可能跨越多个路径段，例如 user/home
+	path string
+	// indices 静态子节点的 path 的第一个字节，和 children 一一对应
+	indices  string
+	children []*radixNode
+	// dynChildren 正则、参数和通配符子节点，按照正则、参数、通配符的顺序排列
+	// 它们只会出现在路径段的开头
+	dynChildren []*radixNode
+
+	paramName string
+	matchFunc func(seg string) bool
+
+	// n 在这里结束的路由，只有注册了 handler 的时候才不为 nil
+	n *node
+}
+
+// compileRadix 把 root 编译为压缩前缀树
+// 编译是延迟的：注册路由只会清空已经编译的结果，第一次匹配的时候才会重新编译，
+// serveWith 和 SwapRouter 会在开始处理请求之前提前编译好。
+// 编译的结果保存在 atomic.Value 里面，编译之后不会再被修改，所以匹配的时候不需要加锁
+func compileRadix(root *node) *radixNode {
+	rn := &radixNode{}
+	if root.handler != nil {
+		rn.n = root
+	}
+	rn.compile(root)
+	rn.compress()
+	return rn
+}
+
+// compile 把 n 的子节点挂到 rn 下面，rn 代表一个路径段的开头
+func (rn *radixNode) compile(n *node) {
+	// 排序只是为了让编译的结果稳定，不影响匹配
+	keys := make([]string, 0, len(n.children))
+	for k := range n.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		rn.insert(k).attach(n.children[k])
+	}
+
+	dyn := make([]*node, 0, len(n.regChildren)+2)
+	dyn = append(dyn, n.regChildren...)
+	if n.paramChild != nil {
+		dyn = append(dyn, n.paramChild)
+	}
+	if n.starChild != nil {
+		dyn = append(dyn, n.starChild)
+	}
+	for _, c := range dyn {
+		d := &radixNode{typ: c.typ, paramName: c.paramName, matchFunc: c.matchFunc}
+		rn.dynChildren = append(rn.dynChildren, d)
+		d.attach(c)
+	}
+}
+
+// attach rn 代表 n 这一段路径的结尾
+func (rn *radixNode) attach(n *node) {
+	if n.handler != nil {
+		rn.n = n
+	}
+	if len(n.children) > 0 || len(n.regChildren) > 0 || n.paramChild != nil || n.starChild != nil {
+		rn.insert("/").compile(n)
+	}
+}
+
+// insert 插入静态路径，返回代表 path 结尾的节点
+// 拆分边的时候，原本的节点保留后半部分，所以之前返回的节点仍然有效
+func (rn *radixNode) insert(path string) *radixNode {
+	for path != "" {
+		i := strings.IndexByte(rn.indices, path[0])
+		if i < 0 {
+			c := &radixNode{path: path}
+			rn.indices += path[:1]
+			rn.children = append(rn.children, c)
+			return c
+		}
+		c := rn.children[i]
+		l := commonPrefix(path, c.path)
+		if l < len(c.path) {
+			mid := &radixNode{path: c.path[:l], indices: c.path[l : l+1], children: []*radixNode{c}}
+			c.path = c.path[l:]
+			rn.children[i] = mid
+			c = mid
+		}
+		rn, path = c, path[l:]
+	}
+	return rn
+}
+
+// compress 合并只有一个静态子节点，并且自身没有路由的静态节点
+func (rn *radixNode) compress() {
+	for i, c := range rn.children {
+		for c.n == nil && len(c.dynChildren) == 0 && len(c.children) == 1 {
+			gc := c.children[0]
+			gc.path = c.path + gc.path
+			c = gc
+		}
+		rn.children[i] = c
+		c.compress()
+	}
+	for _, d := range rn.dynChildren {
+		d.compress()
+	}
+}
+
+func commonPrefix(a string, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// match 匹配 rn 之后剩余的 path，规则和 node.match 一致，但是只返回注册了 handler 的节点
+// 路径参数会被追加到 ps 后面，回溯的时候会被截断，所以调用者可以复用 ps
+func (rn *radixNode) match(path string, ps []paramValue) (*node, []paramValue, bool) {
+	if path != "" {
+		if i := strings.IndexByte(rn.indices, path[0]); i >= 0 {
+			c := rn.children[i]
+			if strings.HasPrefix(path, c.path) {
+				rest := path[len(c.path):]
+				if rest == "" {
+					if c.n != nil {
+						return c.n, ps, true
+					}
+				} else if res, resPs, ok := c.match(rest, ps); ok {
+					return res, resPs, true
+				}
+			}
+		}
+	}
+	if len(rn.dynChildren) == 0 {
+		return nil, ps, false
+	}
+	seg := path
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		seg = path[:i]
+	}
+	l := len(ps)
+	for _, d := range rn.dynChildren {
+		ps = ps[:l]
+		switch d.typ {
+		case nodeTypeCatchAll:
+			// 匹配剩余的所有路径段
+			if d.n != nil {
+				return d.n, append(ps, paramValue{key: d.paramName, value: path}), true
+			}
+			continue
+		case nodeTypeReg:
+			if !d.matchFunc(seg) {
+				continue
+			}
+			ps = append(ps, paramValue{key: d.paramName, value: seg})
+		case nodeTypeParam:
+			ps = append(ps, paramValue{key: d.paramName, value: seg})
+		}
+		rest := path[len(seg):]
+		if rest == "" {
+			if d.n != nil {
+				return d.n, ps, true
+			}
+			continue
+		}
+		if res, resPs, ok := d.match(rest, ps); ok {
+			return res, resPs, true
+		}
+	}
+	return nil, ps[:l], false
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_compileRadix(t *testing.T) {
+	mockHandler := func(ctx *Context) {}
+	r := newRouter()
+	r.addRoute(http.MethodGet, "/user/home", mockHandler)
+	root := r.compile()[http.MethodGet]
+	// 只有一个子节点的静态路径会被合并
+	assert.Equal(t, "u", root.indices)
+	assert.Equal(t, "user/home", root.children[0].path)
+
+	r.addRoute(http.MethodGet, "/user/homepage", mockHandler)
+	r.addRoute(http.MethodGet, "/user/:id", mockHandler)
+	root = r.compile()[http.MethodGet]
+	user := root.children[0]
+	// 参数节点只能挂在路径段的开头
+	assert.Equal(t, "user/", user.path)
+	assert.Len(t, user.dynChildren, 1)
+	home := user.children[0]
+	assert.Equal(t, "home", home.path)
+	assert.Equal(t, "/user/home", home.n.route)
+	assert.Equal(t, "page", home.children[0].path)
+	assert.Equal(t, "/user/homepage", home.children[0].n.route)
+}
+
+// Test_router_compile 注册路由的时候不会编译，第一次匹配的时候才编译，并且只编译一次
+func Test_router_compile(t *testing.T) {
+	mockHandler := func(ctx *Context) {}
+	r := newRouter()
+	r.addRoute(http.MethodGet, "/user", mockHandler)
+	r.addRoute(http.MethodPost, "/user", mockHandler)
+	t1, _ := r.radix.Load().(radixTable)
+	assert.Nil(t, t1)
+
+	_, _, ok := r.lookup(http.MethodGet, "/user", nil)
+	assert.True(t, ok)
+	t1, _ = r.radix.Load().(radixTable)
+	assert.Len(t, t1, 2)
+	_, _, ok = r.lookup(http.MethodPost, "/user", nil)
+	assert.True(t, ok)
+	t2, _ := r.radix.Load().(radixTable)
+	assert.Equal(t, reflect.ValueOf(t1).Pointer(), reflect.ValueOf(t2).Pointer())
+
+	// 注册新的路由之后重新编译
+	r.addRoute(http.MethodGet, "/order", mockHandler)
+	_, _, ok = r.lookup(http.MethodGet, "/order", nil)
+	assert.True(t, ok)
+}
+
+// Test_radixNode_match 压缩前缀树的匹配结果需要和逐段匹配的结果一致
+func Test_radixNode_match(t *testing.T) {
+	mockHandler := func(ctx *Context) {}
+	routes := []string{
+		"/",
+		"/user",
+		"/users",
+		"/user/home",
+		"/user/homepage",
+		"/user/:id",
+		"/user/:id/detail",
+		"/user/:id(^[0-9]+$)/blog/:slug",
+		"/user/:id<uuid>/avatar",
+		"/order/*",
+		"/order/*/detail",
+		"/order/create",
+		"/static/*filepath",
+		"/static/index",
+		"/a/:id/b/:id",
+		"/reg/:id(^[0-9]+$)",
+		"/reg/:name(^[a-z]+$)/info",
+		"/reg/*",
+	}
+	r := newRouter()
+	for _, route := range routes {
+		r.addRoute(http.MethodGet, route, mockHandler)
+	}
+	// 只注册 middleware 的节点
+	r.addRoute(http.MethodGet, "/admin/settings", nil)
+
+	paths := []string{
+		"/",
+		"//",
+		"/user",
+		"/user/",
+		"/users",
+		"/usersx",
+		"/use",
+		"/user/home",
+		"/user/homepage",
+		"/user/homep",
+		"/user/hom",
+		"/user/123",
+		"/user/123/detail",
+		"/user/123/blog/hello",
+		"/user/abc/blog/hello",
+		"/user/home/detail",
+		"/user/3f2504e0-4f89-11d3-9a0c-0305e82c3301/avatar",
+		"/user/abc/avatar",
+		"/user//detail",
+		"/order",
+		"/order/123",
+		"/order/123/detail",
+		"/order/create",
+		"/order/create/detail",
+		"/order/123/abc",
+		"/static",
+		"/static/index",
+		"/static/css/app.css",
+		"/a/1/b/2",
+		"/reg/123",
+		"/reg/abc",
+		"/reg/abc/info",
+		"/reg/123/info",
+		"/admin/settings",
+		"/admin",
+		"/unknown",
+	}
+	legacyRoot := r.trees[http.MethodGet]
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			var (
+				want       *node
+				wantParams []paramValue
+			)
+			if path == "/" {
+				want = legacyRoot
+			} else {
+				n, params, ok := legacyRoot.match(strings.Split(strings.Trim(path, "/"), "/"), nil)
+				if ok && n.handler != nil {
+					want, wantParams = n, params
+				}
+			}
+			n, params, ok := r.lookup(http.MethodGet, path, nil)
+			if len(params) == 0 {
+				// 回溯之后可能是空切片
+				params = nil
+			}
+			assert.Equal(t, want != nil, ok)
+			assert.Same(t, want, n)
+			assert.Equal(t, wantParams, params)
+		})
+	}
+}
@@ -2,15 +2,30 @@ package web
 
 import (
 	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type router struct {
 	// trees 是按照 HTTP 方法来组织的
 	// 如 GET => *node
 	trees map[string]*node
+	// radix 由 trees 编译而来的压缩前缀树，用于匹配请求，类型是 radixTable
+	// 注册路由的时候只会把它置为 nil，第一次匹配或者路由表被 HTTPServer 启用的时候才编译
+	radix        atomic.Value
+	compileMutex sync.Mutex
+	// serving 不为 0 代表路由表正在被 HTTPServer 使用
+	serving int32
 }
 
+// radixTable 按照 HTTP 方法组织的压缩前缀树
+type radixTable map[string]*radixNode
+
 func newRouter() router {
 	return router{
 		trees: map[string]*node{},
@@ -24,7 +39,16 @@ func newRouter() router {
 // - 不能在同一个位置注册不同的参数路由，例如 /user/:id 和 /user/:name 冲突
 // - 不能在同一个位置同时注册通配符路由和参数路由，例如 /user/:id 和 /user/* 冲突
 // - 同名路径参数，在路由匹配的时候，值会被覆盖。例如 /user/:id/abc/:id，那么 /user/123/abc/456 最终 id = 456
+// - 正则路由 /user/:id(^[0-9]+$) 和类型约束路由 /user/:id<int> 可以和参数路由、通配符路由共存
+// - 命名通配符路由 /static/*filepath 只能出现在路由的最后，例如 /static/css/app.css 的 filepath = css/app.css
 func (r *router) addRoute(method string, path string, handler HandleFunc, ms ...Middleware) {
+	r.addGroupRoute(method, path, handler, nil, ms...)
+}
+
+// addGroupRoute 注册分组下的路由
+// groupMdls 是分组的 middleware，它们只作用于这一个路由，在 ms 之前执行；
+// ms 和 addRoute 一样，注册在节点上，会作用于这个路由之下的所有路由
+func (r *router) addGroupRoute(method string, path string, handler HandleFunc, groupMdls []Middleware, ms ...Middleware) {
 	if path == "" {
 		panic("web: 路由是空字符串")
 	}
@@ -36,6 +60,10 @@ func (r *router) addRoute(method string, path string, handler HandleFunc, ms ...
 		panic("web: 路由不能以 / 结尾")
 	}
 
+	// 先检查整条路径，确认可以注册之后再修改路由树，
+	// 这样注册失败的时候不会留下注册了一半的节点
+	segs := r.checkPath(method, path, handler)
+
 	root, ok := r.trees[method]
 	// 这是一个全新的 HTTP 方法，创建根节点
 	if !ok {
@@ -44,41 +72,96 @@ func (r *router) addRoute(method string, path string, handler HandleFunc, ms ...
 		r.trees[method] = root
 	}
 	if path == "/" {
-		if root.handler != nil {
-			panic("web: 路由冲突[/]")
+		// handler 为 nil 的时候只是注册 middleware，例如 UseV1 和 Group
+		if handler != nil {
+			root.handler = handler
+			root.routeMdls = groupMdls
 		}
-		root.handler = handler
 		if root.mdls == nil {
 			root.mdls = ms
 		} else {
 			root.mdls = append(root.mdls, ms...)
 		}
+		root.subMdls = root.subMdls || len(ms) > 0
+		r.radix.Store(radixTable(nil))
 		return
 	}
 
-	segs := strings.Split(path[1:], "/")
 	// 开始一段段处理
 	for _, s := range segs {
-		if s == "" {
-			panic(fmt.Sprintf("web: 非法路由。不允许使用 //a/b, /a//b 之类的路由, [%s]", path))
+		if len(ms) > 0 {
+			root.subMdls = true
 		}
 		root = root.childOrCreate(s)
 	}
-	if root.handler != nil {
-		panic(fmt.Sprintf("web: 路由冲突[%s]", path))
+	if handler != nil {
+		root.handler = handler
+		root.routeMdls = groupMdls
 	}
-	root.handler = handler
 	root.route = path
 	if root.mdls == nil {
 		root.mdls = ms
 	} else {
 		root.mdls = append(root.mdls, ms...)
 	}
+	root.subMdls = root.subMdls || len(ms) > 0
+	r.radix.Store(radixTable(nil))
+}
+
+// checkPath 检查 path 能否注册，不会修改路由树，不能注册的时候 panic
+// 返回 path 切割之后的路径段
+func (r *router) checkPath(method string, path string, handler HandleFunc) []string {
+	root := r.trees[method]
+	if path == "/" {
+		if handler != nil && root != nil && root.handler != nil {
+			panic("web: 路由冲突[/]")
+		}
+		return nil
+	}
+	segs := strings.Split(path[1:], "/")
+	for i, s := range segs {
+		if s == "" {
+			panic(fmt.Sprintf("web: 非法路由。不允许使用 //a/b, /a//b 之类的路由, [%s]", path))
+		}
+		if s[0] == '*' && len(s) > 1 && i != len(segs)-1 {
+			panic(fmt.Sprintf("web: 非法路由，%s 只能出现在路由的最后 [%s]", s, path))
+		}
+		if root != nil {
+			root = root.childOf(s)
+		} else {
+			// 新的子树不会和已有的路由冲突，只需要校验正则和类型约束
+			parseRegPath(s)
+		}
+	}
+	if handler != nil && root != nil && root.handler != nil {
+		panic(fmt.Sprintf("web: 路由冲突[%s]", path))
+	}
+	return segs
+}
 
+// compile 把所有的路由树编译为压缩前缀树，已经编译过的时候直接返回
+// 匹配请求的时候不需要加锁，只有注册路由之后的第一次匹配会在这里加锁编译
+func (r *router) compile() radixTable {
+	if t, _ := r.radix.Load().(radixTable); t != nil {
+		return t
+	}
+	r.compileMutex.Lock()
+	defer r.compileMutex.Unlock()
+	if t, _ := r.radix.Load().(radixTable); t != nil {
+		return t
+	}
+	t := make(radixTable, len(r.trees))
+	for method, root := range r.trees {
+		t[method] = compileRadix(root)
+	}
+	r.radix.Store(t)
+	return t
 }
 
 // findRoute 查找对应的节点
 // 注意，返回的 node 内部 HandleFunc 不为 nil 才算是注册了路由
+// 匹配是可以回溯的：如果某个候选节点在后续的路径上没有匹配成功，
+// 或者正则、类型约束校验失败，那么会继续尝试下一个候选节点
 func (r *router) findRoute(method string, path string) (*matchInfo, bool) {
 	root, ok := r.trees[method]
 	if !ok {
@@ -86,68 +169,188 @@ func (r *router) findRoute(method string, path string) (*matchInfo, bool) {
 	}
 
 	if path == "/" {
-		return &matchInfo{n: root, mdls: root.mdls}, true
+		mi := &matchInfo{n: root}
+		if len(root.mdls) > 0 {
+			mi.mdlNodes = []*node{root}
+		}
+		mi.mdls = root.chainMdls(mi.mdlNodes)
+		return mi, true
 	}
 
-	segs := strings.Split(strings.Trim(path, "/"), "/")
-	mi := &matchInfo{}
-	cur := root
-	for _, s := range segs {
-		var matchParam bool
-		cur, matchParam, ok = cur.childOf(s)
+	n, params, ok := r.lookup(method, path, nil)
+	if !ok {
+		// 压缩前缀树只保存了注册了 handler 的路由，只注册了 middleware 的节点需要逐段匹配
+		n, params, ok = root.match(strings.Split(strings.Trim(path, "/"), "/"), nil)
 		if !ok {
 			return nil, false
 		}
-		if matchParam {
-			mi.addValue(cur.path[1:], s)
-		}
 	}
-	mi.n = cur
-	mi.mdls = r.findMdls(root, segs)
+	mi := &matchInfo{n: n}
+	for _, p := range params {
+		mi.addValue(p.key, p.value)
+	}
+	var buf mdlCollector
+	mi.mdlNodes = buf.collect(root, strings.Trim(path, "/"))
+	mi.mdls = n.chainMdls(mi.mdlNodes)
 	return mi, true
 }
 
-func (r *router) findMdls(root *node, segs []string) []Middleware {
-	res := []*node{root}
-	q := []*node{root}
-	l := 0
-	for len(q) > 0 {
-		p := make([]*node, 0, 4)
-		seg := segs[l]
+// lookup 在压缩前缀树上查找注册了 handler 的节点
+// 路径参数会被追加到 ps 后面，调用者可以复用 ps 来避免内存分配
+func (r *router) lookup(method string, path string, ps []paramValue) (*node, []paramValue, bool) {
+	root, ok := r.compile()[method]
+	if !ok {
+		return nil, ps, false
+	}
+	if path == "/" {
+		return root.n, ps, root.n != nil
+	}
+	return root.match(strings.Trim(path, "/"), ps)
+}
+
+// allowedMethods 返回 path 注册了路由的所有 HTTP 方法，按照字典序排列
+// 注册了 GET 的时候，会自动支持 HEAD；只要有任何一个方法，就会自动支持 OPTIONS
+func (r *router) allowedMethods(path string) []string {
+	var res []string
+	for method := range r.trees {
+		if _, _, ok := r.lookup(method, path, nil); ok {
+			res = append(res, method)
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	has := func(method string) bool {
+		for _, m := range res {
+			if m == method {
+				return true
+			}
+		}
+		return false
+	}
+	if has(http.MethodGet) && !has(http.MethodHead) {
+		res = append(res, http.MethodHead)
+	}
+	if !has(http.MethodOptions) {
+		res = append(res, http.MethodOptions)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// mdlCollector 收集所有匹配路径前缀的节点上的 middleware
+// 只要节点能够匹配路径的某个前缀，那么它上面的 middleware 就会作用于这个前缀之下的所有路由。
+// 执行顺序是固定的：
+// 1. 层级浅的先于层级深的
+// 2. 同一层级之内，按照通配符、路径参数、正则（注册顺序）、静态的顺序，也就是越具体的越靠后
+// 收集到的是节点而不是 middleware，用于缓存组装好的调用链。
+// 它内部的缓冲区可以复用，避免每次请求都分配内存
+type mdlCollector struct {
+	res []*node
+	// q 和 p 是遍历时使用的缓冲区
+	q []*node
+	p []*node
+}
+
+// collect 收集 path 上注册了 middleware 的节点，path 两端没有 /
+// 返回的切片在下一次调用 collect 之前有效
+func (mc *mdlCollector) collect(root *node, path string) []*node {
+	res, q, p := mc.res[:0], mc.q[:0], mc.p[:0]
+	defer func() {
+		mc.res, mc.q, mc.p = res, q, p
+	}()
+	if len(root.mdls) > 0 {
+		res = append(res, root)
+	}
+	if !root.subMdls {
+		return res
+	}
+	q = append(q, root)
+	for {
+		seg, rest, more := strings.Cut(path, "/")
+		p = p[:0]
 		for _, n := range q {
-			if n.starChild != nil {
+			// 子树上没有 middleware 的节点不需要继续遍历
+			if n.starChild != nil && n.starChild.subMdls {
 				p = append(p, n.starChild)
 			}
-			if n.paramChild != nil {
+			if n.paramChild != nil && n.paramChild.subMdls {
 				p = append(p, n.paramChild)
 			}
-			if n.children != nil {
-				if c, ok := n.children[seg]; ok {
+			for _, c := range n.regChildren {
+				if c.subMdls && c.matchFunc(seg) {
 					p = append(p, c)
 				}
 			}
+			if c, ok := n.children[seg]; ok && c.subMdls {
+				p = append(p, c)
+			}
+		}
+		if len(p) == 0 {
+			return res
 		}
-		res = append(res, p...)
-		q = p
-		l++
-		if l == len(segs) {
-			break
+		for _, n := range p {
+			if len(n.mdls) > 0 {
+				res = append(res, n)
+			}
 		}
+		if !more {
+			return res
+		}
+		q, p, path = p, q, rest
+	}
+}
+
+func mdlsOf(nodes []*node) []Middleware {
+	var mdls []Middleware
+	for _, n := range nodes {
+		mdls = append(mdls, n.mdls...)
+	}
+	return mdls
+}
+
+// chainMdls 返回命中 n 的时候需要执行的所有 middleware，mdlNodes 是收集到的节点
+// routeMdls 在 n 自己的 mdls 之前执行，也就是分组的 middleware 包裹着注册路由时传入的 middleware
+func (n *node) chainMdls(mdlNodes []*node) []Middleware {
+	mdls := mdlsOf(mdlNodes)
+	if len(n.routeMdls) == 0 {
+		return mdls
 	}
-	var mdl []Middleware
-	for _, n := range res {
-		mdl = append(mdl, n.mdls...)
+	idx := len(mdls)
+	if len(mdlNodes) > 0 && mdlNodes[len(mdlNodes)-1] == n {
+		idx -= len(n.mdls)
 	}
-	return mdl
+	res := make([]Middleware, 0, len(mdls)+len(n.routeMdls))
+	res = append(res, mdls[:idx]...)
+	res = append(res, n.routeMdls...)
+	return append(res, mdls[idx:]...)
 }
 
+type nodeType int
+
+const (
+	// 静态路由
+	nodeTypeStatic nodeType = iota
+	// 正则路由，包括类型约束路由，例如 :id(^[0-9]+$) 和 :id<int>
+	nodeTypeReg
+	// 路径参数路由
+	nodeTypeParam
+	// 通配符路由
+	nodeTypeAny
+	// 命名通配符路由，形式 *name，只能出现在路由的最后，匹配剩余的所有路径段
+	nodeTypeCatchAll
+)
+
 // node 代表路由树的节点
 // 路由树的匹配顺序是：
 // 1. 静态完全匹配
-// 2. 路径参数匹配：形式 :param_name
-// 3. 通配符匹配：*
-// 这是不回溯匹配
+// 2. 正则匹配：形式 :param_name(reg_expr) 或者 :param_name<type>，按照注册顺序尝试
+// 3. 路径参数匹配：形式 :param_name
+// 4. 通配符匹配：* 匹配一段，*name 匹配剩余的所有路径段，并且作为路径参数 name 的值
+// 某个候选节点匹配失败之后，会回溯尝试下一个候选节点
 type node struct {
+	typ nodeType
+
 	path string
 	// children 子节点
 	// 子节点的 path => node
@@ -156,6 +359,8 @@ type node struct {
 	handler HandleFunc
 	// 注册在该节点上的 middleware
 	mdls []Middleware
+	// routeMdls 只作用于这个节点上的路由，不会作用于子孙节点上的路由，例如分组的 middleware
+	routeMdls []Middleware
 
 	// route 到达该节点的完整的路由路径
 	route string
@@ -164,93 +369,276 @@ type node struct {
 	starChild *node
 
 	paramChild *node
+	// 正则路由和参数路由都会使用这个字段
+	paramName string
+
+	// 正则路由和类型约束路由，同一个位置允许注册多个
+	regChildren []*node
+	// matchFunc 正则路由用于校验路径段是否满足约束
+	matchFunc func(seg string) bool
+
+	// subMdls 这个节点或者它的子孙节点上注册了 middleware
+	subMdls bool
+
+	// chains 缓存组装好的调用链，类型是 []*cachedChain
+	// 写入的时候复制一份新的切片，所以读取的时候不需要加锁
+	chains     atomic.Value
+	chainMutex sync.Mutex
+}
+
+// cachedChain 一组 middleware 节点和 handler 组装好的调用链
+// 同一个节点在不同的请求路径下，可能会收集到不同的 middleware
+type cachedChain struct {
+	mdlNodes []*node
+	chain    HandleFunc
+}
+
+// handlerChain 返回 mdlNodes 上的 middleware 和 handler 组装好的调用链
+// 调用链只会在第一次命中的时候组装，之后直接从缓存中获取
+func (n *node) handlerChain(mdlNodes []*node) HandleFunc {
+	if hc, ok := n.findChain(mdlNodes); ok {
+		return hc
+	}
+	n.chainMutex.Lock()
+	defer n.chainMutex.Unlock()
+	if hc, ok := n.findChain(mdlNodes); ok {
+		return hc
+	}
+	chain := n.handler
+	mdls := n.chainMdls(mdlNodes)
+	// 从后往前组装，保证先收集到的 middleware 先执行
+	for i := len(mdls) - 1; i >= 0; i-- {
+		chain = mdls[i](chain)
+	}
+	chains, _ := n.chains.Load().([]*cachedChain)
+	// mdlNodes 可能是调用者复用的缓冲区，需要复制一份
+	hc := &cachedChain{mdlNodes: append([]*node(nil), mdlNodes...), chain: chain}
+	n.chains.Store(append(chains[:len(chains):len(chains)], hc))
+	return chain
+}
+
+func (n *node) findChain(mdlNodes []*node) (HandleFunc, bool) {
+	chains, _ := n.chains.Load().([]*cachedChain)
+	for _, hc := range chains {
+		if len(hc.mdlNodes) != len(mdlNodes) {
+			continue
+		}
+		same := true
+		for i, mn := range hc.mdlNodes {
+			if mn != mdlNodes[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return hc.chain, true
+		}
+	}
+	return nil, false
+}
+
+type paramValue struct {
+	key   string
+	value string
+}
+
+// match 深度优先匹配 segs
+// 优先返回带有 handler 的节点。如果所有的候选路径都没有 handler，
+// 那么返回第一个能够完整匹配的节点，和原本不回溯的行为保持一致
+func (n *node) match(segs []string, params []paramValue) (*node, []paramValue, bool) {
+	if len(segs) == 0 {
+		return n, params, true
+	}
+	var (
+		fallback       *node
+		fallbackParams []paramValue
+	)
+	seg := segs[0]
+	for _, c := range n.childrenOf(seg) {
+		if c.typ == nodeTypeCatchAll {
+			// 匹配剩余的所有路径段
+			ps := append(make([]paramValue, 0, len(params)+1), params...)
+			ps = append(ps, paramValue{key: c.paramName, value: strings.Join(segs, "/")})
+			if c.handler != nil {
+				return c, ps, true
+			}
+			if fallback == nil {
+				fallback, fallbackParams = c, ps
+			}
+			continue
+		}
+		ps := params
+		if c.typ == nodeTypeReg || c.typ == nodeTypeParam {
+			// 重新分配，避免不同的候选路径共享底层数组
+			ps = append(make([]paramValue, 0, len(params)+1), params...)
+			ps = append(ps, paramValue{key: c.paramName, value: seg})
+		}
+		res, resParams, ok := c.match(segs[1:], ps)
+		if !ok {
+			continue
+		}
+		if res.handler != nil {
+			return res, resParams, true
+		}
+		if fallback == nil {
+			fallback, fallbackParams = res, resParams
+		}
+	}
+	return fallback, fallbackParams, fallback != nil
 }
 
+// childrenOf 按照匹配顺序返回能够匹配 path 的候选子节点
 func (n *node) childrenOf(path string) []*node {
 	res := make([]*node, 0, 4)
-	var static *node
 	if n.children != nil {
-		static = n.children[path]
+		if static, ok := n.children[path]; ok {
+			res = append(res, static)
+		}
 	}
-	if n.starChild != nil {
-		res = append(res, n.starChild)
+	for _, c := range n.regChildren {
+		if c.matchFunc(path) {
+			res = append(res, c)
+		}
 	}
 	if n.paramChild != nil {
-		res = append(res, n.starChild)
+		res = append(res, n.paramChild)
 	}
-	if static != nil {
-		res = append(res, static)
+	if n.starChild != nil {
+		res = append(res, n.starChild)
 	}
 	return res
 }
 
-// child 返回子节点
-// 第一个返回值 *node 是命中的节点
-// 第二个返回值 bool 代表是否是命中参数路由
-// 第三个返回值 bool 代表是否命中
-func (n *node) childOf(path string) (*node, bool, bool) {
-	if n.children == nil {
-		if n.paramChild != nil {
-			return n.paramChild, true, true
-		}
-		return n.starChild, false, n.starChild != nil
+// childOrCreate 查找子节点，没有找到的时候会创建一个新的节点，并且保存在 node 里面
+func (n *node) childOrCreate(path string) *node {
+	if child := n.childOf(path); child != nil {
+		return child
 	}
-	res, ok := n.children[path]
-	if !ok {
-		if n.paramChild != nil {
-			return n.paramChild, true, true
+	// * 或者 *name
+	if path[0] == '*' {
+		if path == "*" {
+			n.starChild = &node{path: path, typ: nodeTypeAny}
+		} else {
+			n.starChild = &node{path: path, typ: nodeTypeCatchAll, paramName: path[1:]}
 		}
-		return n.starChild, false, n.starChild != nil
+		return n.starChild
+	}
+
+	// 正则路由和参数路由、通配符路由可以共存，
+	// 正则校验失败的时候会继续尝试参数路由或者通配符路由
+	if paramName, matchFunc, ok := parseRegPath(path); ok {
+		child := &node{path: path, typ: nodeTypeReg, paramName: paramName, matchFunc: matchFunc}
+		n.regChildren = append(n.regChildren, child)
+		return child
 	}
-	return res, false, ok
+
+	// 以 : 开头，我们认为是参数路由
+	if path[0] == ':' {
+		n.paramChild = &node{path: path, typ: nodeTypeParam, paramName: path[1:]}
+		return n.paramChild
+	}
+
+	if n.children == nil {
+		n.children = make(map[string]*node)
+	}
+	child := &node{path: path, typ: nodeTypeStatic}
+	n.children[path] = child
+	return child
 }
 
-// childOrCreate 查找子节点，
+// childOf 查找子节点，不会修改路由树，没有找到的时候返回 nil
 // 首先会判断 path 是不是通配符路径
-// 其次判断 path 是不是参数路径，即以 : 开头的路径
+// 其次判断 path 是不是正则路径，即 :name(reg_expr) 或者 :name<type>
+// 再次判断 path 是不是参数路径，即以 : 开头的路径
 // 最后会从 children 里面查找，
-// 如果没有找到，那么会创建一个新的节点，并且保存在 node 里面
-func (n *node) childOrCreate(path string) *node {
-	if path == "*" {
+// path 和已有的子节点冲突，或者正则表达式、类型约束非法的时候 panic
+func (n *node) childOf(path string) *node {
+	// * 或者 *name
+	if path[0] == '*' {
 		if n.paramChild != nil {
 			panic(fmt.Sprintf("web: 非法路由，已有路径参数路由。不允许同时注册通配符路由和参数路由 [%s]", path))
 		}
-		if n.starChild == nil {
-			n.starChild = &node{path: path}
+		if n.starChild != nil && n.starChild.path != path {
+			panic(fmt.Sprintf("web: 路由冲突，通配符路由冲突，已有 %s，新注册 %s", n.starChild.path, path))
 		}
 		return n.starChild
 	}
 
-	// 以 : 开头，我们认为是参数路由
+	if _, _, ok := parseRegPath(path); ok {
+		for _, c := range n.regChildren {
+			if c.path == path {
+				return c
+			}
+		}
+		return nil
+	}
+
 	if path[0] == ':' {
 		if n.starChild != nil {
 			panic(fmt.Sprintf("web: 非法路由，已有通配符路由。不允许同时注册通配符路由和参数路由 [%s]", path))
 		}
-		if n.paramChild != nil {
-			if n.paramChild.path != path {
-				panic(fmt.Sprintf("web: 路由冲突，参数路由冲突，已有 %s，新注册 %s", n.paramChild.path, path))
-			}
-		} else {
-			n.paramChild = &node{path: path}
+		if n.paramChild != nil && n.paramChild.path != path {
+			panic(fmt.Sprintf("web: 路由冲突，参数路由冲突，已有 %s，新注册 %s", n.paramChild.path, path))
 		}
 		return n.paramChild
 	}
+	return n.children[path]
+}
 
-	if n.children == nil {
-		n.children = make(map[string]*node)
+// paramTypes 类型约束路由支持的类型
+// 形式 :param_name<type>，例如 :id<int>
+var paramTypes = map[string]func(seg string) bool{
+	"int": func(seg string) bool {
+		_, err := strconv.ParseInt(seg, 10, 64)
+		return err == nil
+	},
+	"uint": func(seg string) bool {
+		_, err := strconv.ParseUint(seg, 10, 64)
+		return err == nil
+	},
+	"uuid": regexp.MustCompile(
+		`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+}
+
+// parseRegPath 检查是否满足正则路由或者类型约束路由的规则
+// 满足，则返回参数名和校验函数
+func parseRegPath(path string) (string, func(seg string) bool, bool) {
+	if len(path) < 2 || path[0] != ':' {
+		return "", nil, false
 	}
-	child, ok := n.children[path]
-	if !ok {
-		child = &node{path: path}
-		n.children[path] = child
+	switch path[len(path)-1] {
+	case ')':
+		idx := strings.IndexByte(path, '(')
+		if idx < 0 {
+			return "", nil, false
+		}
+		reg, err := regexp.Compile(path[idx+1 : len(path)-1])
+		if err != nil {
+			panic(fmt.Sprintf("web: 非法路由，正则表达式错误 [%s]: %v", path, err))
+		}
+		return path[1:idx], reg.MatchString, true
+	case '>':
+		idx := strings.IndexByte(path, '<')
+		if idx < 0 {
+			return "", nil, false
+		}
+		typ := path[idx+1 : len(path)-1]
+		matchFunc, ok := paramTypes[typ]
+		if !ok {
+			panic(fmt.Sprintf("web: 非法路由，不支持的参数类型 %s [%s]", typ, path))
+		}
+		return path[1:idx], matchFunc, true
 	}
-	return child
+	return "", nil, false
 }
 
 type matchInfo struct {
 	n          *node
 	pathParams map[string]string
 	mdls       []Middleware
+	// mdlNodes mdls 来自哪些节点
+	// 同一个节点在不同的请求路径下，可能会收集到不同的 middleware
+	mdlNodes []*node
 }
 
 func (m *matchInfo) addValue(key string, value string) {
@@ -0,0 +1,340 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func BenchmarkStaticRoute(b *testing.B) {
+	testRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/home",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/home/bedroom",
+		},
+	}
+	mockHandler := func(ctx *Context) {}
+	r := newRouter()
+	for _, tr := range testRoutes {
+		r.addRoute(tr.method, tr.path, mockHandler)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tr := range testRoutes {
+			r.findRoute(tr.method, tr.path)
+		}
+	}
+	b.StopTimer()
+}
+
+func BenchmarkParamRoute(b *testing.B) {
+	testRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/:id",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/:id/detail",
+		},
+	}
+	mockHandler := func(ctx *Context) {}
+	actualRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/123",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/456/detail",
+		},
+	}
+	r := newRouter()
+	for _, tr := range testRoutes {
+		r.addRoute(tr.method, tr.path, mockHandler)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tr := range actualRoutes {
+			r.findRoute(tr.method, tr.path)
+		}
+	}
+	b.StopTimer()
+}
+
+func BenchmarkParamRoute2(b *testing.B) {
+	testRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/:id/detail",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/:id/blog/:slug",
+		},
+	}
+	mockHandler := func(ctx *Context) {}
+	actualRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/456/detail",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/789/blog/how-to-write-blog",
+		},
+	}
+	r := newRouter()
+	for _, tr := range testRoutes {
+		r.addRoute(tr.method, tr.path, mockHandler)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tr := range actualRoutes {
+			r.findRoute(tr.method, tr.path)
+		}
+	}
+	b.StopTimer()
+}
+
+func BenchmarkRegexRoute(b *testing.B) {
+	testRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/:id(^[0-9]+$)",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/:id(^[0-9]+$)/detail",
+		},
+	}
+	mockHandler := func(ctx *Context) {}
+	actualRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/123",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/456/detail",
+		},
+	}
+	r := newRouter()
+	for _, tr := range testRoutes {
+		r.addRoute(tr.method, tr.path, mockHandler)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tr := range actualRoutes {
+			r.findRoute(tr.method, tr.path)
+		}
+	}
+	b.StopTimer()
+}
+func BenchmarkRegexRoute2(b *testing.B) {
+	testRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/:id(^[0-9]+$)/detail",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/:id(^[0-9]+$)/blog/:slug(^[\\w,-]+$)",
+		},
+	}
+	mockHandler := func(ctx *Context) {}
+	actualRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/456/detail",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/789/blog/how-to-write-blog",
+		},
+	}
+	r := newRouter()
+	for _, tr := range testRoutes {
+		r.addRoute(tr.method, tr.path, mockHandler)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tr := range actualRoutes {
+			r.findRoute(tr.method, tr.path)
+		}
+	}
+	b.StopTimer()
+}
+
+func BenchmarkStarRoute(b *testing.B) {
+	testRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/*/detail",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/*/*/bedroom",
+		},
+	}
+	mockHandler := func(ctx *Context) {}
+	actualRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/456/detail",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/789/hotel/bedroom",
+		},
+	}
+	r := newRouter()
+	for _, tr := range testRoutes {
+		r.addRoute(tr.method, tr.path, mockHandler)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tr := range actualRoutes {
+			r.findRoute(tr.method, tr.path)
+		}
+	}
+	b.StopTimer()
+}
+func BenchmarkStarRoute2(b *testing.B) {
+	testRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/*/detail",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/*/blog/*",
+		},
+	}
+	mockHandler := func(ctx *Context) {}
+	actualRoutes := []struct {
+		method string
+		path   string
+	}{
+		{
+			method: http.MethodGet,
+			path:   "/user/456/detail",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/user/789/blog/how-to-write",
+		},
+	}
+	r := newRouter()
+	for _, tr := range testRoutes {
+		r.addRoute(tr.method, tr.path, mockHandler)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tr := range actualRoutes {
+			r.findRoute(tr.method, tr.path)
+		}
+	}
+	b.StopTimer()
+}
+
+// BenchmarkHTTPServer_ServeHTTP 完整的请求处理流程，包括路由匹配、Context 的创建和 middleware
+func BenchmarkHTTPServer_ServeHTTP(b *testing.B) {
+	mdl := func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+		}
+	}
+	mockHandler := func(ctx *Context) {}
+	s := NewHTTPServer()
+	s.Use(mdl)
+	s.Get("/user/home", mockHandler)
+	s.Get("/user/:id/detail", mockHandler)
+	s.Get("/user/:id(^[0-9]+$)/blog/:slug", mockHandler)
+	g := s.Group("/api", mdl)
+	g.Get("/order/:sn", mockHandler)
+
+	testCases := []struct {
+		name string
+		path string
+	}{
+		{name: "static", path: "/user/home"},
+		{name: "param", path: "/user/456/detail"},
+		{name: "regex", path: "/user/789/blog/how-to-write-blog"},
+		{name: "group", path: "/api/order/123"},
+	}
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := &discardWriter{header: http.Header{}}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.ServeHTTP(w, req)
+			}
+		})
+	}
+}
+
+// discardWriter 避免 httptest.ResponseRecorder 自身的内存分配影响结果
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *discardWriter) Write(bs []byte) (int, error) {
+	return len(bs), nil
+}
+
+func (d *discardWriter) WriteHeader(statusCode int) {}
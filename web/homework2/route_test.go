@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -122,7 +124,7 @@ func Test_router_AddRoute(t *testing.T) {
 			}},
 		},
 	}
-	msg, ok := wantRouter.equal(r)
+	msg, ok := wantRouter.equal(&r)
 	assert.True(t, ok, msg)
 
 	// 非法用例
@@ -189,7 +191,7 @@ func Test_router_AddRoute(t *testing.T) {
 	})
 }
 
-func (r router) equal(y router) (string, bool) {
+func (r *router) equal(y *router) (string, bool) {
 	for k, v := range r.trees {
 		yv, ok := y.trees[k]
 		if !ok {
@@ -579,3 +581,262 @@ func Test_findRoute_Middleware(t *testing.T) {
 	}
 
 }
+
+func Test_router_findRoute_Reg(t *testing.T) {
+	handlerBuilder := func(route string) HandleFunc {
+		return func(ctx *Context) {
+			ctx.RespData = []byte(route)
+		}
+	}
+	testRoutes := []string{
+		"/user/:id(^[0-9]+$)",
+		"/user/:name",
+		"/order/:id<int>",
+		"/order/:sn<uuid>/detail",
+		"/order/:sn(^[a-z]+$)/detail",
+		"/order/*",
+		"/item/:id<uint>/detail",
+		"/item/:name/info",
+	}
+	r := newRouter()
+	for _, route := range testRoutes {
+		r.addRoute(http.MethodGet, route, handlerBuilder(route))
+	}
+
+	testCases := []struct {
+		name      string
+		path      string
+		found     bool
+		wantRoute string
+		wantParam map[string]string
+	}{
+		{
+			name:      "reg match",
+			path:      "/user/123",
+			found:     true,
+			wantRoute: "/user/:id(^[0-9]+$)",
+			wantParam: map[string]string{"id": "123"},
+		},
+		{
+			name:      "reg fall through to param",
+			path:      "/user/tom",
+			found:     true,
+			wantRoute: "/user/:name",
+			wantParam: map[string]string{"name": "tom"},
+		},
+		{
+			name:      "int",
+			path:      "/order/-12",
+			found:     true,
+			wantRoute: "/order/:id<int>",
+			wantParam: map[string]string{"id": "-12"},
+		},
+		{
+			name:      "int fall through to star",
+			path:      "/order/abc",
+			found:     true,
+			wantRoute: "/order/*",
+		},
+		{
+			name:      "uuid",
+			path:      "/order/4a6e2d3c-0b0e-4a4d-9d7e-2f8f0e9c1a2b/detail",
+			found:     true,
+			wantRoute: "/order/:sn<uuid>/detail",
+			wantParam: map[string]string{"sn": "4a6e2d3c-0b0e-4a4d-9d7e-2f8f0e9c1a2b"},
+		},
+		{
+			name:      "second reg",
+			path:      "/order/abc/detail",
+			found:     true,
+			wantRoute: "/order/:sn(^[a-z]+$)/detail",
+			wantParam: map[string]string{"sn": "abc"},
+		},
+		{
+			name: "no reg match",
+			path: "/order/ABC/detail",
+		},
+		{
+			// 类型约束通过了，但是后续路径匹配失败，回溯到参数路由
+			name:      "backtrack",
+			path:      "/item/123/info",
+			found:     true,
+			wantRoute: "/item/:name/info",
+			wantParam: map[string]string{"name": "123"},
+		},
+		{
+			name:      "uint",
+			path:      "/item/123/detail",
+			found:     true,
+			wantRoute: "/item/:id<uint>/detail",
+			wantParam: map[string]string{"id": "123"},
+		},
+		{
+			name: "uint fail",
+			path: "/item/-1/detail",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mi, found := r.findRoute(http.MethodGet, tc.path)
+			assert.Equal(t, tc.found, found)
+			if !found {
+				return
+			}
+			assert.Equal(t, tc.wantParam, mi.pathParams)
+			ctx := &Context{}
+			mi.n.handler(ctx)
+			assert.Equal(t, tc.wantRoute, string(ctx.RespData))
+		})
+	}
+
+	assert.PanicsWithValue(t, "web: 非法路由，不支持的参数类型 abc [:id<abc>]", func() {
+		r.addRoute(http.MethodGet, "/a/:id<abc>", handlerBuilder(""))
+	})
+	assert.Panics(t, func() {
+		r.addRoute(http.MethodGet, "/a/:id([)", handlerBuilder(""))
+	})
+}
+
+func Test_findRoute_ScopedMiddleware(t *testing.T) {
+	var mdlBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				ctx.RespData = append(ctx.RespData, i)
+				next(ctx)
+			}
+		}
+	}
+	r := newRouter()
+	r.addRoute(http.MethodGet, "/", nil, mdlBuilder('/'))
+	r.addRoute(http.MethodGet, "/user/:id", nil, mdlBuilder('p'))
+	r.addRoute(http.MethodGet, "/user/:id(^[0-9]+$)", nil, mdlBuilder('r'))
+	r.addRoute(http.MethodGet, "/user/123", nil, mdlBuilder('s'))
+	r.addRoute(http.MethodGet, "/admin/*", nil, mdlBuilder('*'))
+	r.addRoute(http.MethodGet, "/admin/*/detail", nil, mdlBuilder('d'))
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantResp string
+	}{
+		{
+			name:     "param",
+			path:     "/user/tom",
+			wantResp: "/p",
+		},
+		{
+			name:     "param and reg",
+			path:     "/user/456",
+			wantResp: "/pr",
+		},
+		{
+			name:     "param, reg and static",
+			path:     "/user/123",
+			wantResp: "/prs",
+		},
+		{
+			// 注册在 /user/:id 上的 middleware 对它之下的所有路由都生效
+			name:     "below param",
+			path:     "/user/456/order/1",
+			wantResp: "/pr",
+		},
+		{
+			name:     "star",
+			path:     "/admin/abc",
+			wantResp: "/*",
+		},
+		{
+			name:     "below star",
+			path:     "/admin/abc/detail",
+			wantResp: "/*d",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf mdlCollector
+			mdls := mdlsOf(buf.collect(r.trees[http.MethodGet], strings.Trim(tc.path, "/")))
+			var root HandleFunc = func(ctx *Context) {
+				assert.Equal(t, tc.wantResp, string(ctx.RespData))
+			}
+			for i := len(mdls) - 1; i >= 0; i-- {
+				root = mdls[i](root)
+			}
+			root(&Context{})
+		})
+	}
+}
+
+func Test_node_handlerChain(t *testing.T) {
+	builds := 0
+	mdl := func(next HandleFunc) HandleFunc {
+		builds++
+		return next
+	}
+	s := NewHTTPServer()
+	s.UseV1(http.MethodGet, "/user", mdl)
+	s.Get("/user/:id", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/user/%d", i), nil)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+	assert.Equal(t, 1, builds)
+}
+
+func Test_router_findRoute_CatchAll(t *testing.T) {
+	mockHandler := func(ctx *Context) {}
+	r := newRouter()
+	r.addRoute(http.MethodGet, "/static/*filepath", mockHandler)
+	r.addRoute(http.MethodGet, "/static/index", mockHandler)
+
+	testCases := []struct {
+		name      string
+		path      string
+		found     bool
+		wantParam map[string]string
+	}{
+		{
+			name:      "one segment",
+			path:      "/static/app.css",
+			found:     true,
+			wantParam: map[string]string{"filepath": "app.css"},
+		},
+		{
+			name:      "multiple segments",
+			path:      "/static/css/theme/app.css",
+			found:     true,
+			wantParam: map[string]string{"filepath": "css/theme/app.css"},
+		},
+		{
+			name:  "static first",
+			path:  "/static/index",
+			found: true,
+		},
+		{
+			name: "empty",
+			path: "/static",
+			// /static 节点存在但是没有 handler
+			found: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mi, found := r.findRoute(http.MethodGet, tc.path)
+			assert.Equal(t, tc.found, found)
+			if !found {
+				return
+			}
+			assert.Equal(t, tc.wantParam, mi.pathParams)
+		})
+	}
+
+	assert.PanicsWithValue(t, "web: 非法路由，*filepath 只能出现在路由的最后 [/a/*filepath/b]", func() {
+		r.addRoute(http.MethodGet, "/a/*filepath/b", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 路由冲突，通配符路由冲突，已有 *filepath，新注册 *", func() {
+		r.addRoute(http.MethodGet, "/static/*", mockHandler)
+	})
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// Router 可以独立于 HTTPServer 构建的路由表
+// 典型的用法是在后台根据配置文件构建一个新的路由表，检查没有问题之后，
+// 通过 HTTPServer.SwapRouter 替换正在使用的路由表，这样不需要重启就可以启用或者禁用路由：
+//
+//	r := web.NewRouter()
+//	for _, rc := range cfg.Routes {
+//		if err := r.Handle(rc.Method, rc.Path, handlers[rc.Handler]); err != nil {
+//			return err
+//		}
+//	}
+//	s.SwapRouter(r)
+//
+// Router 不是并发安全的，构建的过程只能在一个 goroutine 里面完成
+type Router struct {
+	r *router
+}
+
+func NewRouter() *Router {
+	r := newRouter()
+	return &Router{r: &r}
+}
+
+// Handle 注册路由，规则和 HTTPServer 注册路由一致，handler 为 nil 的时候只注册 middleware
+// 非法的路由和冲突的路由会返回 error 而不是 panic，方便校验来自配置文件的路由，
+// 返回 error 的时候路由表不会有任何变化
+func (r *Router) Handle(method string, path string, handler HandleFunc, mdls ...Middleware) (err error) {
+	if atomic.LoadInt32(&r.r.serving) != 0 {
+		return errors.New("web: 路由表已经在使用中，不能再注册路由")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	r.r.addRoute(method, path, handler, mdls...)
+	return nil
+}
+
+// Routes 返回所有注册了 handler 的路由，可以用来在替换之前检查路由表
+func (r *Router) Routes() []RouteInfo {
+	return r.r.routes(nil)
+}
+
+// SwapRouter 原子地替换正在使用的路由表，返回之前的路由表，可以用于回滚
+// 已经开始处理的请求会继续使用之前的路由表，直到处理完毕；路由匹配的过程不需要加锁。
+// Server 级别的 middleware 通过 Use 注册，不属于路由表，替换之后仍然生效；
+// 但是之前创建的 RouteGroup 注册的是之前的路由表，不应该再继续使用。
+// 被替换进来的路由表不能再通过 Router.Handle 注册路由
+func (s *HTTPServer) SwapRouter(r *Router) *Router {
+	atomic.StoreInt32(&r.r.serving, 1)
+	// 提前编译，避免替换之后的第一个请求等待编译
+	r.r.compile()
+	old := s.router.Swap(r.r).(*router)
+	return &Router{r: old}
+}
+
+// currentRouter 正在使用的路由表
+func (s *HTTPServer) currentRouter() *router {
+	return s.router.Load().(*router)
+}
+
+// addRoute 在正在使用的路由表上注册路由
+// 和 Get、Post 之类的方法一样，只应该在启动之前调用，运行时修改路由表应该使用 SwapRouter
+func (s *HTTPServer) addRoute(method string, path string, handler HandleFunc, mdls ...Middleware) {
+	s.currentRouter().addRoute(method, path, handler, mdls...)
+}
+
+// addGroupRoute 在正在使用的路由表上注册分组下的路由
+func (s *HTTPServer) addGroupRoute(method string, path string, handler HandleFunc,
+	groupMdls []Middleware, mdls ...Middleware) {
+	s.currentRouter().addGroupRoute(method, path, handler, groupMdls, mdls...)
+}
@@ -0,0 +1,251 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_Handle(t *testing.T) {
+	mockHandler := func(ctx *Context) {}
+	r := NewRouter()
+	require.NoError(t, r.Handle(http.MethodGet, "/user/:id", mockHandler))
+	require.NoError(t, r.Handle(http.MethodGet, "/admin", nil))
+
+	testCases := []struct {
+		name    string
+		method  string
+		path    string
+		wantErr string
+	}{
+		{
+			name:    "conflict",
+			method:  http.MethodGet,
+			path:    "/user/:id",
+			wantErr: "web: 路由冲突[/user/:id]",
+		},
+		{
+			name:    "param conflict",
+			method:  http.MethodGet,
+			path:    "/user/:name",
+			wantErr: "web: 路由冲突，参数路由冲突，已有 :id，新注册 :name",
+		},
+		{
+			name:    "invalid path",
+			method:  http.MethodGet,
+			path:    "user",
+			wantErr: "web: 路由必须以 / 开头",
+		},
+		{
+			name:    "invalid type",
+			method:  http.MethodGet,
+			path:    "/order/:id<float>",
+			wantErr: "web: 非法路由，不支持的参数类型 float [:id<float>]",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := r.Handle(tc.method, tc.path, mockHandler)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+
+	// 出错之后路由表仍然可以继续使用
+	require.NoError(t, r.Handle(http.MethodPost, "/user", mockHandler))
+	assert.Equal(t, []string{"/user", "/user/:id"}, routeNames(r.Routes()))
+
+	s := NewHTTPServer()
+	s.SwapRouter(r)
+	assert.EqualError(t, r.Handle(http.MethodGet, "/order", mockHandler), "web: 路由表已经在使用中，不能再注册路由")
+}
+
+// TestRouter_Handle_NoPartialNodes 注册失败的时候不会留下注册了一半的节点
+func TestRouter_Handle_NoPartialNodes(t *testing.T) {
+	mockHandler := func(ctx *Context) {}
+	r := NewRouter()
+	require.NoError(t, r.Handle(http.MethodGet, "/user/:id", mockHandler))
+
+	testCases := []struct {
+		name    string
+		method  string
+		path    string
+		wantErr string
+	}{
+		{
+			name:    "invalid type",
+			method:  http.MethodGet,
+			path:    "/order/:id/:name<float>",
+			wantErr: "web: 非法路由，不支持的参数类型 float [:name<float>]",
+		},
+		{
+			name:    "catch all not last",
+			method:  http.MethodGet,
+			path:    "/order/:id/*path/detail",
+			wantErr: "web: 非法路由，*path 只能出现在路由的最后 [/order/:id/*path/detail]",
+		},
+		{
+			name:    "conflict below new node",
+			method:  http.MethodGet,
+			path:    "/user/:id/detail/:name/a//b",
+			wantErr: "web: 非法路由。不允许使用 //a/b, /a//b 之类的路由, [/user/:id/detail/:name/a//b]",
+		},
+		{
+			name:    "new method",
+			method:  http.MethodPost,
+			path:    "/user//detail",
+			wantErr: "web: 非法路由。不允许使用 //a/b, /a//b 之类的路由, [/user//detail]",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := r.Handle(tc.method, tc.path, mockHandler, func(next HandleFunc) HandleFunc {
+				return next
+			})
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+	assert.Equal(t, []string{"/user/:id"}, routeNames(r.Routes()))
+	_, ok := r.r.trees[http.MethodPost]
+	assert.False(t, ok)
+	root := r.r.trees[http.MethodGet]
+	assert.Len(t, root.children, 1)
+	user := root.children["user"]
+	id := user.paramChild
+	assert.Nil(t, id.children)
+	assert.Nil(t, id.starChild)
+	// middleware 的标记也没有被修改
+	for _, n := range []*node{root, user, id} {
+		assert.False(t, n.subMdls, n.path)
+	}
+}
+
+func TestHTTPServer_SwapRouter(t *testing.T) {
+	s := NewHTTPServer()
+	s.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			ctx.Resp.Header().Set("X-Server-Mdl", "1")
+		}
+	})
+	s.Get("/v1", func(ctx *Context) {
+		ctx.RespData = []byte("v1")
+	})
+
+	r := NewRouter()
+	require.NoError(t, r.Handle(http.MethodGet, "/v2", func(ctx *Context) {
+		ctx.RespData = []byte("v2")
+	}))
+	old := s.SwapRouter(r)
+
+	testCases := []struct {
+		path     string
+		wantCode int
+		wantResp string
+	}{
+		{path: "/v1", wantCode: http.StatusNotFound},
+		{path: "/v2", wantCode: http.StatusOK, wantResp: "v2"},
+	}
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		assert.Equal(t, tc.wantCode, recorder.Code, tc.path)
+		assert.Equal(t, tc.wantResp, recorder.Body.String(), tc.path)
+		// Server 级别的 middleware 不属于路由表
+		assert.Equal(t, "1", recorder.Header().Get("X-Server-Mdl"), tc.path)
+	}
+	assert.Equal(t, []string{"/v2"}, routeNames(s.Routes()))
+
+	// 回滚
+	s.SwapRouter(old)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1", nil))
+	assert.Equal(t, "v1", recorder.Body.String())
+}
+
+// TestHTTPServer_SwapRouter_InFlight 正在处理的请求使用旧的路由表处理完毕
+func TestHTTPServer_SwapRouter_InFlight(t *testing.T) {
+	s := NewHTTPServer()
+	s.UseV1(http.MethodGet, "/user", func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.Resp.Header().Set("X-Table", "old")
+			next(ctx)
+		}
+	})
+	started, release := make(chan struct{}), make(chan struct{})
+	s.Get("/user/:id", func(ctx *Context) {
+		close(started)
+		<-release
+		ctx.RespData = []byte("old " + ctx.PathParams["id"])
+	})
+
+	r := NewRouter()
+	require.NoError(t, r.Handle(http.MethodGet, "/user/:id", func(ctx *Context) {
+		ctx.RespData = []byte("new " + ctx.PathParams["id"])
+	}))
+
+	oldRecorder := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ServeHTTP(oldRecorder, httptest.NewRequest(http.MethodGet, "/user/1", nil))
+	}()
+	<-started
+	s.SwapRouter(r)
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user/2", nil))
+	assert.Equal(t, "new 2", recorder.Body.String())
+	assert.Equal(t, "", recorder.Header().Get("X-Table"))
+
+	close(release)
+	<-done
+	assert.Equal(t, "old 1", oldRecorder.Body.String())
+	assert.Equal(t, "old", oldRecorder.Header().Get("X-Table"))
+}
+
+// TestHTTPServer_SwapRouter_Concurrent 配合 -race 检查替换和匹配之间没有数据竞争
+func TestHTTPServer_SwapRouter_Concurrent(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/feature", func(ctx *Context) {
+		ctx.RespData = []byte("on")
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				recorder := httptest.NewRecorder()
+				s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/feature", nil))
+				// 只可能是启用或者禁用两种状态之一
+				if recorder.Code == http.StatusOK {
+					assert.Equal(t, "on", recorder.Body.String())
+				} else {
+					assert.Equal(t, http.StatusNotFound, recorder.Code)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		r := NewRouter()
+		if i%2 == 0 {
+			require.NoError(t, r.Handle(http.MethodGet, "/feature", func(ctx *Context) {
+				ctx.RespData = []byte("on")
+			}))
+		}
+		s.SwapRouter(r)
+	}
+	wg.Wait()
+}
+
+func routeNames(routes []RouteInfo) []string {
+	res := make([]string, 0, len(routes))
+	for _, r := range routes {
+		res = append(res, r.Route)
+	}
+	return res
+}
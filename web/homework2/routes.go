@@ -0,0 +1,118 @@
+package web
+
+import (
+	"sort"
+	"strings"
+)
+
+// RouteInfo 注册了的路由
+type RouteInfo struct {
+	Method string
+	// Route 注册时使用的路径，例如 /user/:id<int>
+	Route string
+	// Params 路径参数的名字，按照出现的顺序排列，匿名通配符 * 没有名字
+	Params []string
+	// Middlewares 直接注册在这个路由上的 middleware 数量，
+	// 不包括 Server、分组和前缀路由上的 middleware
+	Middlewares int
+	// Meta 通过 Describe 设置的描述信息，没有设置的时候为 nil
+	Meta *RouteMeta
+}
+
+// RouteMeta 路由的描述信息，用于生成 API 文档
+type RouteMeta struct {
+	Summary     string
+	Description string
+	Tags        []string
+	// Request 请求的类型，例如 UserReq{}，
+	// 带有 path、query、header 标签的字段是参数，其余的字段是请求体
+	Request any
+	// Response 成功时候的响应类型，例如 UserResp{}
+	Response any
+	// Deprecated 是否已经弃用
+	Deprecated bool
+}
+
+// Describe 设置路由的描述信息，可以在注册路由之前或者之后调用
+func (s *HTTPServer) Describe(method string, path string, meta RouteMeta) {
+	if s.metas == nil {
+		s.metas = make(map[string]*RouteMeta, 16)
+	}
+	s.metas[method+" "+path] = &meta
+}
+
+// Describe 设置分组下的路由的描述信息，path 是相对于分组前缀的路径
+func (g *RouteGroup) Describe(method string, path string, meta RouteMeta) {
+	g.s.Describe(method, g.fullPath(path), meta)
+}
+
+// Routes 返回所有注册了 handler 的路由，按照路径和 HTTP 方法排序
+// 只注册了 middleware 的节点，例如 UseV1 和 Group 注册的节点，不会被返回
+func (s *HTTPServer) Routes() []RouteInfo {
+	return s.currentRouter().routes(s.metas)
+}
+
+// routes 返回所有注册了 handler 的路由，metas 是路由的描述信息，METHOD path => RouteMeta
+func (r *router) routes(metas map[string]*RouteMeta) []RouteInfo {
+	res := make([]RouteInfo, 0, 16)
+	for method, root := range r.trees {
+		root.walk(func(n *node) {
+			if n.handler == nil {
+				return
+			}
+			route := n.route
+			if n == root {
+				route = "/"
+			}
+			res = append(res, RouteInfo{
+				Method:      method,
+				Route:       route,
+				Params:      routeParams(route),
+				Middlewares: len(n.mdls),
+				Meta:        metas[method+" "+route],
+			})
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Route != res[j].Route {
+			return res[i].Route < res[j].Route
+		}
+		return res[i].Method < res[j].Method
+	})
+	return res
+}
+
+// walk 遍历以 n 为根的路由树
+func (n *node) walk(fn func(n *node)) {
+	fn(n)
+	for _, c := range n.children {
+		c.walk(fn)
+	}
+	for _, c := range n.regChildren {
+		c.walk(fn)
+	}
+	if n.paramChild != nil {
+		n.paramChild.walk(fn)
+	}
+	if n.starChild != nil {
+		n.starChild.walk(fn)
+	}
+}
+
+// routeParams 解析路由中的路径参数的名字
+func routeParams(route string) []string {
+	var res []string
+	for _, seg := range strings.Split(route, "/") {
+		if seg == "" {
+			continue
+		}
+		if name, _, ok := parseRegPath(seg); ok {
+			res = append(res, name)
+			continue
+		}
+		if (seg[0] == ':' || seg[0] == '*') && len(seg) > 1 {
+			res = append(res, seg[1:])
+		}
+	}
+	return res
+}
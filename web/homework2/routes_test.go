@@ -0,0 +1,36 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPServer_Routes(t *testing.T) {
+	mdl := func(next HandleFunc) HandleFunc {
+		return next
+	}
+	handler := func(ctx *Context) {}
+	s := NewHTTPServer()
+	s.Get("/", handler)
+	s.Get("/user/:id<int>", handler, mdl, mdl)
+	s.Post("/user", handler)
+	s.Get("/static/*filepath", handler)
+	s.Get("/order/:id(^[0-9]+$)/*", handler)
+	// 只注册了 middleware 的节点不是路由
+	s.UseV1(http.MethodGet, "/admin", mdl)
+	g := s.Group("/api", mdl)
+	g.Delete("/user/:name", handler)
+	s.Describe(http.MethodPost, "/user", RouteMeta{Summary: "创建用户"})
+	g.Describe(http.MethodDelete, "/user/:name", RouteMeta{Summary: "删除用户"})
+
+	assert.Equal(t, []RouteInfo{
+		{Method: http.MethodGet, Route: "/"},
+		{Method: http.MethodDelete, Route: "/api/user/:name", Params: []string{"name"}, Meta: &RouteMeta{Summary: "删除用户"}},
+		{Method: http.MethodGet, Route: "/order/:id(^[0-9]+$)/*", Params: []string{"id"}},
+		{Method: http.MethodGet, Route: "/static/*filepath", Params: []string{"filepath"}},
+		{Method: http.MethodPost, Route: "/user", Meta: &RouteMeta{Summary: "创建用户"}},
+		{Method: http.MethodGet, Route: "/user/:id<int>", Params: []string{"id"}, Middlewares: 2},
+	}, s.Routes())
+}
@@ -1,12 +1,38 @@
 package web
 
 import (
+	"context"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type HandleFunc func(ctx *Context)
 
+// HandleErrFunc 返回 error 的 handler，通过 HandleErr 转化为 HandleFunc 之后注册：
+//
+//	s.Get("/user", web.HandleErr(func(ctx *web.Context) error {
+//		return ctx.RespJSONOK(user)
+//	}))
+type HandleErrFunc func(ctx *Context) error
+
+// HandleErr 把 HandleErrFunc 转化为 HandleFunc
+// 返回的 error 会被记录在 ctx.Err 上，并且响应码会被设置为 500，
+// 这样即便没有中间件处理 error，客户端也不会收到 200
+func HandleErr(h HandleErrFunc) HandleFunc {
+	return func(ctx *Context) {
+		if err := h(ctx); err != nil {
+			ctx.Err = err
+			ctx.RespStatusCode = http.StatusInternalServerError
+			ctx.RespData = []byte(http.StatusText(http.StatusInternalServerError))
+		}
+	}
+}
+
 type Server interface {
 	http.Handler
 	// Start 启动服务器
@@ -25,36 +51,100 @@ type Server interface {
 var _ Server = &HTTPServer{}
 
 type HTTPServer struct {
-	router
-	mdls []Middleware
+	// router 正在使用的路由表，类型是 *router，可以通过 SwapRouter 替换
+	router atomic.Value
+	mdls   []Middleware
+	// handler 组装好的 Server 级别的调用链，注册 middleware 的时候重新组装
+	handler HandleFunc
+	// ctxPool 复用 Context，以及 Context 内部的路径参数之类的缓冲区
+	ctxPool sync.Pool
+
+	validator Validator
+	tplEngine TemplateEngine
+	logFunc   func(msg string, args ...any)
+	// trustProxyHeaders Context.ClientIP 是否信任 X-Forwarded-For 和 X-Real-IP
+	trustProxyHeaders bool
+
+	// metas 路由的描述信息，METHOD path => RouteMeta
+	metas map[string]*RouteMeta
+
+	// srv 由 Start 系列方法创建，Shutdown 的时候关闭
+	srvMutex sync.Mutex
+	srv      *http.Server
+	// closing 不为 0 代表服务器正在关闭，新的请求会被拒绝
+	closing int32
+	// inFlight 正在处理的请求数量
+	inFlight    int64
+	beforeStart []Hook
+	afterStop   []Hook
 }
 
-func NewHTTPServer() *HTTPServer {
-	return &HTTPServer{
-		router: newRouter(),
+// Hook 生命周期回调
+// 使用 context.Context 来控制超时，回调需要自己处理超时
+type Hook func(ctx context.Context) error
+
+type HTTPServerOption func(server *HTTPServer)
+
+func NewHTTPServer(opts ...HTTPServerOption) *HTTPServer {
+	s := &HTTPServer{
+		logFunc: func(msg string, args ...any) {
+			log.Printf(msg, args...)
+		},
+	}
+	r := newRouter()
+	r.serving = 1
+	s.router.Store(&r)
+	s.ctxPool.New = func() any {
+		return &Context{respWriter: &responseWriter{}}
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.buildHandler()
+	return s
 }
 
-func (s *HTTPServer) Use(mdls ...Middleware) {
-	if s.mdls == nil {
-		s.mdls = mdls
-		return
+// ServerWithTemplateEngine 指定 Context.Render 使用的模板引擎
+func ServerWithTemplateEngine(engine TemplateEngine) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.tplEngine = engine
 	}
-	s.mdls = append(s.mdls, mdls...)
 }
 
-// UseV1 会执行路由匹配，只有匹配上了的 mdls 才会生效
-// 这个只需要稍微改造一下路由树就可以实现
-func (s *HTTPServer) UseV1(method string, path string, mdls ...Middleware) {
-	s.addRoute(method, path, nil, mdls...)
+// ServerWithLogFunc 指定记录框架内部错误的方法，例如回写响应失败
+func ServerWithLogFunc(logFunc func(msg string, args ...any)) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.logFunc = logFunc
+	}
 }
 
-// ServeHTTP HTTPServer 处理请求的入口
-func (s *HTTPServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	ctx := &Context{
-		Req:  request,
-		Resp: writer,
+// ServerWithProxyHeaders 让 Context.ClientIP 信任 X-Forwarded-For 和 X-Real-IP
+// 这两个头部都是客户端可以伪造的，只有部署在会覆盖这两个头部的代理后面才应该开启
+func ServerWithProxyHeaders() HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.trustProxyHeaders = true
+	}
+}
+
+// ServerWithValidator 指定 Context 上的 Bind 系列方法使用的 Validator
+// 默认使用 TagValidator
+func ServerWithValidator(v Validator) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.validator = v
 	}
+}
+
+func (s *HTTPServer) Use(mdls ...Middleware) {
+	if s.mdls == nil {
+		s.mdls = mdls
+	} else {
+		s.mdls = append(s.mdls, mdls...)
+	}
+	s.buildHandler()
+}
+
+// buildHandler 组装 Server 级别的调用链
+func (s *HTTPServer) buildHandler() {
 	// 最后一个应该是 HTTPServer 执行路由匹配，执行用户代码
 	root := s.serve
 	// 从后往前组装
@@ -67,16 +157,126 @@ func (s *HTTPServer) ServeHTTP(writer http.ResponseWriter, request *http.Request
 	var m Middleware = func(next HandleFunc) HandleFunc {
 		return func(ctx *Context) {
 			next(ctx)
-			s.flashResp(ctx)
+			if err := s.flashResp(ctx); err != nil {
+				s.logFunc("web: 回写响应失败 %v", err)
+			}
 		}
 	}
-	root = m(root)
-	root(ctx)
+	s.handler = m(root)
+}
+
+// UseV1 会执行路由匹配，只有匹配上了的 mdls 才会生效
+// mdls 会作用在 path 以及它之下的所有路由上，例如注册在 /admin/* 上的 mdls
+// 对 /admin/user 和 /admin/user/detail 都生效
+func (s *HTTPServer) UseV1(method string, path string, mdls ...Middleware) {
+	s.addRoute(method, path, nil, mdls...)
+}
+
+// ServeHTTP HTTPServer 处理请求的入口
+// Context 会被复用，handler 返回之后不能再使用它，除非调用了 Context.Retain
+func (s *HTTPServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	atomic.AddInt64(&s.inFlight, 1)
+	defer atomic.AddInt64(&s.inFlight, -1)
+	if atomic.LoadInt32(&s.closing) != 0 {
+		// 正在关闭，拒绝新请求
+		writer.Header().Set("Connection", "close")
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	ctx := s.ctxPool.Get().(*Context)
+	ctx.reset(s, writer, request)
+	s.handler(ctx)
+	// handler panic 的时候 Context 不会被放回去，这并不影响正确性
+	if !ctx.retained {
+		s.ctxPool.Put(ctx)
+	}
+}
+
+// OnBeforeStart 注册启动之前的回调，任何一个回调返回 error 都会导致启动失败
+func (s *HTTPServer) OnBeforeStart(hooks ...Hook) {
+	s.beforeStart = append(s.beforeStart, hooks...)
+}
+
+// OnAfterStop 注册关闭之后的回调，在所有请求处理完毕之后执行，例如释放数据库连接
+func (s *HTTPServer) OnAfterStop(hooks ...Hook) {
+	s.afterStop = append(s.afterStop, hooks...)
 }
 
 // Start 启动服务器
+// 调用 Shutdown 关闭服务器之后，Start 返回 nil。关闭之后可以再次调用 Start 重新启动
 func (s *HTTPServer) Start(addr string) error {
-	return http.ListenAndServe(addr, s)
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// StartTLS 启动 HTTPS 服务器
+func (s *HTTPServer) StartTLS(addr string, certFile string, keyFile string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.serveWith(l, func(srv *http.Server) error {
+		return srv.ServeTLS(l, certFile, keyFile)
+	})
+}
+
+// Serve 在 l 上接收请求
+func (s *HTTPServer) Serve(l net.Listener) error {
+	return s.serveWith(l, func(srv *http.Server) error {
+		return srv.Serve(l)
+	})
+}
+
+func (s *HTTPServer) serveWith(l net.Listener, serveFunc func(srv *http.Server) error) error {
+	// 路由都注册完毕了，提前编译，避免第一个请求等待编译
+	s.currentRouter().compile()
+	for _, hook := range s.beforeStart {
+		if err := hook(context.Background()); err != nil {
+			_ = l.Close()
+			return err
+		}
+	}
+	srv := &http.Server{Handler: s}
+	s.srvMutex.Lock()
+	s.srv = srv
+	// 之前调用过 Shutdown 的话，重新启动之后要恢复接收请求
+	atomic.StoreInt32(&s.closing, 0)
+	s.srvMutex.Unlock()
+	err := serveFunc(srv)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Shutdown 优雅关闭服务器
+// 1. 拒绝新的请求
+// 2. 等待正在处理的请求处理完毕，或者 ctx 超时
+// 3. 执行 OnAfterStop 注册的回调
+// 返回第一个遇到的 error，但是所有的回调都会被执行
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.closing, 1)
+	s.srvMutex.Lock()
+	srv := s.srv
+	s.srvMutex.Unlock()
+	var err error
+	if srv != nil {
+		err = srv.Shutdown(ctx)
+	}
+	for _, hook := range s.afterStop {
+		if hookErr := hook(ctx); hookErr != nil && err == nil {
+			err = hookErr
+		}
+	}
+	return err
+}
+
+// InFlight 正在处理的请求数量
+func (s *HTTPServer) InFlight() int64 {
+	return atomic.LoadInt64(&s.inFlight)
 }
 
 func (s *HTTPServer) Post(path string, handler HandleFunc) {
@@ -88,27 +288,72 @@ func (s *HTTPServer) Get(path string, handler HandleFunc, mdls ...Middleware) {
 }
 
 func (s *HTTPServer) serve(ctx *Context) {
-	mi, ok := s.findRoute(ctx.Req.Method, ctx.Req.URL.Path)
-	if !ok || mi.n == nil || mi.n.handler == nil {
-		ctx.RespStatusCode = 404
+	// 整个请求都使用同一个路由表，即便在处理的过程中路由表被替换了
+	rt := s.currentRouter()
+	method, path := ctx.Req.Method, ctx.Req.URL.Path
+	n, ps, ok := rt.lookup(method, path, ctx.paramBuf)
+	if !ok && method == http.MethodHead {
+		// 没有注册 HEAD 路由的时候，使用 GET 路由来处理，flashResp 不会回写响应体
+		method = http.MethodGet
+		n, ps, ok = rt.lookup(method, path, ctx.paramBuf)
+	}
+	if !ok {
+		s.serveNoRoute(rt, ctx)
+		return
+	}
+	ctx.paramBuf = ps
+	ctx.setPathParams(ps)
+	ctx.MatchedRoute = n.route
+	var mdlNodes []*node
+	if root := rt.trees[method]; path != "/" {
+		mdlNodes = ctx.mdls.collect(root, strings.Trim(path, "/"))
+	} else if len(root.mdls) > 0 {
+		// 根节点只会收集它自己的 middleware
+		mdlNodes = []*node{root}
+	}
+	n.handlerChain(mdlNodes)(ctx)
+}
+
+// serveNoRoute 处理没有命中路由的请求
+// - 如果 path 在其它 HTTP 方法下注册了路由，那么 OPTIONS 请求返回 204，其它请求返回 405，并且设置 Allow 头部
+// - 否则返回 404
+func (s *HTTPServer) serveNoRoute(rt *router, ctx *Context) {
+	allowed := rt.allowedMethods(ctx.Req.URL.Path)
+	if len(allowed) == 0 {
+		ctx.RespStatusCode = http.StatusNotFound
 		return
 	}
-	ctx.PathParams = mi.pathParams
-	ctx.MatchedRoute = mi.n.route
-	//mi.n.handler(ctx)
-	handle := mi.n.handler
-	for _, mdl := range mi.mdls {
-		handle = mdl(handle)
+	ctx.Resp.Header().Set("Allow", strings.Join(allowed, ", "))
+	if ctx.Req.Method == http.MethodOptions {
+		ctx.RespStatusCode = http.StatusNoContent
+		return
 	}
-	handle(ctx)
+	ctx.RespStatusCode = http.StatusMethodNotAllowed
 }
 
-func (s *HTTPServer) flashResp(ctx *Context) {
-	if ctx.RespStatusCode > 0 {
+func (s *HTTPServer) flashResp(ctx *Context) error {
+	if ctx.streaming {
+		// 流式响应已经直接写给了客户端
+		return nil
+	}
+	if ctx.Req.Method == http.MethodHead {
+		// HEAD 请求只回写响应头部，handler 自己设置了 Content-Length 的时候以它为准
+		if header := ctx.Resp.Header(); header.Get("Content-Length") == "" {
+			header.Set("Content-Length", strconv.Itoa(len(ctx.RespData)))
+		}
+		if ctx.RespStatusCode > 0 && ctx.WrittenStatus() == 0 {
+			ctx.Resp.WriteHeader(ctx.RespStatusCode)
+		}
+		return nil
+	}
+	// handler 直接通过 Resp 发送了响应码的时候，不能再发送一次
+	if ctx.RespStatusCode > 0 && ctx.WrittenStatus() == 0 {
 		ctx.Resp.WriteHeader(ctx.RespStatusCode)
 	}
-	_, err := ctx.Resp.Write(ctx.RespData)
-	if err != nil {
-		log.Fatalln("回写响应失败", err)
+	// 204、304 之类的响应不允许有响应体
+	if len(ctx.RespData) == 0 {
+		return nil
 	}
+	_, err := ctx.Resp.Write(ctx.RespData)
+	return err
 }
@@ -1 +1,305 @@
 package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServer_MethodNotAllowed(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/user", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("hello, user")
+	})
+	s.Post("/user", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusCreated
+	})
+	s.Post("/order", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusCreated
+	})
+
+	testCases := []struct {
+		name      string
+		method    string
+		path      string
+		wantCode  int
+		wantAllow string
+		wantBody  string
+	}{
+		{
+			name:     "get",
+			method:   http.MethodGet,
+			path:     "/user",
+			wantCode: http.StatusOK,
+			wantBody: "hello, user",
+		},
+		{
+			name:      "method not allowed",
+			method:    http.MethodDelete,
+			path:      "/user",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantAllow: "GET, HEAD, OPTIONS, POST",
+		},
+		{
+			name:      "method not allowed without get",
+			method:    http.MethodGet,
+			path:      "/order",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantAllow: "OPTIONS, POST",
+		},
+		{
+			name:      "options",
+			method:    http.MethodOptions,
+			path:      "/user",
+			wantCode:  http.StatusNoContent,
+			wantAllow: "GET, HEAD, OPTIONS, POST",
+		},
+		{
+			name:     "head",
+			method:   http.MethodHead,
+			path:     "/user",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "not found",
+			method:   http.MethodGet,
+			path:     "/abc",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "options not found",
+			method:   http.MethodOptions,
+			path:     "/abc",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantAllow, recorder.Header().Get("Allow"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestHTTPServer_Head(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/user", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("hello, user")
+	})
+	req := httptest.NewRequest(http.MethodHead, "/user", nil)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "11", recorder.Header().Get("Content-Length"))
+	assert.Equal(t, 0, recorder.Body.Len())
+
+	// handler 只返回了头部，例如文件的大小
+	s.Get("/file", func(ctx *Context) {
+		ctx.Resp.Header().Set("Content-Length", "1024")
+		ctx.RespStatusCode = http.StatusOK
+	})
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodHead, "/file", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "1024", recorder.Header().Get("Content-Length"))
+}
+
+func TestHTTPServer_Lifecycle(t *testing.T) {
+	var stopped bool
+	s := NewHTTPServer()
+	s.OnBeforeStart(func(ctx context.Context) error {
+		return nil
+	})
+	s.OnAfterStop(func(ctx context.Context) error {
+		stopped = true
+		return nil
+	})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s.Get("/slow", func(ctx *Context) {
+		close(started)
+		<-release
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("done")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Serve(l)
+	}()
+
+	respCh := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String() + "/slow")
+		if err != nil {
+			t.Error(err)
+		}
+		respCh <- resp
+	}()
+	<-started
+	assert.Equal(t, int64(1), s.InFlight())
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- s.Shutdown(context.Background())
+	}()
+	// 正在处理的请求没有结束，Shutdown 不会返回
+	select {
+	case <-shutdownErr:
+		t.Fatal("Shutdown 不应该在请求处理完毕之前返回")
+	case <-time.After(100 * time.Millisecond):
+	}
+	// 新的请求会被拒绝
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	close(release)
+	resp := <-respCh
+	require.NotNil(t, resp)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "done", string(body))
+
+	assert.NoError(t, <-shutdownErr)
+	assert.NoError(t, <-serveErr)
+	assert.True(t, stopped)
+	assert.Equal(t, int64(0), s.InFlight())
+}
+
+func TestHTTPServer_Restart(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/user", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- s.Serve(l)
+		}()
+		require.Eventually(t, func() bool {
+			resp, err := http.Get("http://" + l.Addr().String() + "/user")
+			if err != nil {
+				return false
+			}
+			_ = resp.Body.Close()
+			return resp.StatusCode == http.StatusOK
+		}, time.Second, 10*time.Millisecond)
+		require.NoError(t, s.Shutdown(context.Background()))
+		require.NoError(t, <-serveErr)
+	}
+}
+
+func TestHTTPServer_BeforeStartErr(t *testing.T) {
+	s := NewHTTPServer()
+	s.OnBeforeStart(func(ctx context.Context) error {
+		return errors.New("mock error")
+	})
+	err := s.Start("127.0.0.1:0")
+	assert.EqualError(t, err, "mock error")
+}
+
+type errWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (e errWriter) Write(bs []byte) (int, error) {
+	return 0, errors.New("mock write error")
+}
+
+func TestHTTPServer_WriteErr(t *testing.T) {
+	var logs []string
+	s := NewHTTPServer(ServerWithLogFunc(func(msg string, args ...any) {
+		logs = append(logs, fmt.Sprintf(msg, args...))
+	}))
+	s.Get("/user", func(ctx *Context) {
+		ctx.RespData = []byte("hello")
+	})
+	s.ServeHTTP(errWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, []string{"web: 回写响应失败 mock write error"}, logs)
+}
+
+func TestHandleErr(t *testing.T) {
+	var gotErr error
+	mockErr := errors.New("mock error")
+	s := NewHTTPServer()
+	s.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			gotErr = ctx.Err
+		}
+	})
+	s.Get("/user", HandleErr(func(ctx *Context) error {
+		return mockErr
+	}))
+	s.Get("/ok", HandleErr(func(ctx *Context) error {
+		return ctx.RespJSONOK("ok")
+	}))
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, mockErr, gotErr)
+
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Nil(t, gotErr)
+}
+
+// TestHTTPServer_ContextReuse 复用 Context 的时候，上一个请求的数据不能泄露到下一个请求
+func TestHTTPServer_ContextReuse(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/user/:id", func(ctx *Context) {
+		ctx.UserValues = map[string]any{"id": ctx.PathParams["id"]}
+		ctx.RespData = []byte(ctx.PathParams["id"])
+	})
+	s.Get("/user/home", func(ctx *Context) {
+		if ctx.PathParams != nil || ctx.UserValues != nil || len(ctx.RespData) > 0 {
+			ctx.RespStatusCode = http.StatusInternalServerError
+			return
+		}
+		ctx.RespData = []byte("home")
+	})
+	s.Get("/a/:x/b/:y", func(ctx *Context) {
+		ctx.RespData = []byte(fmt.Sprintf("%d", len(ctx.PathParams)))
+	})
+	testCases := []struct {
+		path     string
+		wantCode int
+		wantResp string
+	}{
+		{path: "/user/123", wantCode: http.StatusOK, wantResp: "123"},
+		{path: "/user/home", wantCode: http.StatusOK, wantResp: "home"},
+		{path: "/user/456", wantCode: http.StatusOK, wantResp: "456"},
+		{path: "/a/1/b/2", wantCode: http.StatusOK, wantResp: "2"},
+		{path: "/user/789", wantCode: http.StatusOK, wantResp: "789"},
+		{path: "/user/home", wantCode: http.StatusOK, wantResp: "home"},
+	}
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		assert.Equal(t, tc.wantCode, recorder.Code, tc.path)
+		assert.Equal(t, tc.wantResp, recorder.Body.String(), tc.path)
+	}
+}
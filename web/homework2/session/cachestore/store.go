@@ -0,0 +1,159 @@
+package cachestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	cache "gitee.com/geektime-geekbang/geektime-go/cache/homework1"
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/session"
+)
+
+// Store 基于 cache.Cache 的 session.Store
+// Session 的数据会被序列化为 JSON 保存，过期依赖于缓存本身的过期机制。
+// 因为经过了 JSON 序列化，Get 返回的数字是 float64，结构体是 map[string]any
+type Store struct {
+	c          cache.Cache
+	prefix     string
+	expiration time.Duration
+	// locks 按照 session id 分段的锁，串行化同一个进程内对同一个 Session 的读取-修改-写回
+	locks [64]sync.Mutex
+}
+
+// Expirer 支持单独设置过期时间的缓存，例如 Redis 的 EXPIRE 命令
+// cache.Cache 实现了这个接口的时候，Refresh 只会修改过期时间，不会重新写入 Session 的数据
+type Expirer interface {
+	Expire(ctx context.Context, key string, expiration time.Duration) error
+}
+
+type StoreOption func(s *Store)
+
+func NewStore(c cache.Cache, expiration time.Duration, opts ...StoreOption) *Store {
+	s := &Store{
+		c:          c,
+		prefix:     "session",
+		expiration: expiration,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// StoreWithPrefix 缓存中 key 的前缀，默认是 session
+func StoreWithPrefix(prefix string) StoreOption {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+func (s *Store) Generate(ctx context.Context, id string) (session.Session, error) {
+	sess := &cacheSession{id: id, store: s}
+	if err := s.c.Set(ctx, s.key(id), []byte("{}"), s.expiration); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Refresh 缓存实现了 Expirer 的时候只刷新过期时间
+// 否则只能读取之后重新写入一遍，这时候会和 Set 持有同一把锁，避免覆盖掉并发写入的数据。
+// 这把锁只在进程内有效，多个实例共享同一个缓存的时候，缓存应该实现 Expirer
+func (s *Store) Refresh(ctx context.Context, id string) error {
+	if e, ok := s.c.(Expirer); ok {
+		if err := e.Expire(ctx, s.key(id), s.expiration); err != nil {
+			return fmt.Errorf("%w: %v", session.ErrSessionNotFound, err)
+		}
+		return nil
+	}
+	mu := s.lock(id)
+	mu.Lock()
+	defer mu.Unlock()
+	val, err := s.load(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.c.Set(ctx, s.key(id), val, s.expiration)
+}
+
+func (s *Store) Remove(ctx context.Context, id string) error {
+	return s.c.Delete(ctx, s.key(id))
+}
+
+func (s *Store) Get(ctx context.Context, id string) (session.Session, error) {
+	if _, err := s.load(ctx, id); err != nil {
+		return nil, err
+	}
+	return &cacheSession{id: id, store: s}, nil
+}
+
+// load 读取 Session 的原始数据
+// cache.Cache 没有区分 key 不存在和其它错误，所以都视为找不到 Session
+func (s *Store) load(ctx context.Context, id string) ([]byte, error) {
+	val, err := s.c.Get(ctx, s.key(id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", session.ErrSessionNotFound, err)
+	}
+	return val, nil
+}
+
+func (s *Store) key(id string) string {
+	return s.prefix + ":" + id
+}
+
+func (s *Store) lock(id string) *sync.Mutex {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return &s.locks[h.Sum32()%uint32(len(s.locks))]
+}
+
+type cacheSession struct {
+	id    string
+	store *Store
+}
+
+func (c *cacheSession) Get(ctx context.Context, key string) (any, error) {
+	values, err := c.values(ctx)
+	if err != nil {
+		return nil, err
+	}
+	val, ok := values[key]
+	if !ok {
+		return nil, session.ErrKeyNotFound
+	}
+	return val, nil
+}
+
+// Set 采用读取-修改-写回的方式，同一个进程内的修改是串行的；
+// 多个实例并发修改同一个 Session 的时候，后写入的会覆盖先写入的
+func (c *cacheSession) Set(ctx context.Context, key string, val any) error {
+	mu := c.store.lock(c.id)
+	mu.Lock()
+	defer mu.Unlock()
+	values, err := c.values(ctx)
+	if err != nil {
+		return err
+	}
+	values[key] = val
+	bs, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return c.store.c.Set(ctx, c.store.key(c.id), bs, c.store.expiration)
+}
+
+func (c *cacheSession) ID() string {
+	return c.id
+}
+
+func (c *cacheSession) values(ctx context.Context) (map[string]any, error) {
+	bs, err := c.store.load(ctx, c.id)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]any, 4)
+	err = json.Unmarshal(bs, &values)
+	return values, err
+}
@@ -0,0 +1,143 @@
+package cachestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	c := &mapCache{data: map[string][]byte{}}
+	s := NewStore(c, time.Minute, StoreWithPrefix("sess"))
+	ctx := context.Background()
+
+	sess, err := s.Generate(ctx, "sess1")
+	require.NoError(t, err)
+	require.NoError(t, sess.Set(ctx, "uid", 123))
+	assert.Equal(t, `{"uid":123}`, string(c.data["sess:sess1"]))
+
+	sess, err = s.Get(ctx, "sess1")
+	require.NoError(t, err)
+	val, err := sess.Get(ctx, "uid")
+	require.NoError(t, err)
+	assert.Equal(t, float64(123), val)
+	_, err = sess.Get(ctx, "name")
+	assert.Equal(t, session.ErrKeyNotFound, err)
+
+	require.NoError(t, s.Refresh(ctx, "sess1"))
+	require.NoError(t, s.Remove(ctx, "sess1"))
+	_, err = s.Get(ctx, "sess1")
+	assert.True(t, errors.Is(err, session.ErrSessionNotFound))
+	assert.True(t, errors.Is(s.Refresh(ctx, "sess1"), session.ErrSessionNotFound))
+}
+
+// mapCache 测试用的 cache.Cache，忽略过期时间
+type mapCache struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+}
+
+func (m *mapCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	val, ok := m.data[key]
+	if !ok {
+		return nil, errors.New("cache: key 不存在")
+	}
+	return val, nil
+}
+
+func (m *mapCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data[key] = val
+	return nil
+}
+
+func (m *mapCache) Delete(ctx context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+func (m *mapCache) LoadAndDelete(ctx context.Context, key string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	val, ok := m.data[key]
+	if !ok {
+		return nil, errors.New("cache: key 不存在")
+	}
+	delete(m.data, key)
+	return val, nil
+}
+
+func (m *mapCache) OnEvicted(func(key string, val []byte)) {}
+
+func TestStore_RefreshConcurrently(t *testing.T) {
+	c := &mapCache{data: map[string][]byte{}}
+	s := NewStore(c, time.Minute)
+	ctx := context.Background()
+	sess, err := s.Generate(ctx, "sess1")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, sess.Set(ctx, fmt.Sprintf("key%d", i), i))
+		}(i)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.Refresh(ctx, "sess1"))
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 20; i++ {
+		val, err := sess.Get(ctx, fmt.Sprintf("key%d", i))
+		require.NoError(t, err)
+		assert.Equal(t, float64(i), val)
+	}
+}
+
+func TestStore_RefreshExpirer(t *testing.T) {
+	c := &expireCache{mapCache: mapCache{data: map[string][]byte{}}, ttl: map[string]time.Duration{}}
+	s := NewStore(c, time.Minute)
+	ctx := context.Background()
+	_, err := s.Generate(ctx, "sess1")
+	require.NoError(t, err)
+	c.sets = 0
+
+	require.NoError(t, s.Refresh(ctx, "sess1"))
+	assert.Equal(t, 0, c.sets)
+	assert.Equal(t, time.Minute, c.ttl["session:sess1"])
+	assert.True(t, errors.Is(s.Refresh(ctx, "sess2"), session.ErrSessionNotFound))
+}
+
+// expireCache 实现了 Expirer 的 cache.Cache，记录 Set 的次数
+type expireCache struct {
+	mapCache
+	sets int
+	ttl  map[string]time.Duration
+}
+
+func (e *expireCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	e.sets++
+	return e.mapCache.Set(ctx, key, val, expiration)
+}
+
+func (e *expireCache) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	if _, err := e.Get(ctx, key); err != nil {
+		return err
+	}
+	e.ttl[key] = expiration
+	return nil
+}
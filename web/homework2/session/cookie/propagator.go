@@ -0,0 +1,80 @@
+package cookie
+
+import (
+	"net/http"
+)
+
+// Propagator 使用 cookie 传递 session id
+type Propagator struct {
+	cookieName string
+	// cookieOptions 按照顺序应用，第一个设置了 Path 和 HttpOnly 的默认值
+	cookieOptions []func(c *http.Cookie)
+}
+
+type PropagatorOption func(p *Propagator)
+
+func NewPropagator(opts ...PropagatorOption) *Propagator {
+	p := &Propagator{
+		cookieName: "sessid",
+		cookieOptions: []func(c *http.Cookie){
+			func(c *http.Cookie) {
+				c.Path = "/"
+				c.HttpOnly = true
+			},
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithCookieName cookie 的名字，默认是 sessid
+func WithCookieName(name string) PropagatorOption {
+	return func(p *Propagator) {
+		p.cookieName = name
+	}
+}
+
+// WithCookieOption 设置 cookie 的其它属性，例如 Domain、Secure、MaxAge
+// opt 在默认值 Path=/ 和 HttpOnly 之后执行，可以多次调用，按照调用的顺序执行
+func WithCookieOption(opt func(c *http.Cookie)) PropagatorOption {
+	return func(p *Propagator) {
+		p.cookieOptions = append(p.cookieOptions, opt)
+	}
+}
+
+func (p *Propagator) applyOptions(c *http.Cookie) {
+	for _, opt := range p.cookieOptions {
+		opt(c)
+	}
+}
+
+func (p *Propagator) Inject(id string, writer http.ResponseWriter) error {
+	c := &http.Cookie{
+		Name:  p.cookieName,
+		Value: id,
+	}
+	p.applyOptions(c)
+	http.SetCookie(writer, c)
+	return nil
+}
+
+func (p *Propagator) Extract(req *http.Request) (string, error) {
+	c, err := req.Cookie(p.cookieName)
+	if err != nil {
+		return "", err
+	}
+	return c.Value, nil
+}
+
+func (p *Propagator) Remove(writer http.ResponseWriter) error {
+	c := &http.Cookie{
+		Name:   p.cookieName,
+		MaxAge: -1,
+	}
+	p.applyOptions(c)
+	c.MaxAge = -1
+	http.SetCookie(writer, c)
+	return nil
+}
@@ -0,0 +1,53 @@
+package cookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagator_Inject(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []PropagatorOption
+		want string
+	}{
+		{
+			name: "default",
+			want: "sessid=abc; Path=/; HttpOnly",
+		},
+		{
+			name: "options keep defaults",
+			opts: []PropagatorOption{
+				WithCookieName("sid"),
+				WithCookieOption(func(c *http.Cookie) {
+					c.Secure = true
+				}),
+				WithCookieOption(func(c *http.Cookie) {
+					c.Domain = "example.com"
+				}),
+			},
+			want: "sid=abc; Path=/; Domain=example.com; HttpOnly; Secure",
+		},
+		{
+			name: "override default",
+			opts: []PropagatorOption{
+				WithCookieOption(func(c *http.Cookie) {
+					c.Path = "/api"
+				}),
+			},
+			want: "sessid=abc; Path=/api; HttpOnly",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewPropagator(tc.opts...)
+			recorder := httptest.NewRecorder()
+			require.NoError(t, p.Inject("abc", recorder))
+			assert.Equal(t, tc.want, recorder.Header().Get("Set-Cookie"))
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package header
+
+import (
+	"net/http"
+
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/session"
+)
+
+// Propagator 使用 HTTP 头部传递 session id
+// 适用于 App 之类不方便使用 cookie 的客户端
+type Propagator struct {
+	headerName string
+}
+
+// NewPropagator headerName 为空的时候使用 X-Session-ID
+func NewPropagator(headerName string) *Propagator {
+	if headerName == "" {
+		headerName = "X-Session-ID"
+	}
+	return &Propagator{headerName: headerName}
+}
+
+func (p *Propagator) Inject(id string, writer http.ResponseWriter) error {
+	writer.Header().Set(p.headerName, id)
+	return nil
+}
+
+func (p *Propagator) Extract(req *http.Request) (string, error) {
+	id := req.Header.Get(p.headerName)
+	if id == "" {
+		return "", session.ErrSessionNotFound
+	}
+	return id, nil
+}
+
+// Remove 头部没有删除的语义，返回空值通知客户端丢弃 session id
+func (p *Propagator) Remove(writer http.ResponseWriter) error {
+	writer.Header().Set(p.headerName, "")
+	return nil
+}
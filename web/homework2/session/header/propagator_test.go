@@ -0,0 +1,49 @@
+package header
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/session"
+)
+
+func TestPropagator(t *testing.T) {
+	testCases := []struct {
+		name       string
+		headerName string
+		wantHeader string
+	}{
+		{
+			name:       "default",
+			wantHeader: "X-Session-ID",
+		},
+		{
+			name:       "custom",
+			headerName: "X-Token",
+			wantHeader: "X-Token",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewPropagator(tc.headerName)
+			recorder := httptest.NewRecorder()
+			require.NoError(t, p.Inject("abc", recorder))
+			assert.Equal(t, "abc", recorder.Header().Get(tc.wantHeader))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			_, err := p.Extract(req)
+			assert.Equal(t, session.ErrSessionNotFound, err)
+			req.Header.Set(tc.wantHeader, "abc")
+			id, err := p.Extract(req)
+			require.NoError(t, err)
+			assert.Equal(t, "abc", id)
+
+			require.NoError(t, p.Remove(recorder))
+			assert.Equal(t, []string{""}, recorder.Header().Values(tc.wantHeader))
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package session
+
+import (
+	"net/http"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+)
+
+// LoginMiddlewareBuilder 检查请求是否已经登录，也就是是否有合法的 Session
+// 没有登录的请求，设置了 redirectURL 的时候会被重定向，否则返回 401
+type LoginMiddlewareBuilder struct {
+	manager     *Manager
+	redirectURL string
+	excludes    map[string]struct{}
+}
+
+func NewLoginMiddlewareBuilder(m *Manager) *LoginMiddlewareBuilder {
+	return &LoginMiddlewareBuilder{
+		manager:  m,
+		excludes: make(map[string]struct{}, 4),
+	}
+}
+
+// RedirectURL 没有登录的时候重定向的地址，例如登录页面
+func (b *LoginMiddlewareBuilder) RedirectURL(url string) *LoginMiddlewareBuilder {
+	b.redirectURL = url
+	return b
+}
+
+// Exclude 不需要登录的路径，例如登录接口本身
+func (b *LoginMiddlewareBuilder) Exclude(paths ...string) *LoginMiddlewareBuilder {
+	for _, p := range paths {
+		b.excludes[p] = struct{}{}
+	}
+	return b
+}
+
+func (b *LoginMiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			if _, ok := b.excludes[ctx.Req.URL.Path]; ok {
+				next(ctx)
+				return
+			}
+			if _, err := b.manager.GetSession(ctx); err != nil {
+				if b.redirectURL != "" {
+					ctx.Resp.Header().Set("Location", b.redirectURL)
+					ctx.RespStatusCode = http.StatusFound
+					return
+				}
+				ctx.RespStatusCode = http.StatusUnauthorized
+				return
+			}
+			next(ctx)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package session
+
+import (
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+	"github.com/google/uuid"
+)
+
+// Manager 组合 Store 和 Propagator，提供面向 web.Context 的操作
+// 同一个请求里面，Session 会被缓存在 web.Context.UserValues 中
+type Manager struct {
+	Store
+	Propagator
+	// CtxSessKey Session 在 web.Context.UserValues 中的 key
+	CtxSessKey string
+}
+
+func NewManager(store Store, propagator Propagator) *Manager {
+	return &Manager{
+		Store:      store,
+		Propagator: propagator,
+		CtxSessKey: "_sess",
+	}
+}
+
+// GetSession 返回当前请求的 Session
+// 第一次访问的时候会刷新 Session 的过期时间
+func (m *Manager) GetSession(ctx *web.Context) (Session, error) {
+	if val, ok := ctx.UserValues[m.CtxSessKey]; ok {
+		return val.(Session), nil
+	}
+	id, err := m.Extract(ctx.Req)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := m.Get(ctx.Req.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.Refresh(ctx.Req.Context(), id); err != nil {
+		return nil, err
+	}
+	m.cache(ctx, sess)
+	return sess, nil
+}
+
+// InitSession 创建一个新的 Session，并且将 session id 写入响应
+// 一般是在登录成功之后调用
+func (m *Manager) InitSession(ctx *web.Context) (Session, error) {
+	id := uuid.New().String()
+	sess, err := m.Generate(ctx.Req.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.Inject(id, ctx.Resp); err != nil {
+		return nil, err
+	}
+	m.cache(ctx, sess)
+	return sess, nil
+}
+
+// RefreshSession 刷新 Session 的过期时间，并且重新将 session id 写入响应
+// 适用于 session id 在客户端也有过期时间的场景，例如设置了 MaxAge 的 cookie
+func (m *Manager) RefreshSession(ctx *web.Context) (Session, error) {
+	sess, err := m.GetSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sess, m.Inject(sess.ID(), ctx.Resp)
+}
+
+// RemoveSession 删除 Session，一般是在退出登录的时候调用
+func (m *Manager) RemoveSession(ctx *web.Context) error {
+	sess, err := m.GetSession(ctx)
+	if err != nil {
+		return err
+	}
+	if err = m.Store.Remove(ctx.Req.Context(), sess.ID()); err != nil {
+		return err
+	}
+	delete(ctx.UserValues, m.CtxSessKey)
+	return m.Propagator.Remove(ctx.Resp)
+}
+
+func (m *Manager) cache(ctx *web.Context, sess Session) {
+	if ctx.UserValues == nil {
+		ctx.UserValues = make(map[string]any, 1)
+	}
+	ctx.UserValues[m.CtxSessKey] = sess
+}
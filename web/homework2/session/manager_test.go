@@ -0,0 +1,93 @@
+package session_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	web "gitee.com/geektime-geekbang/geektime-go/web/homework2"
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/session"
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/session/cookie"
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+	store := memory.NewStore(time.Minute)
+	defer store.Close()
+	m := session.NewManager(store, cookie.NewPropagator())
+
+	s := web.NewHTTPServer()
+	s.Use(session.NewLoginMiddlewareBuilder(m).Exclude("/login").Build())
+	s.Post("/login", func(ctx *web.Context) {
+		sess, err := m.InitSession(ctx)
+		if err != nil {
+			ctx.RespStatusCode = http.StatusInternalServerError
+			return
+		}
+		_ = sess.Set(ctx.Req.Context(), "uid", "123")
+		ctx.RespStatusCode = http.StatusOK
+	})
+	s.Get("/profile", func(ctx *web.Context) {
+		sess, err := m.GetSession(ctx)
+		if err != nil {
+			ctx.RespStatusCode = http.StatusInternalServerError
+			return
+		}
+		uid, _ := sess.Get(ctx.Req.Context(), "uid")
+		ctx.RespData = []byte(uid.(string))
+	})
+	s.Post("/logout", func(ctx *web.Context) {
+		_ = m.RemoveSession(ctx)
+	})
+
+	// 没有登录
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/profile", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	// 登录
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/login", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	sessCookie := cookies[0]
+	assert.Equal(t, "sessid", sessCookie.Name)
+
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.AddCookie(sessCookie)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "123", recorder.Body.String())
+
+	// 退出登录
+	req = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(sessCookie)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, -1, recorder.Result().Cookies()[0].MaxAge)
+
+	req = httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.AddCookie(sessCookie)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestLoginMiddlewareBuilder_Redirect(t *testing.T) {
+	store := memory.NewStore(time.Minute)
+	defer store.Close()
+	m := session.NewManager(store, cookie.NewPropagator())
+	s := web.NewHTTPServer()
+	s.Use(session.NewLoginMiddlewareBuilder(m).RedirectURL("/login").Build())
+	s.Get("/profile", func(ctx *web.Context) {})
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/profile", nil))
+	assert.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t, "/login", recorder.Header().Get("Location"))
+}
@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/session"
+)
+
+// Store 基于内存的 session.Store
+// 过期的 Session 在访问的时候会被惰性删除，同时后台会定期清理
+type Store struct {
+	mutex      sync.RWMutex
+	sessions   map[string]*memorySession
+	expiration time.Duration
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewStore 创建一个 Store，Session 在 expiration 时间内没有被访问就会过期
+// 不再使用的时候需要调用 Close 停止后台清理
+// expiration 必须大于 0，否则会 panic
+func NewStore(expiration time.Duration) *Store {
+	if expiration <= 0 {
+		panic("memory: expiration 必须大于 0")
+	}
+	s := &Store{
+		sessions:   make(map[string]*memorySession, 64),
+		expiration: expiration,
+		closeCh:    make(chan struct{}),
+	}
+	interval := expiration
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	go s.cleanup(interval)
+	return s
+}
+
+func (s *Store) Generate(ctx context.Context, id string) (session.Session, error) {
+	sess := &memorySession{
+		id:       id,
+		values:   make(map[string]any, 4),
+		deadline: time.Now().Add(s.expiration),
+	}
+	s.mutex.Lock()
+	s.sessions[id] = sess
+	s.mutex.Unlock()
+	return sess, nil
+}
+
+func (s *Store) Refresh(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok || sess.expired(time.Now()) {
+		return session.ErrSessionNotFound
+	}
+	sess.deadline = time.Now().Add(s.expiration)
+	return nil
+}
+
+func (s *Store) Remove(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	delete(s.sessions, id)
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (session.Session, error) {
+	s.mutex.RLock()
+	sess, ok := s.sessions[id]
+	// deadline 由锁保护，需要在持有锁的时候判断
+	expired := ok && sess.expired(time.Now())
+	s.mutex.RUnlock()
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+	if expired {
+		s.mutex.Lock()
+		// double check，避免删掉刚刚刷新过的 Session
+		if sess.expired(time.Now()) {
+			delete(s.sessions, id)
+		}
+		s.mutex.Unlock()
+		return nil, session.ErrSessionNotFound
+	}
+	return sess, nil
+}
+
+// Close 停止后台清理
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+	return nil
+}
+
+func (s *Store) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.mutex.Lock()
+			for id, sess := range s.sessions {
+				if sess.expired(now) {
+					delete(s.sessions, id)
+				}
+			}
+			s.mutex.Unlock()
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+type memorySession struct {
+	id string
+	// deadline 由 Store 的锁保护
+	deadline time.Time
+
+	mutex  sync.RWMutex
+	values map[string]any
+}
+
+func (m *memorySession) Get(ctx context.Context, key string) (any, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	val, ok := m.values[key]
+	if !ok {
+		return nil, session.ErrKeyNotFound
+	}
+	return val, nil
+}
+
+func (m *memorySession) Set(ctx context.Context, key string, val any) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.values[key] = val
+	return nil
+}
+
+func (m *memorySession) ID() string {
+	return m.id
+}
+
+func (m *memorySession) expired(now time.Time) bool {
+	return now.After(m.deadline)
+}
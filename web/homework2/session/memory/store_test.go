@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(100 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	sess, err := s.Generate(ctx, "sess1")
+	require.NoError(t, err)
+	require.NoError(t, sess.Set(ctx, "name", "Tom"))
+	_, err = sess.Get(ctx, "age")
+	assert.Equal(t, session.ErrKeyNotFound, err)
+
+	sess, err = s.Get(ctx, "sess1")
+	require.NoError(t, err)
+	val, err := sess.Get(ctx, "name")
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", val)
+
+	// 刷新之后不会过期
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, s.Refresh(ctx, "sess1"))
+	time.Sleep(60 * time.Millisecond)
+	_, err = s.Get(ctx, "sess1")
+	require.NoError(t, err)
+
+	// 过期
+	time.Sleep(150 * time.Millisecond)
+	_, err = s.Get(ctx, "sess1")
+	assert.Equal(t, session.ErrSessionNotFound, err)
+	assert.Equal(t, session.ErrSessionNotFound, s.Refresh(ctx, "sess1"))
+
+	// 删除
+	_, err = s.Generate(ctx, "sess2")
+	require.NoError(t, err)
+	require.NoError(t, s.Remove(ctx, "sess2"))
+	_, err = s.Get(ctx, "sess2")
+	assert.Equal(t, session.ErrSessionNotFound, err)
+}
+
+func TestStore_Cleanup(t *testing.T) {
+	s := NewStore(10 * time.Millisecond)
+	defer s.Close()
+	_, err := s.Generate(context.Background(), "sess1")
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	assert.Empty(t, s.sessions)
+}
+
+// TestStore_Concurrent 配合 -race 检查 Get 和 Refresh 之间没有数据竞争
+func TestStore_Concurrent(t *testing.T) {
+	s := NewStore(time.Minute)
+	defer s.Close()
+	ctx := context.Background()
+	_, err := s.Generate(ctx, "sess1")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = s.Get(ctx, "sess1")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = s.Refresh(ctx, "sess1")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewStore_InvalidExpiration(t *testing.T) {
+	assert.PanicsWithValue(t, "memory: expiration 必须大于 0", func() {
+		NewStore(0)
+	})
+}
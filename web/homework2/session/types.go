@@ -0,0 +1,41 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session: 找不到 session")
+	ErrKeyNotFound     = errors.New("session: 找不到 key")
+)
+
+// Session 代表一个用户的会话数据
+type Session interface {
+	// Get 返回 key 对应的值，不存在的时候返回 ErrKeyNotFound
+	Get(ctx context.Context, key string) (any, error)
+	Set(ctx context.Context, key string, val any) error
+	ID() string
+}
+
+// Store 管理 Session 本身
+type Store interface {
+	// Generate 创建一个 Session，id 由调用者指定
+	Generate(ctx context.Context, id string) (Session, error)
+	// Refresh 刷新 Session 的过期时间
+	Refresh(ctx context.Context, id string) error
+	Remove(ctx context.Context, id string) error
+	// Get 查找 Session，不存在或者已经过期的时候返回 ErrSessionNotFound
+	Get(ctx context.Context, id string) (Session, error)
+}
+
+// Propagator 在请求和响应中传递 session id
+type Propagator interface {
+	// Inject 将 session id 写入响应
+	Inject(id string, writer http.ResponseWriter) error
+	// Extract 从请求中读取 session id
+	Extract(req *http.Request) (string, error)
+	// Remove 通知客户端删除 session id
+	Remove(writer http.ResponseWriter) error
+}
@@ -0,0 +1,241 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// responseWriter 记录响应码和已经写入的字节数
+// 流式响应和直接使用 Context.Resp 写入的响应，都需要依赖它来统计
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(bs []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(bs)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("web: ResponseWriter 不支持 Hijack")
+	}
+	return h.Hijack()
+}
+
+// Unwrap 返回原生的 ResponseWriter，用于 http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RespSize 响应体的字节数
+// 流式响应或者直接写入 Resp 的时候，返回已经写入的字节数，否则返回 RespData 的长度
+func (c *Context) RespSize() int {
+	if c.respWriter != nil && (c.streaming || c.respWriter.size > 0) {
+		return c.respWriter.size
+	}
+	return len(c.RespData)
+}
+
+// WrittenStatus 已经发送给客户端的响应码
+// 流式响应或者直接写入 Resp 的时候，RespStatusCode 可能和真正发送的响应码不一致，
+// 例如 http.ServeContent 返回的 304 和 206；还没有发送响应码的时候返回 0
+func (c *Context) WrittenStatus() int {
+	if c.respWriter == nil {
+		return 0
+	}
+	return c.respWriter.status
+}
+
+// Streaming 是否已经进入流式响应
+func (c *Context) Streaming() bool {
+	return c.streaming
+}
+
+// startStream 进入流式响应
+// 响应头部会立刻发送，之后 RespStatusCode 和 RespData 都不再生效，
+// 已经缓存在 RespData 中的数据会被先发送出去
+func (c *Context) startStream() error {
+	if c.streaming {
+		return nil
+	}
+	c.streaming = true
+	if c.RespStatusCode == 0 {
+		c.RespStatusCode = http.StatusOK
+	}
+	c.Resp.WriteHeader(c.RespStatusCode)
+	if len(c.RespData) > 0 {
+		data := c.RespData
+		c.RespData = nil
+		if _, err := c.Resp.Write(data); err != nil {
+			return err
+		}
+	}
+	c.flush()
+	return nil
+}
+
+func (c *Context) flush() {
+	if f, ok := c.Resp.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// WriteChunk 以流式的方式写入 data，并且立刻发送给客户端
+// 第一次调用会进入流式响应
+func (c *Context) WriteChunk(data []byte) error {
+	if err := c.startStream(); err != nil {
+		return err
+	}
+	if _, err := c.Resp.Write(data); err != nil {
+		return err
+	}
+	c.flush()
+	return nil
+}
+
+// Stream 进入流式响应，并且反复调用 step，每一次调用之后都会把数据发送给客户端
+// step 返回 false 或者客户端断开连接的时候结束。
+// 返回值代表客户端是否在中途断开了连接
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	if err := c.startStream(); err != nil {
+		return true
+	}
+	done := c.Req.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.Resp)
+			c.flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+var errSSEInvalidField = errors.New("web: SSE 的 event 和 id 不能包含换行符")
+
+// SSE 进入 Server-Sent Events 响应
+func (c *Context) SSE() *SSE {
+	header := c.Resp.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 避免 nginx 之类的代理缓存响应
+	header.Set("X-Accel-Buffering", "no")
+	return &SSE{ctx: c}
+}
+
+// SSE Server-Sent Events
+// 例如：
+//
+//	sse := ctx.SSE()
+//	for {
+//		select {
+//		case msg := <-msgs:
+//			if err := sse.Send("message", msg.ID, msg); err != nil {
+//				return
+//			}
+//		case <-sse.Done():
+//			return
+//		}
+//	}
+type SSE struct {
+	ctx *Context
+}
+
+// Send 发送一个事件
+// event 和 id 为空的时候不发送对应的字段，它们包含 \r 或者 \n 的时候返回错误，避免伪造事件。
+// data 是 string 或者 []byte 的时候原样发送，其它类型会被序列化为 JSON，
+// 多行数据按照 \r\n、\r 和 \n 拆分成多个 data 字段
+// 客户端断开连接之后返回 context 的错误
+func (s *SSE) Send(event string, id string, data any) error {
+	if err := s.ctx.Req.Context().Err(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(event, "\r\n") || strings.ContainsAny(id, "\r\n") {
+		return errSSEInvalidField
+	}
+	var payload string
+	switch val := data.(type) {
+	case string:
+		payload = val
+	case []byte:
+		payload = string(val)
+	default:
+		bs, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		payload = string(bs)
+	}
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString("id: ")
+		sb.WriteString(id)
+		sb.WriteByte('\n')
+	}
+	if event != "" {
+		sb.WriteString("event: ")
+		sb.WriteString(event)
+		sb.WriteByte('\n')
+	}
+	// 客户端会把这三种都当作换行
+	payload = strings.ReplaceAll(payload, "\r\n", "\n")
+	payload = strings.ReplaceAll(payload, "\r", "\n")
+	for _, line := range strings.Split(payload, "\n") {
+		sb.WriteString("data: ")
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	sb.WriteByte('\n')
+	return s.ctx.WriteChunk([]byte(sb.String()))
+}
+
+// Retry 通知客户端断线重连的等待时间
+func (s *SSE) Retry(d time.Duration) error {
+	return s.ctx.WriteChunk([]byte("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n"))
+}
+
+// Ping 发送一个注释作为心跳，避免连接被代理关闭
+func (s *SSE) Ping() error {
+	if err := s.ctx.Req.Context().Err(); err != nil {
+		return err
+	}
+	return s.ctx.WriteChunk([]byte(": ping\n\n"))
+}
+
+// Done 客户端断开连接的时候会被关闭
+func (s *SSE) Done() <-chan struct{} {
+	return s.ctx.Req.Context().Done()
+}
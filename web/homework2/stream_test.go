@@ -0,0 +1,168 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_Stream(t *testing.T) {
+	var gotStatus, gotSize int
+	s := NewHTTPServer()
+	s.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			gotStatus = ctx.RespStatusCode
+			gotSize = ctx.RespSize()
+		}
+	})
+	s.Get("/stream", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusAccepted
+		ctx.RespData = []byte("start;")
+		i := 0
+		ctx.Stream(func(w io.Writer) bool {
+			_, _ = fmt.Fprintf(w, "chunk-%d;", i)
+			i++
+			return i < 3
+		})
+		// 进入流式响应之后，RespData 不再生效
+		ctx.RespData = []byte("ignored")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.True(t, recorder.Flushed)
+	assert.Equal(t, "start;chunk-0;chunk-1;chunk-2;", recorder.Body.String())
+	assert.Equal(t, http.StatusAccepted, gotStatus)
+	assert.Equal(t, recorder.Body.Len(), gotSize)
+}
+
+func TestContext_Stream_ClientGone(t *testing.T) {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil).WithContext(reqCtx)
+	ctx := &Context{Req: req, Resp: httptest.NewRecorder()}
+	cnt := 0
+	clientGone := ctx.Stream(func(w io.Writer) bool {
+		cnt++
+		if cnt == 2 {
+			cancel()
+		}
+		return true
+	})
+	assert.True(t, clientGone)
+	assert.Equal(t, 2, cnt)
+}
+
+func TestSSE_Send(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/events", func(ctx *Context) {
+		sse := ctx.SSE()
+		require.NoError(t, sse.Retry(time.Second))
+		require.NoError(t, sse.Send("greeting", "1", "hello\nworld"))
+		require.NoError(t, sse.Send("", "", map[string]int{"count": 2}))
+		require.NoError(t, sse.Ping())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", recorder.Header().Get("Cache-Control"))
+	assert.Equal(t, "retry: 1000\n\n"+
+		"id: 1\nevent: greeting\ndata: hello\ndata: world\n\n"+
+		"data: {\"count\":2}\n\n"+
+		": ping\n\n", recorder.Body.String())
+}
+
+func TestSSE_Send_Newline(t *testing.T) {
+	testCases := []struct {
+		name     string
+		event    string
+		id       string
+		data     string
+		wantErr  error
+		wantBody string
+	}{
+		{
+			name:     "crlf data",
+			data:     "a\r\nb\rc\nd",
+			wantBody: "data: a\ndata: b\ndata: c\ndata: d\n\n",
+		},
+		{
+			name:    "newline in event",
+			event:   "message\ndata: fake",
+			data:    "a",
+			wantErr: errSSEInvalidField,
+		},
+		{
+			name:    "carriage return in id",
+			id:      "1\revent: fake",
+			data:    "a",
+			wantErr: errSSEInvalidField,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewHTTPServer()
+			s.Get("/events", func(ctx *Context) {
+				err := ctx.SSE().Send(tc.event, tc.id, tc.data)
+				assert.Equal(t, tc.wantErr, err)
+			})
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/events", nil))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestSSE_ClientDisconnect(t *testing.T) {
+	stopped := make(chan error, 1)
+	s := NewHTTPServer()
+	s.Get("/events", func(ctx *Context) {
+		sse := ctx.SSE()
+		ticker := time.NewTicker(time.Millisecond * 10)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			select {
+			case <-ticker.C:
+				if err := sse.Send("tick", "", fmt.Sprint(i)); err != nil {
+					stopped <- err
+					return
+				}
+			case <-sse.Done():
+				stopped <- ctx.Req.Context().Err()
+				return
+			}
+		}
+	})
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events")
+	require.NoError(t, err)
+	reader := bufio.NewReader(resp.Body)
+	// 读到第一个事件之后断开连接
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, "event: tick"))
+	require.NoError(t, resp.Body.Close())
+
+	select {
+	case err = <-stopped:
+		assert.Error(t, err)
+	case <-time.After(time.Second * 3):
+		t.Fatal("客户端断开连接之后，SSE 没有结束")
+	}
+}
@@ -0,0 +1,177 @@
+package web
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// StringValue 代表从请求中读取到的字符串值，提供了转换成其它类型的方法
+// 查询参数和表单数据允许一个 key 对应多个值，可以使用 ToStrings、ToInt64s 或者 As 读取全部的值，
+// 其余方法只使用第一个值
+type StringValue struct {
+	val string
+	// vals 全部的值，只有查询参数和表单数据会设置
+	vals []string
+	err  error
+}
+
+func (s StringValue) String() (string, error) {
+	return s.val, s.err
+}
+
+// StringOr 出错的时候返回 def
+func (s StringValue) StringOr(def string) string {
+	if s.err != nil {
+		return def
+	}
+	return s.val
+}
+
+func (s StringValue) ToInt64() (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return strconv.ParseInt(s.val, 10, 64)
+}
+
+// ToInt64Or 找不到 key 或者转换失败的时候返回 def
+func (s StringValue) ToInt64Or(def int64) int64 {
+	res, err := s.ToInt64()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (s StringValue) ToUint64() (uint64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return strconv.ParseUint(s.val, 10, 64)
+}
+
+func (s StringValue) ToUint64Or(def uint64) uint64 {
+	res, err := s.ToUint64()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (s StringValue) ToFloat64() (float64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return strconv.ParseFloat(s.val, 64)
+}
+
+func (s StringValue) ToFloat64Or(def float64) float64 {
+	res, err := s.ToFloat64()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+// ToBool 支持 strconv.ParseBool 所支持的格式，例如 1、t、true、0、f、false
+func (s StringValue) ToBool() (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	return strconv.ParseBool(s.val)
+}
+
+func (s StringValue) ToBoolOr(def bool) bool {
+	res, err := s.ToBool()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+// ToDuration 支持 time.ParseDuration 所支持的格式，例如 300ms、1h30m
+func (s StringValue) ToDuration() (time.Duration, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return time.ParseDuration(s.val)
+}
+
+func (s StringValue) ToDurationOr(def time.Duration) time.Duration {
+	res, err := s.ToDuration()
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+// ToTime 使用 layout 解析时间，例如 time.RFC3339 或者 2006-01-02
+func (s StringValue) ToTime(layout string) (time.Time, error) {
+	if s.err != nil {
+		return time.Time{}, s.err
+	}
+	return time.Parse(layout, s.val)
+}
+
+func (s StringValue) ToTimeOr(layout string, def time.Time) time.Time {
+	res, err := s.ToTime(layout)
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+// ToStrings 返回 key 对应的全部的值
+func (s StringValue) ToStrings() ([]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.values(), nil
+}
+
+// ToInt64s 将 key 对应的全部的值转换为 int64
+func (s StringValue) ToInt64s() ([]int64, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	vals := s.values()
+	res := make([]int64, 0, len(vals))
+	for _, v := range vals {
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, i)
+	}
+	return res, nil
+}
+
+func (s StringValue) values() []string {
+	if s.vals != nil {
+		return s.vals
+	}
+	return []string{s.val}
+}
+
+// As 将 StringValue 转换为 T
+// Go 的方法不支持类型参数，所以只能做成包级别的函数。
+// T 可以是任意的基本类型、time.Duration、time.Time（RFC3339 格式）以及它们的指针，
+// 如果 T 是切片，那么会转换 key 对应的全部的值，例如 As[[]int](ctx.QueryValue("id"))
+func As[T any](s StringValue) (T, error) {
+	var res T
+	if s.err != nil {
+		return res, s.err
+	}
+	err := setValues(reflect.ValueOf(&res).Elem(), s.values(), time.RFC3339)
+	return res, err
+}
+
+// AsOr 找不到 key 或者转换失败的时候返回 def
+func AsOr[T any](s StringValue, def T) T {
+	res, err := As[T](s)
+	if err != nil {
+		return def
+	}
+	return res
+}
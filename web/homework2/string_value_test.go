@@ -0,0 +1,148 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringValue(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet,
+		"/?id=123&uid=18446744073709551615&price=9.9&ok=true&timeout=1m30s&date=2022-10-01&ids=1&ids=2&name=Tom", nil)
+	ctx := &Context{Req: req}
+
+	id, err := ctx.QueryValue("id").ToInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(123), id)
+
+	uid, err := ctx.QueryValue("uid").ToUint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(18446744073709551615), uid)
+
+	price, err := ctx.QueryValue("price").ToFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, 9.9, price)
+
+	ok, err := ctx.QueryValue("ok").ToBool()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	timeout, err := ctx.QueryValue("timeout").ToDuration()
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, timeout)
+
+	date, err := ctx.QueryValue("date").ToTime("2006-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC), date)
+
+	strs, err := ctx.QueryValue("ids").ToStrings()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, strs)
+
+	ids, err := ctx.QueryValue("ids").ToInt64s()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	_, err = ctx.QueryValue("name").ToInt64s()
+	assert.Error(t, err)
+
+	// 默认值
+	assert.Equal(t, int64(10), ctx.QueryValue("page").ToInt64Or(10))
+	assert.Equal(t, int64(10), ctx.QueryValue("name").ToInt64Or(10))
+	assert.Equal(t, int64(123), ctx.QueryValue("id").ToInt64Or(10))
+	assert.Equal(t, "def", ctx.QueryValue("page").StringOr("def"))
+	assert.Equal(t, uint64(1), ctx.QueryValue("page").ToUint64Or(1))
+	assert.Equal(t, 1.5, ctx.QueryValue("page").ToFloat64Or(1.5))
+	assert.True(t, ctx.QueryValue("page").ToBoolOr(true))
+	assert.Equal(t, time.Second, ctx.QueryValue("page").ToDurationOr(time.Second))
+	assert.Equal(t, time.Time{}, ctx.QueryValue("page").ToTimeOr(time.RFC3339, time.Time{}))
+}
+
+func TestAs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sv      StringValue
+		as      func(sv StringValue) (any, error)
+		wantVal any
+		wantErr error
+	}{
+		{
+			name: "int",
+			sv:   StringValue{val: "12"},
+			as: func(sv StringValue) (any, error) {
+				return As[int](sv)
+			},
+			wantVal: 12,
+		},
+		{
+			name: "int8 overflow",
+			sv:   StringValue{val: "128"},
+			as: func(sv StringValue) (any, error) {
+				return As[int8](sv)
+			},
+			wantVal: int8(0),
+			wantErr: errors.New(`strconv.ParseInt: parsing "128": value out of range`),
+		},
+		{
+			name: "float32",
+			sv:   StringValue{val: "1.5"},
+			as: func(sv StringValue) (any, error) {
+				return As[float32](sv)
+			},
+			wantVal: float32(1.5),
+		},
+		{
+			name: "pointer",
+			sv:   StringValue{val: "true"},
+			as: func(sv StringValue) (any, error) {
+				res, err := As[*bool](sv)
+				return *res, err
+			},
+			wantVal: true,
+		},
+		{
+			name: "slice",
+			sv:   StringValue{val: "1", vals: []string{"1", "2"}},
+			as: func(sv StringValue) (any, error) {
+				return As[[]uint16](sv)
+			},
+			wantVal: []uint16{1, 2},
+		},
+		{
+			name: "time",
+			sv:   StringValue{val: "2022-10-01T08:00:00Z"},
+			as: func(sv StringValue) (any, error) {
+				return As[time.Time](sv)
+			},
+			wantVal: time.Date(2022, 10, 1, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "error",
+			sv:   StringValue{err: errors.New("web: 找不到这个 key")},
+			as: func(sv StringValue) (any, error) {
+				return As[string](sv)
+			},
+			wantVal: "",
+			wantErr: errors.New("web: 找不到这个 key"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			val, err := tc.as(tc.sv)
+			if tc.wantErr != nil {
+				assert.EqualError(t, err, tc.wantErr.Error())
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+
+	assert.Equal(t, 5, AsOr[int](StringValue{val: "abc"}, 5))
+	assert.Equal(t, 7, AsOr[int](StringValue{val: "7"}, 5))
+}
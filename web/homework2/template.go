@@ -0,0 +1,178 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+type TemplateEngine interface {
+	// Render 渲染页面
+	// tplName 模板的名字，按名索引
+	// data 渲染页面用的数据
+	Render(ctx context.Context, tplName string, data any) ([]byte, error)
+}
+
+// GoTemplateEngine 基于 html/template 的模板引擎
+// 模板分为两类：
+// 1. 页面：每一个页面文件是一个独立的模板，名字是它在 fs.FS 中的路径，例如 user/login.gohtml
+// 2. 公共模板：布局和局部模板，会被解析到每一个页面里面
+// 页面里面可以使用 {{template "header" .}} 引用局部模板，
+// 也可以使用 {{define "content"}}...{{end}} 填充布局中的占位
+type GoTemplateEngine struct {
+	fsys   fs.FS
+	pages  []string
+	shared []string
+	layout string
+	funcs  template.FuncMap
+	// devMode 开发模式，每一次渲染都会重新加载模板
+	devMode bool
+
+	mutex sync.RWMutex
+	tpls  map[string]*template.Template
+}
+
+type GoTemplateEngineOption func(e *GoTemplateEngine)
+
+// NewGoTemplateEngine 从 fsys 中加载模板
+// 加载目录可以使用 os.DirFS(dir)，也可以直接使用 embed.FS
+func NewGoTemplateEngine(fsys fs.FS, opts ...GoTemplateEngineOption) (*GoTemplateEngine, error) {
+	e := &GoTemplateEngine{
+		fsys:  fsys,
+		pages: []string{"*.gohtml"},
+		funcs: template.FuncMap{},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	tpls, err := e.load()
+	if err != nil {
+		return nil, err
+	}
+	e.tpls = tpls
+	return e, nil
+}
+
+// GoTemplateWithPages 页面文件的匹配模式，语法同 fs.Glob，默认是 *.gohtml
+func GoTemplateWithPages(patterns ...string) GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		e.pages = patterns
+	}
+}
+
+// GoTemplateWithShared 布局和局部模板文件的匹配模式，语法同 fs.Glob
+// 匹配上的文件不会被当作页面
+func GoTemplateWithShared(patterns ...string) GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		e.shared = patterns
+	}
+}
+
+// GoTemplateWithLayout 指定布局模板的名字
+// 设置了之后，渲染页面实际上是执行布局模板，页面只负责 define 布局中的占位
+func GoTemplateWithLayout(name string) GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		e.layout = name
+	}
+}
+
+func GoTemplateWithFuncs(funcs template.FuncMap) GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		for name, fn := range funcs {
+			e.funcs[name] = fn
+		}
+	}
+}
+
+// GoTemplateWithDevMode 开启开发模式，每一次渲染都会重新加载模板
+// 修改模板之后不需要重启服务器
+func GoTemplateWithDevMode() GoTemplateEngineOption {
+	return func(e *GoTemplateEngine) {
+		e.devMode = true
+	}
+}
+
+func (e *GoTemplateEngine) Render(ctx context.Context, tplName string, data any) ([]byte, error) {
+	if e.devMode {
+		if err := e.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	e.mutex.RLock()
+	tpl, ok := e.tpls[tplName]
+	e.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("web: 找不到模板 %s", tplName)
+	}
+	name := tplName
+	if e.layout != "" {
+		name = e.layout
+	}
+	buffer := &bytes.Buffer{}
+	err := tpl.ExecuteTemplate(buffer, name, data)
+	return buffer.Bytes(), err
+}
+
+// Reload 重新加载所有的模板
+func (e *GoTemplateEngine) Reload() error {
+	tpls, err := e.load()
+	if err != nil {
+		return err
+	}
+	e.mutex.Lock()
+	e.tpls = tpls
+	e.mutex.Unlock()
+	return nil
+}
+
+func (e *GoTemplateEngine) load() (map[string]*template.Template, error) {
+	base := template.New("").Funcs(e.funcs)
+	shared := make(map[string]struct{}, 8)
+	for _, pattern := range e.shared {
+		matches, err := fs.Glob(e.fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if err = parseFile(base.New(path.Base(m)), e.fsys, m); err != nil {
+				return nil, err
+			}
+			shared[m] = struct{}{}
+		}
+	}
+
+	tpls := make(map[string]*template.Template, 16)
+	for _, pattern := range e.pages {
+		matches, err := fs.Glob(e.fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if _, ok := shared[m]; ok {
+				continue
+			}
+			tpl, err := base.Clone()
+			if err != nil {
+				return nil, err
+			}
+			if err = parseFile(tpl.New(m), e.fsys, m); err != nil {
+				return nil, err
+			}
+			tpls[m] = tpl
+		}
+	}
+	return tpls, nil
+}
+
+func parseFile(tpl *template.Template, fsys fs.FS, name string) error {
+	bs, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	_, err = tpl.Parse(string(bs))
+	return err
+}
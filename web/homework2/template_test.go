@@ -0,0 +1,142 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoTemplateEngine_Render(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout/base.gohtml": {Data: []byte(
+			`<html>{{template "header" .}}{{block "content" .}}default{{end}}</html>`)},
+		"partial/header.gohtml": {Data: []byte(`{{define "header"}}<h1>{{.Title}}</h1>{{end}}`)},
+		"user/login.gohtml":     {Data: []byte(`{{define "content"}}<p>{{upper .Name}}</p>{{end}}`)},
+		"user/empty.gohtml":     {Data: []byte(``)},
+	}
+	engine, err := NewGoTemplateEngine(fsys,
+		GoTemplateWithPages("user/*.gohtml"),
+		GoTemplateWithShared("layout/*.gohtml", "partial/*.gohtml"),
+		GoTemplateWithLayout("base.gohtml"),
+		GoTemplateWithFuncs(map[string]any{"upper": strings.ToUpper}))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		tplName  string
+		data     any
+		wantResp string
+		wantErr  string
+	}{
+		{
+			name:     "page with layout",
+			tplName:  "user/login.gohtml",
+			data:     map[string]string{"Title": "Login", "Name": "tom"},
+			wantResp: "<html><h1>Login</h1><p>TOM</p></html>",
+		},
+		{
+			name:     "default block",
+			tplName:  "user/empty.gohtml",
+			data:     map[string]string{"Title": "Empty"},
+			wantResp: "<html><h1>Empty</h1>default</html>",
+		},
+		{
+			name:    "not found",
+			tplName: "user/abc.gohtml",
+			wantErr: "web: 找不到模板 user/abc.gohtml",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := engine.Render(context.Background(), tc.tplName, tc.data)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantResp, string(resp))
+		})
+	}
+}
+
+func TestGoTemplateEngine_DevMode(t *testing.T) {
+	fsys := fstest.MapFS{
+		"hello.gohtml": {Data: []byte(`hello, {{.}}`)},
+	}
+	engine, err := NewGoTemplateEngine(fsys, GoTemplateWithDevMode())
+	require.NoError(t, err)
+	resp, err := engine.Render(context.Background(), "hello.gohtml", "tom")
+	require.NoError(t, err)
+	assert.Equal(t, "hello, tom", string(resp))
+
+	fsys["hello.gohtml"] = &fstest.MapFile{Data: []byte(`hi, {{.}}`)}
+	resp, err = engine.Render(context.Background(), "hello.gohtml", "tom")
+	require.NoError(t, err)
+	assert.Equal(t, "hi, tom", string(resp))
+}
+
+func TestContext_Render(t *testing.T) {
+	engine, err := NewGoTemplateEngine(fstest.MapFS{
+		"hello.gohtml": {Data: []byte(`<p>hello, {{.}}</p>`)},
+	})
+	require.NoError(t, err)
+	s := NewHTTPServer(ServerWithTemplateEngine(engine))
+	s.Get("/hello", func(ctx *Context) {
+		_ = ctx.Render("hello.gohtml", "<tom>")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "<p>hello, &lt;tom&gt;</p>", recorder.Body.String())
+}
+
+func TestContext_Render_Status(t *testing.T) {
+	engine, err := NewGoTemplateEngine(fstest.MapFS{
+		"404.gohtml":    {Data: []byte(`<p>not found</p>`)},
+		"broken.gohtml": {Data: []byte(`<p>partial</p>{{index . 5}}`)},
+	})
+	require.NoError(t, err)
+	testCases := []struct {
+		name     string
+		tplName  string
+		status   int
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "keep status",
+			tplName:  "404.gohtml",
+			status:   http.StatusNotFound,
+			wantCode: http.StatusNotFound,
+			wantBody: "<p>not found</p>",
+		},
+		{
+			// 渲染了一半的内容不会发送给客户端
+			name:     "render error",
+			tplName:  "broken.gohtml",
+			status:   http.StatusNotFound,
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewHTTPServer(ServerWithTemplateEngine(engine))
+			s.Get("/page", func(ctx *Context) {
+				ctx.RespStatusCode = tc.status
+				_ = ctx.Render(tc.tplName, []int{})
+			})
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/page", nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
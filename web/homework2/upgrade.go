@@ -0,0 +1,30 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/websocket"
+)
+
+// Upgrade 把当前连接升级为 WebSocket
+// Upgrade 在 handler 里面调用，所以路由上的中间件，例如鉴权，都已经执行过了。
+// 握手失败的时候，会把失败原因写入 RespStatusCode 和 RespData，handler 直接返回就可以；
+// 握手成功之后，连接已经被接管，RespStatusCode 和 RespData 都不再生效
+func (c *Context) Upgrade(opts ...websocket.Option) (*websocket.Conn, error) {
+	conn, err := websocket.Upgrade(c.Resp, c.Req, opts...)
+	if err != nil {
+		var he *websocket.HandshakeError
+		if errors.As(err, &he) {
+			c.RespStatusCode = he.Status
+			c.RespData = []byte(he.Message)
+		} else {
+			c.RespStatusCode = http.StatusInternalServerError
+		}
+		return nil, err
+	}
+	c.RespStatusCode = http.StatusSwitchingProtocols
+	// 连接已经被接管，不再回写响应
+	c.streaming = true
+	return conn, nil
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitee.com/geektime-geekbang/geektime-go/web/homework2/websocket"
+)
+
+func TestContext_Upgrade(t *testing.T) {
+	statuses := make(chan int, 2)
+	s := NewHTTPServer()
+	s.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			statuses <- ctx.RespStatusCode
+		}
+	})
+	auth := func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			if ctx.Req.URL.Query().Get("token") != "123" {
+				ctx.RespStatusCode = http.StatusUnauthorized
+				return
+			}
+			next(ctx)
+		}
+	}
+	s.Get("/ws", func(ctx *Context) {
+		conn, err := ctx.Upgrade()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(typ, append([]byte("echo: "), data...)); err != nil {
+				return
+			}
+		}
+	}, auth)
+	server := httptest.NewServer(s)
+	defer server.Close()
+	wsURL := strings.Replace(server.URL, "http", "ws", 1)
+
+	// 鉴权中间件在握手之前执行
+	_, resp, err := websocket.Dial(wsURL+"/ws", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, <-statuses)
+
+	conn, _, err := websocket.Dial(wsURL+"/ws?token=123", nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	typ, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, typ)
+	assert.Equal(t, "echo: hello", string(data))
+
+	require.NoError(t, conn.WriteClose(websocket.CloseNormalClosure, ""))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "%v", err)
+	assert.Equal(t, http.StatusSwitchingProtocols, <-statuses)
+}
+
+func TestContext_Upgrade_HandshakeError(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/ws", func(ctx *Context) {
+		_, _ = ctx.Upgrade()
+	})
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "Connection 头部必须包含 upgrade", recorder.Body.String())
+}
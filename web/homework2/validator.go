@@ -0,0 +1,357 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Validator 校验绑定之后的请求数据
+// Context 上的 Bind 系列方法在绑定成功之后都会调用 Validator
+type Validator interface {
+	Validate(val any) error
+}
+
+// FieldError 代表一个字段没有通过校验
+type FieldError struct {
+	// Field 字段名，嵌套字段使用 . 连接，例如 address.city
+	// 优先使用 json、form、query、path、header 标签里面的名字
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors 校验失败的所有字段
+type ValidationErrors []*FieldError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, fe := range v {
+		msgs = append(msgs, fe.Message)
+	}
+	return "web: 参数校验失败: " + strings.Join(msgs, "; ")
+}
+
+// ValidateRule 校验规则
+// field 是字段的值，param 是规则的参数，例如 min=1 里面的 1
+// 返回 false 代表校验失败
+type ValidateRule func(field reflect.Value, param string) bool
+
+// TagValidator 依据 validate 标签校验结构体
+// 标签的形式是逗号分隔的规则，例如 validate:"required,min=1,max=100"
+// 内置的规则有：
+//   - required：不能是零值
+//   - omitempty：零值的时候跳过其余规则
+//   - min、max：数字比较大小，字符串、切片和 map 比较长度
+//   - len：字符串、切片和 map 的长度
+//   - oneof：枚举，多个值使用空格分隔，例如 oneof=red green
+//   - regexp：正则表达式，注意表达式里面不能有逗号
+//   - email：邮箱格式
+//
+// 嵌套的结构体、结构体指针和结构体切片会被递归校验
+type TagValidator struct {
+	mutex sync.RWMutex
+	rules map[string]ValidateRule
+}
+
+func NewTagValidator() *TagValidator {
+	v := &TagValidator{
+		rules: make(map[string]ValidateRule, 8),
+	}
+	v.rules["required"] = ruleRequired
+	v.rules["min"] = ruleMin
+	v.rules["max"] = ruleMax
+	v.rules["len"] = ruleLen
+	v.rules["oneof"] = ruleOneOf
+	v.rules["regexp"] = ruleRegexp
+	v.rules["email"] = ruleEmail
+	return v
+}
+
+// RegisterRule 注册自定义规则，同名规则会被覆盖
+func (v *TagValidator) RegisterRule(name string, rule ValidateRule) *TagValidator {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.rules[name] = rule
+	return v
+}
+
+func (v *TagValidator) Validate(val any) error {
+	rv := reflect.ValueOf(val)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := v.validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateStruct 返回的 error 代表标签本身有问题，例如规则不存在
+// 校验失败的字段会被放到 errs 里面
+func (v *TagValidator) validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) error {
+	typ := rv.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		fd := typ.Field(i)
+		if !fd.IsExported() && !fd.Anonymous {
+			continue
+		}
+		fv := rv.Field(i)
+		name := prefix + fieldName(fd)
+		if fd.Anonymous {
+			// 嵌入的结构体，字段名不增加前缀
+			name = strings.TrimSuffix(prefix, ".")
+		}
+		if tag, ok := fd.Tag.Lookup("validate"); ok && tag != "-" {
+			if err := v.validateField(fv, name, tag, errs); err != nil {
+				return err
+			}
+		}
+		if err := v.validateNested(fv, name, fd.Anonymous, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *TagValidator) validateField(fv reflect.Value, name string, tag string, errs *ValidationErrors) error {
+	rules := strings.Split(tag, ",")
+	for _, r := range rules {
+		if r == "omitempty" {
+			if fv.IsZero() {
+				return nil
+			}
+			break
+		}
+	}
+	for _, r := range rules {
+		if r == "" || r == "omitempty" {
+			continue
+		}
+		ruleName, param, _ := strings.Cut(r, "=")
+		v.mutex.RLock()
+		rule, ok := v.rules[ruleName]
+		v.mutex.RUnlock()
+		if !ok {
+			return fmt.Errorf("web: 未知的校验规则 %s", ruleName)
+		}
+		if !rule(fv, param) {
+			*errs = append(*errs, &FieldError{
+				Field:   name,
+				Rule:    ruleName,
+				Param:   param,
+				Message: fieldErrMsg(name, ruleName, param),
+			})
+		}
+	}
+	return nil
+}
+
+// validateNested 递归校验结构体、结构体指针和结构体切片
+func (v *TagValidator) validateNested(fv reflect.Value, name string, embedded bool, errs *ValidationErrors) error {
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.Type() == timeType {
+			return nil
+		}
+		prefix := name + "."
+		if embedded && name == "" {
+			prefix = ""
+		}
+		return v.validateStruct(fv, prefix, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if err := v.validateNested(fv.Index(i), fmt.Sprintf("%s[%d]", name, i), false, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldName 返回字段在请求中的名字
+func fieldName(fd reflect.StructField) string {
+	for _, tag := range []string{"json", tagForm, tagQuery, tagPath, tagHeader} {
+		name, _, _ := strings.Cut(fd.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return fd.Name
+}
+
+func fieldErrMsg(name, rule, param string) string {
+	switch rule {
+	case "required":
+		return fmt.Sprintf("%s 是必填字段", name)
+	case "min":
+		return fmt.Sprintf("%s 不能小于 %s", name, param)
+	case "max":
+		return fmt.Sprintf("%s 不能大于 %s", name, param)
+	case "len":
+		return fmt.Sprintf("%s 的长度必须是 %s", name, param)
+	case "oneof":
+		return fmt.Sprintf("%s 必须是 [%s] 之一", name, param)
+	case "regexp":
+		return fmt.Sprintf("%s 的格式不正确", name)
+	case "email":
+		return fmt.Sprintf("%s 不是合法的邮箱", name)
+	}
+	if param == "" {
+		return fmt.Sprintf("%s 不满足规则 %s", name, rule)
+	}
+	return fmt.Sprintf("%s 不满足规则 %s=%s", name, rule, param)
+}
+
+func ruleRequired(field reflect.Value, _ string) bool {
+	return !field.IsZero()
+}
+
+func ruleMin(field reflect.Value, param string) bool {
+	res, ok := compare(field, param)
+	return ok && res >= 0
+}
+
+func ruleMax(field reflect.Value, param string) bool {
+	res, ok := compare(field, param)
+	return ok && res <= 0
+}
+
+func ruleLen(field reflect.Value, param string) bool {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return false
+	}
+	l, ok := lengthOf(field)
+	return ok && l == n
+}
+
+func ruleOneOf(field reflect.Value, param string) bool {
+	field = reflect.Indirect(field)
+	if !field.IsValid() {
+		return false
+	}
+	val := fmt.Sprint(field.Interface())
+	for _, opt := range strings.Fields(param) {
+		if opt == val {
+			return true
+		}
+	}
+	return false
+}
+
+var regexpCache sync.Map
+
+func ruleRegexp(field reflect.Value, param string) bool {
+	field = reflect.Indirect(field)
+	if field.Kind() != reflect.String {
+		return false
+	}
+	reg, ok := regexpCache.Load(param)
+	if !ok {
+		compiled, err := regexp.Compile(param)
+		if err != nil {
+			return false
+		}
+		reg, _ = regexpCache.LoadOrStore(param, compiled)
+	}
+	return reg.(*regexp.Regexp).MatchString(field.String())
+}
+
+var emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+
+func ruleEmail(field reflect.Value, _ string) bool {
+	field = reflect.Indirect(field)
+	return field.Kind() == reflect.String && emailRegexp.MatchString(field.String())
+}
+
+// compare 比较 field 和 param
+// 数字比较值，time.Duration 支持 1s 之类的写法，字符串、切片和 map 比较长度
+// 返回 -1、0、1，第二个返回值代表能否比较
+func compare(field reflect.Value, param string) (int, bool) {
+	field = reflect.Indirect(field)
+	if !field.IsValid() {
+		return 0, false
+	}
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(param)
+		if err != nil {
+			return 0, false
+		}
+		return cmp(float64(field.Int()), float64(d)), true
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		p, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return cmp(float64(field.Int()), float64(p)), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		p, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return cmp(float64(field.Uint()), float64(p)), true
+	case reflect.Float32, reflect.Float64:
+		p, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return 0, false
+		}
+		return cmp(field.Float(), p), true
+	}
+	l, ok := lengthOf(field)
+	if !ok {
+		return 0, false
+	}
+	p, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, false
+	}
+	return cmp(float64(l), float64(p)), true
+}
+
+func lengthOf(field reflect.Value) (int, bool) {
+	field = reflect.Indirect(field)
+	switch field.Kind() {
+	case reflect.String:
+		return len([]rune(field.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return field.Len(), true
+	}
+	return 0, false
+}
+
+func cmp(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// defaultValidator 没有通过 ServerWithValidator 指定 Validator 的时候使用
+var defaultValidator Validator = NewTagValidator()
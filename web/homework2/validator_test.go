@@ -0,0 +1,179 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validateAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validateUser struct {
+	Name     string            `json:"name" validate:"required,min=2,max=8"`
+	Age      int               `json:"age" validate:"min=1,max=150"`
+	Email    string            `json:"email" validate:"omitempty,email"`
+	Gender   string            `json:"gender" validate:"oneof=male female"`
+	Phone    string            `json:"phone" validate:"omitempty,regexp=^[0-9]{11}$"`
+	Tags     []string          `json:"tags" validate:"max=2"`
+	Address  *validateAddress  `json:"address"`
+	Backups  []validateAddress `json:"backups"`
+	Nickname string            `json:"nickname" validate:"len=3"`
+}
+
+func TestTagValidator_Validate(t *testing.T) {
+	valid := func() *validateUser {
+		return &validateUser{
+			Name:     "Tom",
+			Age:      18,
+			Gender:   "male",
+			Nickname: "tom",
+		}
+	}
+	testCases := []struct {
+		name     string
+		val      func() *validateUser
+		wantErrs ValidationErrors
+	}{
+		{
+			name: "valid",
+			val:  valid,
+		},
+		{
+			name: "required and min",
+			val: func() *validateUser {
+				u := valid()
+				u.Name = ""
+				u.Age = 0
+				return u
+			},
+			wantErrs: ValidationErrors{
+				{Field: "name", Rule: "required", Message: "name 是必填字段"},
+				{Field: "name", Rule: "min", Param: "2", Message: "name 不能小于 2"},
+				{Field: "age", Rule: "min", Param: "1", Message: "age 不能小于 1"},
+			},
+		},
+		{
+			name: "format",
+			val: func() *validateUser {
+				u := valid()
+				u.Email = "abc"
+				u.Phone = "123"
+				u.Gender = "unknown"
+				u.Tags = []string{"a", "b", "c"}
+				u.Nickname = "中文名字"
+				return u
+			},
+			wantErrs: ValidationErrors{
+				{Field: "email", Rule: "email", Message: "email 不是合法的邮箱"},
+				{Field: "gender", Rule: "oneof", Param: "male female", Message: "gender 必须是 [male female] 之一"},
+				{Field: "phone", Rule: "regexp", Param: "^[0-9]{11}$", Message: "phone 的格式不正确"},
+				{Field: "tags", Rule: "max", Param: "2", Message: "tags 不能大于 2"},
+				{Field: "nickname", Rule: "len", Param: "3", Message: "nickname 的长度必须是 3"},
+			},
+		},
+		{
+			name: "nested",
+			val: func() *validateUser {
+				u := valid()
+				u.Address = &validateAddress{}
+				u.Backups = []validateAddress{{City: "a"}, {}}
+				return u
+			},
+			wantErrs: ValidationErrors{
+				{Field: "address.city", Rule: "required", Message: "address.city 是必填字段"},
+				{Field: "backups[1].city", Rule: "required", Message: "backups[1].city 是必填字段"},
+			},
+		},
+	}
+	v := NewTagValidator()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := v.Validate(tc.val())
+			if tc.wantErrs == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tc.wantErrs, err)
+		})
+	}
+}
+
+func TestTagValidator_RegisterRule(t *testing.T) {
+	v := NewTagValidator().RegisterRule("even", func(field reflect.Value, _ string) bool {
+		return field.Int()%2 == 0
+	})
+	val := &struct {
+		Count int `json:"count" validate:"even"`
+	}{Count: 3}
+	err := v.Validate(val)
+	assert.Equal(t, ValidationErrors{
+		{Field: "count", Rule: "even", Message: "count 不满足规则 even"},
+	}, err)
+
+	err = v.Validate(&struct {
+		Count int `validate:"unknown"`
+	}{})
+	assert.EqualError(t, err, "web: 未知的校验规则 unknown")
+}
+
+func TestContext_BindJSON_Validate(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/user",
+		strings.NewReader(`{"name":"T","age":18,"gender":"male","nickname":"tom"}`))
+	ctx := &Context{Req: req}
+	err := ctx.BindJSON(&validateUser{})
+	require.Error(t, err)
+	assert.Equal(t, ValidationErrors{
+		{Field: "name", Rule: "min", Param: "2", Message: "name 不能小于 2"},
+	}, ctx.ValidationErrors())
+}
+
+// TestContext_Validate 分别绑定多个来源的时候，只有全部绑定完成之后才校验
+func TestContext_Validate(t *testing.T) {
+	type orderReq struct {
+		ID   int64  `path:"id" validate:"min=1"`
+		Page string `query:"page" validate:"required"`
+	}
+	testCases := []struct {
+		name     string
+		url      string
+		wantErrs ValidationErrors
+	}{
+		{
+			name: "valid",
+			url:  "/order/12?page=2",
+		},
+		{
+			name: "missing query",
+			url:  "/order/12",
+			wantErrs: ValidationErrors{
+				{Field: "page", Rule: "required", Message: "page 是必填字段"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := &Context{
+				Req:        httptest.NewRequest(http.MethodGet, tc.url, nil),
+				PathParams: map[string]string{"id": "12"},
+			}
+			val := &orderReq{}
+			// 单独绑定的时候不会校验，此时 Page 还没有绑定
+			require.NoError(t, ctx.BindPath(val))
+			require.NoError(t, ctx.BindQuery(val))
+			err := ctx.Validate(val)
+			assert.Equal(t, tc.wantErrs, ctx.ValidationErrors())
+			if tc.wantErrs == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tc.wantErrs, err)
+		})
+	}
+}
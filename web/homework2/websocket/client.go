@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// Dial 作为客户端连接 WebSocket 服务端，rawURL 的 scheme 可以是 ws、wss、http 或者 https
+// 握手失败的时候，如果服务端返回了响应，那么响应会和 error 一起返回
+func Dial(rawURL string, header http.Header) (*Conn, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	useTLS := false
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme = "https"
+		useTLS = true
+	default:
+		return nil, nil, errors.New("websocket: 不支持的 scheme " + u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		if useTLS {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	var netConn net.Conn
+	if useTLS {
+		netConn, err = tls.Dial("tcp", addr, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		netConn, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var nonce [16]byte
+	if _, err = rand.Read(nonce[:]); err != nil {
+		_ = netConn.Close()
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{},
+	}
+	for k, vals := range header {
+		req.Header[k] = vals
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if err = req.Write(netConn); err != nil {
+		_ = netConn.Close()
+		return nil, nil, err
+	}
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		_ = netConn.Close()
+		return nil, resp, &HandshakeError{Status: resp.StatusCode, Message: "服务端拒绝了握手"}
+	}
+	conn := newConn(netConn, br, false)
+	conn.subprotocol = resp.Header.Get("Sec-WebSocket-Protocol")
+	return conn, resp, nil
+}
@@ -0,0 +1,438 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型，也就是 RFC 6455 中的 opcode
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// 关闭码，参考 RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+// maxControlPayload 控制帧的负载不能超过 125 字节
+const maxControlPayload = 125
+
+var (
+	ErrCloseSent       = errors.New("websocket: 已经发送了关闭帧")
+	ErrInvalidMsgType  = errors.New("websocket: 不支持的消息类型")
+	ErrControlTooLarge = errors.New("websocket: 控制帧的负载超过 125 字节")
+)
+
+// CloseError 连接已经关闭，Code 是关闭码
+// 对端发送了关闭帧，或者对端违反了协议，都会返回 CloseError
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return "websocket: 连接关闭 " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// IsCloseError err 是否是 CloseError，并且关闭码是 codes 之一
+func IsCloseError(err error, codes ...int) bool {
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn WebSocket 连接
+// 同一时刻只能有一个 goroutine 读，写是并发安全的
+type Conn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	isServer bool
+
+	subprotocol string
+	readLimit   int64
+	// readErr 读出错之后，后续的读都直接返回这个错误
+	readErr error
+
+	pingHandler func(appData string) error
+	pongHandler func(appData string) error
+
+	writeMutex sync.Mutex
+	closeSent  bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isServer bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	c := &Conn{
+		conn:     conn,
+		br:       br,
+		isServer: isServer,
+	}
+	c.pingHandler = func(appData string) error {
+		err := c.WriteControl(PongMessage, []byte(appData))
+		if errors.Is(err, ErrCloseSent) {
+			return nil
+		}
+		return err
+	}
+	c.pongHandler = func(string) error { return nil }
+	return c
+}
+
+// Subprotocol 握手时协商的子协议
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit 单个消息的最大字节数，小于等于 0 代表不限制
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetPingHandler 收到 ping 的时候调用，默认回复一个 pong
+func (c *Conn) SetPingHandler(h func(appData string) error) {
+	c.pingHandler = h
+}
+
+// SetPongHandler 收到 pong 的时候调用，默认什么也不做
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	c.pongHandler = h
+}
+
+// ReadMessage 读取一个完整的消息，分片的消息会被拼接起来
+// 读取过程中收到的 ping、pong 会交给对应的 handler 处理。
+// 收到关闭帧的时候会回复关闭帧，并且返回 *CloseError
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	messageType, data, err = c.readMessage()
+	if err != nil {
+		c.readErr = err
+	}
+	return
+}
+
+func (c *Conn) readMessage() (int, []byte, error) {
+	var msgType int
+	var buf []byte
+	for {
+		fin, opcode, payload, err := c.readFrame(int64(len(buf)))
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case PingMessage:
+			if err = c.pingHandler(string(payload)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if err = c.pongHandler(string(payload)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case continuationFrame:
+			if msgType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "意外的 continuation 帧")
+			}
+		default:
+			if msgType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "上一个分片消息还没有结束")
+			}
+			msgType = opcode
+		}
+		buf = append(buf, payload...)
+		if fin {
+			if msgType == TextMessage && !utf8.Valid(buf) {
+				return 0, nil, c.fail(CloseInvalidFramePayloadData, "文本消息不是合法的 UTF-8")
+			}
+			if buf == nil {
+				buf = []byte{}
+			}
+			return msgType, buf, nil
+		}
+	}
+}
+
+// readFrame 读取一个帧，read 是当前消息已经读取的字节数
+func (c *Conn) readFrame(read int64) (fin bool, opcode int, payload []byte, err error) {
+	var head [8]byte
+	if _, err = io.ReadFull(c.br, head[:2]); err != nil {
+		return false, 0, nil, c.abnormal(err)
+	}
+	fin = head[0]&0x80 != 0
+	opcode = int(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7f)
+
+	// 没有协商扩展，RSV 必须是 0
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "RSV 不为 0")
+	}
+	switch opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if !fin || length > maxControlPayload {
+			return false, 0, nil, c.fail(CloseProtocolError, "控制帧不能分片，负载不能超过 125 字节")
+		}
+	default:
+		return false, 0, nil, c.fail(CloseProtocolError, "未知的 opcode "+strconv.Itoa(opcode))
+	}
+	// 客户端发送的帧必须有掩码，服务端发送的帧必须没有
+	if masked != c.isServer {
+		return false, 0, nil, c.fail(CloseProtocolError, "掩码不正确")
+	}
+
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(c.br, head[:2]); err != nil {
+			return false, 0, nil, c.abnormal(err)
+		}
+		length = int64(binary.BigEndian.Uint16(head[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.br, head[:8]); err != nil {
+			return false, 0, nil, c.abnormal(err)
+		}
+		l := binary.BigEndian.Uint64(head[:8])
+		if l>>63 != 0 {
+			return false, 0, nil, c.fail(CloseProtocolError, "负载长度不正确")
+		}
+		length = int64(l)
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, maskKey[:]); err != nil {
+			return false, 0, nil, c.abnormal(err)
+		}
+	}
+	// read 不会超过 readLimit，这样写不会溢出
+	if c.readLimit > 0 && opcode < CloseMessage && length > c.readLimit-read {
+		return false, 0, nil, c.fail(CloseMessageTooBig, "消息太大")
+	}
+	if payload, err = c.readPayload(length); err != nil {
+		return false, 0, nil, c.abnormal(err)
+	}
+	if masked {
+		maskBytes(maskKey, payload)
+	}
+	return fin, opcode, payload, nil
+}
+
+// readChunkSize 分段读取负载的大小
+const readChunkSize = 64 << 10
+
+// readPayload 读取 length 字节的负载
+// length 是对端声明的长度，不能直接按照它分配内存，所以分段读取，内存只会随着实际收到的数据增长
+func (c *Conn) readPayload(length int64) ([]byte, error) {
+	if length <= readChunkSize {
+		payload := make([]byte, length)
+		_, err := io.ReadFull(c.br, payload)
+		return payload, err
+	}
+	payload := make([]byte, 0, readChunkSize)
+	for remaining := length; remaining > 0; {
+		n := remaining
+		if n > readChunkSize {
+			n = readChunkSize
+		}
+		start := len(payload)
+		payload = append(payload, make([]byte, n)...)
+		if _, err := io.ReadFull(c.br, payload[start:]); err != nil {
+			return nil, err
+		}
+		remaining -= n
+	}
+	return payload, nil
+}
+
+// handleClose 处理对端发送的关闭帧：回复关闭帧，然后关闭底层连接
+func (c *Conn) handleClose(payload []byte) error {
+	code := CloseNoStatusReceived
+	text := ""
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "关闭帧的负载不正确")
+	case len(payload) >= 2:
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validCloseCode(code) {
+			return c.fail(CloseProtocolError, "非法的关闭码")
+		}
+		if !utf8.ValidString(text) {
+			return c.fail(CloseInvalidFramePayloadData, "关闭原因不是合法的 UTF-8")
+		}
+	}
+	var reply []byte
+	if code != CloseNoStatusReceived {
+		reply = FormatCloseMessage(code, "")
+	}
+	_ = c.WriteControl(CloseMessage, reply)
+	_ = c.conn.Close()
+	return &CloseError{Code: code, Text: text}
+}
+
+// fail 对端违反了协议，发送关闭帧之后关闭连接
+func (c *Conn) fail(code int, text string) error {
+	_ = c.WriteControl(CloseMessage, FormatCloseMessage(code, text))
+	_ = c.conn.Close()
+	return &CloseError{Code: code, Text: text}
+}
+
+// abnormal 连接在没有收到关闭帧的情况下断开
+func (c *Conn) abnormal(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &CloseError{Code: CloseAbnormalClosure, Text: err.Error()}
+	}
+	return err
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// FormatCloseMessage 构造关闭帧的负载
+func FormatCloseMessage(code int, text string) []byte {
+	buf := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], text)
+	return buf
+}
+
+// WriteMessage 发送一个文本或者二进制消息
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return ErrInvalidMsgType
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// WriteControl 发送控制帧：ping、pong 或者关闭帧
+// 发送关闭帧之后，不能再发送任何数据
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+	if messageType != CloseMessage && messageType != PingMessage && messageType != PongMessage {
+		return ErrInvalidMsgType
+	}
+	if len(data) > maxControlPayload {
+		return ErrControlTooLarge
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// Ping 发送 ping，对端的 pong 会交给 pong handler 处理
+func (c *Conn) Ping(data []byte) error {
+	return c.WriteControl(PingMessage, data)
+}
+
+// WriteClose 发送关闭帧，但是不关闭底层连接
+// 调用者应该继续 ReadMessage 直到收到对端回复的关闭帧
+func (c *Conn) WriteClose(code int, text string) error {
+	return c.WriteControl(CloseMessage, FormatCloseMessage(code, text))
+}
+
+// Close 发送 CloseNormalClosure 之后关闭底层连接
+func (c *Conn) Close() error {
+	_ = c.WriteClose(CloseNormalClosure, "")
+	return c.conn.Close()
+}
+
+func (c *Conn) writeFrame(opcode int, data []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, len(data)+14)
+	frame = append(frame, 0x80|byte(opcode))
+	var maskBit byte
+	if !c.isServer {
+		maskBit = 0x80
+	}
+	switch l := len(data); {
+	case l <= 125:
+		frame = append(frame, maskBit|byte(l))
+	case l <= 0xffff:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(l))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(l))
+	}
+	if c.isServer {
+		frame = append(frame, data...)
+	} else {
+		var maskKey [4]byte
+		if _, err := rand.Read(maskKey[:]); err != nil {
+			return err
+		}
+		frame = append(frame, maskKey[:]...)
+		start := len(frame)
+		frame = append(frame, data...)
+		maskBytes(maskKey, frame[start:])
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskBytes(key [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= key[i%4]
+	}
+}
@@ -0,0 +1,337 @@
+package websocket
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dial 测试用的客户端，完成握手之后返回客户端一侧的 Conn
+func dial(t *testing.T, server *httptest.Server, header http.Header) (*Conn, *http.Response) {
+	netConn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, vals := range header {
+		req.Header[k] = vals
+	}
+	require.NoError(t, req.Write(netConn))
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	require.NoError(t, err)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		_ = netConn.Close()
+		return nil, resp
+	}
+	return newConn(netConn, br, false), resp
+}
+
+func echoServer(t *testing.T, opts ...Option) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, opts...)
+		if err != nil {
+			w.WriteHeader(err.(*HandshakeError).Status)
+			return
+		}
+		defer conn.Close()
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(typ, data); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestUpgrade_Handshake(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []Option
+		header   http.Header
+		wantCode int
+		wantHdr  map[string]string
+	}{
+		{
+			name:     "success",
+			opts:     []Option{WithSubprotocols("chat", "superchat")},
+			header:   http.Header{"Sec-Websocket-Protocol": {"superchat, chat"}},
+			wantCode: http.StatusSwitchingProtocols,
+			wantHdr: map[string]string{
+				// RFC 6455 里面的例子
+				"Sec-WebSocket-Accept":   "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=",
+				"Sec-WebSocket-Protocol": "chat",
+			},
+		},
+		{
+			name:     "bad version",
+			header:   http.Header{"Sec-Websocket-Version": {"8"}},
+			wantCode: http.StatusUpgradeRequired,
+			wantHdr:  map[string]string{"Sec-WebSocket-Version": "13"},
+		},
+		{
+			name:     "bad key",
+			header:   http.Header{"Sec-Websocket-Key": {"abc"}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "not upgrade",
+			header:   http.Header{"Upgrade": {"h2c"}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "cross origin",
+			header:   http.Header{"Origin": {"http://evil.com"}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name: "custom origin check",
+			opts: []Option{WithCheckOrigin(func(r *http.Request) bool {
+				return true
+			})},
+			header:   http.Header{"Origin": {"http://evil.com"}},
+			wantCode: http.StatusSwitchingProtocols,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := echoServer(t, tc.opts...)
+			defer server.Close()
+			conn, resp := dial(t, server, tc.header)
+			assert.Equal(t, tc.wantCode, resp.StatusCode)
+			for k, v := range tc.wantHdr {
+				assert.Equal(t, v, resp.Header.Get(k))
+			}
+			if conn != nil {
+				_ = conn.Close()
+			}
+		})
+	}
+}
+
+func TestConn_Echo(t *testing.T) {
+	server := echoServer(t, WithSubprotocols("chat"))
+	defer server.Close()
+	conn, _, err := Dial(strings.Replace(server.URL, "http", "ws", 1)+"/ws",
+		http.Header{"Sec-Websocket-Protocol": {"chat"}})
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "chat", conn.Subprotocol())
+
+	testCases := []struct {
+		name string
+		typ  int
+		data []byte
+	}{
+		{name: "text", typ: TextMessage, data: []byte("hello, 世界")},
+		{name: "empty", typ: TextMessage, data: []byte{}},
+		{name: "binary", typ: BinaryMessage, data: []byte{0, 1, 2, 255}},
+		{name: "16 bit length", typ: BinaryMessage, data: []byte(strings.Repeat("a", 1000))},
+		{name: "64 bit length", typ: BinaryMessage, data: []byte(strings.Repeat("b", 70000))},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, conn.WriteMessage(tc.typ, tc.data))
+			typ, data, err := conn.ReadMessage()
+			require.NoError(t, err)
+			assert.Equal(t, tc.typ, typ)
+			assert.Equal(t, tc.data, data)
+		})
+	}
+}
+
+func TestConn_Fragmented(t *testing.T) {
+	server := echoServer(t)
+	defer server.Close()
+	conn, _ := dial(t, server, nil)
+	require.NotNil(t, conn)
+	defer conn.Close()
+
+	// 分片中间插入一个 ping，服务端应该先回复 pong
+	pong := make(chan string, 1)
+	conn.SetPongHandler(func(appData string) error {
+		pong <- appData
+		return nil
+	})
+	writeRawFrame(t, conn, false, TextMessage, []byte("hello, "))
+	writeRawFrame(t, conn, true, PingMessage, []byte("ping"))
+	writeRawFrame(t, conn, true, continuationFrame, []byte("world"))
+
+	typ, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, TextMessage, typ)
+	assert.Equal(t, "hello, world", string(data))
+	assert.Equal(t, "ping", <-pong)
+}
+
+func TestConn_Close(t *testing.T) {
+	testCases := []struct {
+		name     string
+		frame    func(t *testing.T, conn *Conn)
+		wantCode int
+	}{
+		{
+			name: "normal close",
+			frame: func(t *testing.T, conn *Conn) {
+				require.NoError(t, conn.WriteClose(CloseGoingAway, "bye"))
+			},
+			wantCode: CloseGoingAway,
+		},
+		{
+			name: "invalid close code",
+			frame: func(t *testing.T, conn *Conn) {
+				require.NoError(t, conn.WriteClose(1004, ""))
+			},
+			wantCode: CloseProtocolError,
+		},
+		{
+			name: "invalid utf8",
+			frame: func(t *testing.T, conn *Conn) {
+				require.NoError(t, conn.WriteMessage(TextMessage, []byte{0xff, 0xfe}))
+			},
+			wantCode: CloseInvalidFramePayloadData,
+		},
+		{
+			name: "unexpected continuation",
+			frame: func(t *testing.T, conn *Conn) {
+				writeRawFrame(t, conn, true, continuationFrame, []byte("abc"))
+			},
+			wantCode: CloseProtocolError,
+		},
+		{
+			name: "unknown opcode",
+			frame: func(t *testing.T, conn *Conn) {
+				writeRawFrame(t, conn, true, 3, nil)
+			},
+			wantCode: CloseProtocolError,
+		},
+		{
+			name: "too big",
+			frame: func(t *testing.T, conn *Conn) {
+				require.NoError(t, conn.WriteMessage(BinaryMessage, make([]byte, 11)))
+			},
+			wantCode: CloseMessageTooBig,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := echoServer(t, WithReadLimit(10))
+			defer server.Close()
+			conn, _ := dial(t, server, nil)
+			require.NotNil(t, conn)
+			defer conn.Close()
+
+			tc.frame(t, conn)
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second*3)))
+			_, _, err := conn.ReadMessage()
+			assert.True(t, IsCloseError(err, tc.wantCode), "%v", err)
+		})
+	}
+}
+
+func TestConn_UnmaskedClientFrame(t *testing.T) {
+	server := echoServer(t)
+	defer server.Close()
+	conn, _ := dial(t, server, nil)
+	require.NotNil(t, conn)
+	defer conn.Close()
+
+	// 伪装成服务端，发送没有掩码的帧
+	conn.isServer = true
+	require.NoError(t, conn.WriteMessage(TextMessage, []byte("abc")))
+	conn.isServer = false
+	_, _, err := conn.ReadMessage()
+	assert.True(t, IsCloseError(err, CloseProtocolError), "%v", err)
+}
+
+// writeRawFrame 发送带掩码的帧，可以控制 FIN 位
+func writeRawFrame(t *testing.T, conn *Conn, fin bool, opcode int, data []byte) {
+	head := byte(opcode)
+	if fin {
+		head |= 0x80
+	}
+	frame := []byte{head, 0x80 | byte(len(data)), 1, 2, 3, 4}
+	start := len(frame)
+	frame = append(frame, data...)
+	maskBytes([4]byte{1, 2, 3, 4}, frame[start:])
+	_, err := conn.conn.Write(frame)
+	require.NoError(t, err)
+}
+
+// TestConn_DeclaredLength 不能按照对端声明的长度分配内存
+func TestConn_DeclaredLength(t *testing.T) {
+	testCases := []struct {
+		name  string
+		opts  []Option
+		frame func(t *testing.T, conn *Conn)
+	}{
+		{
+			name: "default limit",
+			frame: func(t *testing.T, conn *Conn) {
+				writeHugeHeader(t, conn, BinaryMessage)
+			},
+		},
+		{
+			name: "continuation overflow",
+			opts: []Option{WithReadLimit(1024)},
+			frame: func(t *testing.T, conn *Conn) {
+				writeRawFrame(t, conn, false, BinaryMessage, make([]byte, 10))
+				writeHugeHeader(t, conn, continuationFrame)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := echoServer(t, tc.opts...)
+			defer server.Close()
+			conn, _ := dial(t, server, nil)
+			require.NotNil(t, conn)
+			defer conn.Close()
+
+			tc.frame(t, conn)
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second*3)))
+			_, _, err := conn.ReadMessage()
+			assert.True(t, IsCloseError(err, CloseMessageTooBig), "%v", err)
+		})
+	}
+}
+
+// TestConn_LargeMessage 超过 readChunkSize 的消息会被分段读取
+func TestConn_LargeMessage(t *testing.T) {
+	server := echoServer(t, WithReadLimit(0))
+	defer server.Close()
+	conn, _ := dial(t, server, nil)
+	require.NotNil(t, conn)
+	defer conn.Close()
+
+	data := make([]byte, readChunkSize*3+7)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	require.NoError(t, conn.WriteMessage(BinaryMessage, data))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second*3)))
+	typ, got, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, BinaryMessage, typ)
+	assert.Equal(t, data, got)
+}
+
+// writeHugeHeader 发送只有头部的帧，声明的负载长度是 1<<62
+func writeHugeHeader(t *testing.T, conn *Conn, opcode int) {
+	frame := []byte{0x80 | byte(opcode), 0x80 | 127, 0x40, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}
+	_, err := conn.conn.Write(frame)
+	require.NoError(t, err)
+}
@@ -0,0 +1,171 @@
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// acceptGUID RFC 6455 里面规定的，用于计算 Sec-WebSocket-Accept
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// HandshakeError 握手失败
+// Upgrade 不会回写响应，调用者应该使用 Status 作为响应码
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: 握手失败: " + e.Message
+}
+
+// DefaultReadLimit Upgrade 默认的单个消息的最大字节数
+const DefaultReadLimit = 32 << 20
+
+type upgrader struct {
+	checkOrigin  func(r *http.Request) bool
+	subprotocols []string
+	readLimit    int64
+}
+
+type Option func(u *upgrader)
+
+// WithCheckOrigin 校验 Origin 头部，返回 false 会拒绝握手
+// 默认只允许没有 Origin 或者 Origin 和 Host 相同的请求
+func WithCheckOrigin(fn func(r *http.Request) bool) Option {
+	return func(u *upgrader) {
+		u.checkOrigin = fn
+	}
+}
+
+// WithSubprotocols 服务端支持的子协议，按照优先级从高到低排列
+func WithSubprotocols(protocols ...string) Option {
+	return func(u *upgrader) {
+		u.subprotocols = protocols
+	}
+}
+
+// WithReadLimit 单个消息的最大字节数，超过之后会以 CloseMessageTooBig 关闭连接
+// 默认是 DefaultReadLimit，小于等于 0 代表不限制，只应该用于可信的对端
+func WithReadLimit(limit int64) Option {
+	return func(u *upgrader) {
+		u.readLimit = limit
+	}
+}
+
+// Upgrade 完成 WebSocket 握手，并且接管底层的 TCP 连接
+// 握手失败的时候返回 *HandshakeError，此时 w 还没有被写入，由调用者决定如何响应
+func Upgrade(w http.ResponseWriter, r *http.Request, opts ...Option) (*Conn, error) {
+	u := &upgrader{
+		checkOrigin: sameOrigin,
+		readLimit:   DefaultReadLimit,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	if r.Method != http.MethodGet {
+		return nil, &HandshakeError{Status: http.StatusMethodNotAllowed, Message: "请求方法必须是 GET"}
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") {
+		return nil, &HandshakeError{Status: http.StatusBadRequest, Message: "Connection 头部必须包含 upgrade"}
+	}
+	if !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, &HandshakeError{Status: http.StatusBadRequest, Message: "Upgrade 头部必须是 websocket"}
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, &HandshakeError{Status: http.StatusUpgradeRequired, Message: "只支持版本 13"}
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, &HandshakeError{Status: http.StatusBadRequest, Message: "Sec-WebSocket-Key 不合法"}
+	}
+	if !u.checkOrigin(r) {
+		return nil, &HandshakeError{Status: http.StatusForbidden, Message: "Origin 不允许"}
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, &HandshakeError{Status: http.StatusInternalServerError, Message: "ResponseWriter 不支持 Hijack"}
+	}
+	protocol := selectSubprotocol(r, u.subprotocols)
+
+	netConn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	sb.WriteString("Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	sb.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if protocol != "" {
+		sb.WriteString("Sec-WebSocket-Protocol: " + protocol + "\r\n")
+	}
+	sb.WriteString("\r\n")
+	if _, err = brw.WriteString(sb.String()); err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	conn := newConn(netConn, brw.Reader, true)
+	conn.subprotocol = protocol
+	conn.readLimit = u.readLimit
+	return conn, nil
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func selectSubprotocol(r *http.Request, supported []string) string {
+	offered := headerTokens(r.Header, "Sec-WebSocket-Protocol")
+	for _, s := range supported {
+		for _, o := range offered {
+			if s == o {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// headerTokens 返回逗号分隔的头部里面的所有值
+func headerTokens(header http.Header, name string) []string {
+	var res []string
+	for _, val := range header.Values(name) {
+		for _, token := range strings.Split(val, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				res = append(res, token)
+			}
+		}
+	}
+	return res
+}
+
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, t := range headerTokens(header, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}